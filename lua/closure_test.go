@@ -21,3 +21,19 @@ func TestClosureHeader_L(t *testing.T) {
 		t.Log(v.NumberValue())
 	}
 }
+
+func TestLuaState_fClose(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	/* both closures share the same upvalue, which outlives the frame of `counter' */
+	chunk := "local function counter()\n  local n = 0\n" +
+		"  return function() n = n + 1 return n end, function() return n end\nend\n" +
+		"local inc, get = counter()\ninc()\ninc()\nreturn get()"
+	L.LLoadBuffer([]byte(chunk), "=test")
+	if status := L.PCall(0, 1, 0); status != 0 {
+		t.Fatalf("unexpected error %s", L.ToString(-1))
+	}
+	if got := L.ToString(-1); got != "2" {
+		t.Errorf("want 2 got %q", got)
+	}
+}
@@ -9,6 +9,11 @@ type CommonHeader struct {
 	marked lu_byte
 }
 
+// 对应C：`o->gch'
+func (c *CommonHeader) gch() *GCHeader {
+	return c
+}
+
 func (c *CommonHeader) GetNext() GCObject {
 	return c.next
 }
@@ -6,6 +6,7 @@ import (
 
 func TestCommonHeader_ToClosure(t *testing.T) {
 	lc := &CClosure{}
+	lc.tt = LUA_TFUNCTION
 	var obj GCObject
 	obj = lc
 	t.Logf("%#v", obj.ToClosure().C())
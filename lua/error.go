@@ -0,0 +1,184 @@
+package golua
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 与各个错误状态码对应的哨兵错误，可用于errors.Is判断*LuaError的种类
+var (
+	ErrRun    = errors.New("lua: runtime error")
+	ErrSyntax = errors.New("lua: syntax error")
+	ErrMem    = errors.New("lua: memory allocation error")
+	ErrErr    = errors.New("lua: error in error handling")
+	ErrFile   = errors.New("lua: cannot read file")
+)
+
+// LuaError
+// PCallErr、LDoStringErr等函数返回的错误。
+// Value是Lua中的错误对象转换成的Go值：nil、bool、LuaNumber、string，
+// 其余类型分别为*Table、Closure、*LuaState以及ToUserData的结果。
+type LuaError struct {
+	Status    int         /* LUA_ERRRUN, LUA_ERRSYNTAX, LUA_ERRMEM, LUA_ERRERR or LUA_ERRFILE */
+	Value     interface{} /* the error object */
+	Message   string      /* the error object as a string */
+	ChunkName string      /* chunk where the error was raised, "" if unknown */
+	Line      int         /* line where the error was raised, 0 if unknown */
+	Traceback string      /* "stack traceback:" listing at the point of the error */
+	GoPanic   interface{} /* value of the Go panic that caused the error, if any */
+	GoStack   string      /* Go stack of that panic */
+}
+
+func (e *LuaError) Error() string {
+	return e.Message
+}
+
+// Is 使errors.Is(err, ErrRun)等判断成立
+func (e *LuaError) Is(target error) bool {
+	return target == statusError(e.Status)
+}
+
+// Unwrap 当错误由Go panic引起且panic的值是error时，返回该error
+func (e *LuaError) Unwrap() error {
+	if err, ok := e.GoPanic.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// SetRethrowGoPanics
+// 设置为true时，LuaCFunction中的Go panic不再被转换为Lua错误，而是原样传播给宿主程序，
+// 便于调试。该设置由同一个全局状态下的所有线程共享。
+func (L *LuaState) SetRethrowGoPanics(rethrow bool) {
+	L.G().rethrowGoPanics = rethrow
+}
+
+// 返回状态码对应的哨兵错误
+func statusError(status int) error {
+	switch status {
+	case LUA_ERRRUN:
+		return ErrRun
+	case LUA_ERRSYNTAX:
+		return ErrSyntax
+	case LUA_ERRMEM:
+		return ErrMem
+	case LUA_ERRERR:
+		return ErrErr
+	case LUA_ERRFILE:
+		return ErrFile
+	default:
+		return nil
+	}
+}
+
+// 抛出错误时记录的出错位置，在错误对象被交给调用者之前有效
+type errorInfo struct {
+	chunkName string
+	line      int
+	traceback string
+	goPanic   interface{}
+	goStack   string
+	fresh     bool /* recorded for the error being thrown; see dThrow */
+}
+
+// 记录当前正在执行的最内层Lua函数的位置。
+// 调用栈只在错误会交给宿主时才生成：被Lua中的pcall捕获的错误不需要它。
+func (L *LuaState) saveErrorInfo() {
+	L.errInfo = errorInfo{fresh: true}
+	if L.errorReachesHost() {
+		L.errInfo.traceback = L.Traceback(L, "", 0)
+	}
+	for ci := L.ci; ci > 0; ci-- {
+		if L.baseCi[ci].IsLua() {
+			var p = L.baseCi[ci].Func().L().p
+			L.errInfo.chunkName = oChunkId(string(p.source.Bytes), LUA_IDSIZE)
+			L.errInfo.line = currentline(L, &L.baseCi[ci])
+			break
+		}
+	}
+}
+
+// 正在抛出的错误是否会到达PCallErr等函数，或者没有保护而直接交给宿主。
+// 这些函数建立的恢复点的上一层就是调用它们时的恢复点，Lua中的pcall建立的恢复点都在更里层
+func (L *LuaState) errorReachesHost() bool {
+	return L.errorJmp == nil || L.errTrace && L.errorJmp.previous == L.errTraceJmp
+}
+
+// 在f执行期间，到达f所建立的保护调用的错误会带上调用栈
+func (L *LuaState) withTraceback(f func() int) int {
+	var oldTrace, oldJmp = L.errTrace, L.errTraceJmp
+	L.errTrace, L.errTraceJmp = true, L.errorJmp
+	defer func() {
+		L.errTrace, L.errTraceJmp = oldTrace, oldJmp
+	}()
+	return f()
+}
+
+// 将status和栈顶的错误对象转换为*LuaError，并弹出错误对象
+func (L *LuaState) newLuaError(status int) error {
+	if status == 0 {
+		return nil
+	}
+	var e = &LuaError{
+		Status:    status,
+		ChunkName: L.errInfo.chunkName,
+		Line:      L.errInfo.line,
+		Traceback: L.errInfo.traceback,
+		GoPanic:   L.errInfo.goPanic,
+		GoStack:   L.errInfo.goStack,
+	}
+	var o = index2adr(L, -1)
+	switch o.gcType() {
+	case LUA_TNIL:
+		e.Value = nil
+	case LUA_TBOOLEAN:
+		e.Value = o.BooleanValue()
+	case LUA_TNUMBER:
+		e.Value = o.NumberValue()
+	case LUA_TSTRING:
+		e.Value = L.ToString(-1)
+	case LUA_TTABLE:
+		e.Value = o.TableValue()
+	case LUA_TFUNCTION:
+		e.Value = o.ClosureValue()
+	case LUA_TTHREAD:
+		e.Value = o.ThreadValue()
+	default:
+		e.Value = L.ToUserData(-1)
+	}
+	if L.IsString(-1) {
+		e.Message = L.ToString(-1)
+	} else {
+		e.Message = fmt.Sprintf("(error object is a %s value)", L.LTypeName(-1))
+	}
+	L.Pop(1)
+	return e
+}
+
+// PCallErr
+// 与PCall相同，但出错时返回*LuaError，并将错误对象从栈中弹出。
+func (L *LuaState) PCallErr(nargs int, nresults int, errFunc int) error {
+	L.errInfo = errorInfo{}
+	return L.newLuaError(L.withTraceback(func() int { return L.PCall(nargs, nresults, errFunc) }))
+}
+
+// LLoadBufferErr
+// 与LLoadBuffer相同，但出错时返回*LuaError，并将错误对象从栈中弹出。
+func (L *LuaState) LLoadBufferErr(buff []byte, name string) error {
+	L.errInfo = errorInfo{}
+	return L.newLuaError(L.withTraceback(func() int { return L.LLoadBuffer(buff, name) }))
+}
+
+// LDoStringErr
+// 与LDoString相同，但出错时返回*LuaError，并将错误对象从栈中弹出。
+func (L *LuaState) LDoStringErr(s string) error {
+	L.errInfo = errorInfo{}
+	return L.newLuaError(L.withTraceback(func() int { return L.LDoString(s) }))
+}
+
+// LDoFileErr
+// 与LDoFile相同，但出错时返回*LuaError，并将错误对象从栈中弹出。
+func (L *LuaState) LDoFileErr(filename string) error {
+	L.errInfo = errorInfo{}
+	return L.newLuaError(L.withTraceback(func() int { return L.LDoFile(filename) }))
+}
@@ -0,0 +1,132 @@
+package golua
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLuaState_LDoStringErr(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	err := L.LDoStringErr("local function f(t)\n  return t.x\nend\nf()")
+	if !errors.Is(err, ErrRun) {
+		t.Fatalf("want ErrRun got %v", err)
+	}
+	if errors.Is(err, ErrSyntax) {
+		t.Errorf("runtime error must not match ErrSyntax")
+	}
+	var le *LuaError
+	if !errors.As(err, &le) {
+		t.Fatalf("want *LuaError got %T", err)
+	}
+	if le.Status != LUA_ERRRUN {
+		t.Errorf("want status %d got %d", LUA_ERRRUN, le.Status)
+	}
+	if le.Line != 2 {
+		t.Errorf("want line 2 got %d", le.Line)
+	}
+	if !strings.HasPrefix(le.ChunkName, `[string "local function f(t)..."]`) {
+		t.Errorf("unexpected chunk name %q", le.ChunkName)
+	}
+	if le.Value != le.Message || !strings.HasSuffix(le.Message, "attempt to index local 't' (a nil value)") {
+		t.Errorf("unexpected error value %v", le.Value)
+	}
+	if !strings.HasPrefix(le.Traceback, "stack traceback:") ||
+		!strings.Contains(le.Traceback, ":2: in function 'f'") ||
+		!strings.Contains(le.Traceback, ":4: in main chunk") {
+		t.Errorf("unexpected traceback %q", le.Traceback)
+	}
+	if L.GetTop() != 0 {
+		t.Errorf("error object must be popped, top = %d", L.GetTop())
+	}
+	if err := L.LDoStringErr("local x = 1"); err != nil {
+		t.Errorf("want nil got %v", err)
+	}
+}
+
+func TestLuaState_LDoStringErr_Syntax(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	err := L.LDoStringErr("x = 1\nlocal = 2")
+	if !errors.Is(err, ErrSyntax) {
+		t.Fatalf("want ErrSyntax got %v", err)
+	}
+	le := err.(*LuaError)
+	if le.Line != 2 || le.ChunkName != `[string "x = 1..."]` {
+		t.Errorf("unexpected position %s:%d", le.ChunkName, le.Line)
+	}
+}
+
+func TestLuaState_PCallErr_Value(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	L.Register("fail", func(L *LuaState) int {
+		L.NewTable()
+		return L.Error()
+	})
+	if status := L.LLoadBuffer([]byte("fail()"), "=test"); status != 0 {
+		t.Fatalf("load failed: %s", L.ToString(-1))
+	}
+	err := L.PCallErr(0, 0, 0)
+	le, ok := err.(*LuaError)
+	if !ok {
+		t.Fatalf("want *LuaError got %T", err)
+	}
+	if _, ok := le.Value.(*Table); !ok {
+		t.Errorf("want *Table value got %T", le.Value)
+	}
+	if le.Message != "(error object is a table value)" {
+		t.Errorf("unexpected message %q", le.Message)
+	}
+	if le.ChunkName != "test" || le.Line != 1 {
+		t.Errorf("unexpected position %s:%d", le.ChunkName, le.Line)
+	}
+	if !strings.Contains(le.Traceback, "[C]: in function 'fail'") {
+		t.Errorf("unexpected traceback %q", le.Traceback)
+	}
+}
+
+func TestLuaState_LDoFileErr(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	err := L.LDoFileErr("testdata/no_such_file.lua")
+	if !errors.Is(err, ErrFile) {
+		t.Fatalf("want ErrFile got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "cannot open testdata/no_such_file.lua") {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+}
+
+func TestLuaState_errInfoPerThrow(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	L.Register("fail", func(L *LuaState) int {
+		L.PushString("caught")
+		return L.Error()
+	})
+	L.Register("try", func(L *LuaState) int { /* a pcall that discards the error */
+		L.PCall(L.GetTop()-1, 0, 0)
+		return 0
+	})
+	L.Register("oom", func(L *LuaState) int {
+		L.dThrow(LUA_ERRMEM)
+		return 0
+	})
+	if err := L.LDoStringErr("try(fail)"); err != nil {
+		t.Fatal(err)
+	}
+	if L.errInfo.traceback != "" {
+		t.Errorf("traceback built for an error caught inside Lua: %q", L.errInfo.traceback)
+	}
+	/* an error thrown without position must not reuse the one caught before */
+	err := L.LDoStringErr("try(fail)\noom()")
+	var le *LuaError
+	if !errors.As(err, &le) || le.Status != LUA_ERRMEM || le.Message != "not enough memory" {
+		t.Fatalf("want a memory error got %v", err)
+	}
+	if le.ChunkName != "" || le.Line != 0 || le.Traceback != "" {
+		t.Errorf("stale position %s:%d %q", le.ChunkName, le.Line, le.Traceback)
+	}
+}
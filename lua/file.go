@@ -1,8 +1,20 @@
 package golua
 
 import (
+	"bytes"
 	"io"
 	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+// 缓冲方式，对应C中setvbuf的_IOFBF、_IOLBF与_IONBF
+const (
+	IOFBF = iota /* full buffering */
+	IOLBF        /* line buffering */
+	IONBF        /* no buffering */
 )
 
 type FILE struct {
@@ -12,28 +24,58 @@ type FILE struct {
 	n    int
 	eof  bool
 	err  error
+
+	wbuff []byte    /* pending output */
+	mode  int       /* buffering mode of the output */
+	size  int       /* size of the output buffer */
+	cmd   *exec.Cmd /* process of a file opened by Popen */
+	tmp   string    /* name of a file created by Tmpfile */
 }
 
-var STDIN = &FILE{
-	fp:   os.Stdin,
-	buff: make([]byte, 1024),
-	cur:  0,
-	n:    0,
-	eof:  false,
+func newFILE(fp *os.File, mode int) *FILE {
+	return &FILE{
+		fp:   fp,
+		buff: make([]byte, LUAL_BUFFERSIZE),
+		mode: mode,
+		size: LUAL_BUFFERSIZE,
+	}
 }
 
+// Go没有atexit，无法在退出时刷新stdio，因此标准输出与标准错误都不带缓冲
+var (
+	STDIN  = newFILE(os.Stdin, IOFBF)
+	STDOUT = newFILE(os.Stdout, IONBF)
+	STDERR = newFILE(os.Stderr, IONBF)
+)
+
 func fopen(name string, flag int) (*FILE, error) {
-	f, err := os.OpenFile(name, flag, os.ModePerm)
+	f, err := os.OpenFile(name, flag, 0666)
 	if f == nil || err != nil {
 		return nil, err
 	}
-	return &FILE{
-		fp:   f,
-		buff: make([]byte, 1024),
-		cur:  0,
-		n:    0,
-		eof:  false,
-	}, nil
+	return newFILE(f, IOFBF), nil
+}
+
+// Fopen 以C的fopen模式("r"、"w"、"a"，可带"+"与"b")打开文件
+func Fopen(name string, mode string) (*FILE, error) {
+	var flag int
+	switch strings.Replace(mode, "b", "", 1) {
+	case "r":
+		flag = os.O_RDONLY
+	case "w":
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	case "a":
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	case "r+":
+		flag = os.O_RDWR
+	case "w+":
+		flag = os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	case "a+":
+		flag = os.O_RDWR | os.O_CREATE | os.O_APPEND
+	default:
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EINVAL}
+	}
+	return fopen(name, flag)
 }
 
 func freopen(name string, flag int, old *FILE) (*FILE, error) {
@@ -41,10 +83,56 @@ func freopen(name string, flag int, old *FILE) (*FILE, error) {
 	return fopen(name, flag)
 }
 
+// Popen 通过shell执行command，mode为"r"时读取它的标准输出，为"w"时写入它的标准输入
+func Popen(command string, mode string) (*FILE, error) {
+	if mode != "r" && mode != "w" {
+		return nil, syscall.EINVAL
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/c", command)
+	} else {
+		cmd = exec.Command("/bin/sh", "-c", command)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	var fp, child = r, w
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, w, os.Stderr
+	if mode == "w" {
+		fp, child = w, r
+		cmd.Stdin, cmd.Stdout = r, os.Stdout
+	}
+	if err = cmd.Start(); err != nil {
+		r.Close()
+		w.Close()
+		return nil, err
+	}
+	child.Close()
+	var f = newFILE(fp, IOFBF)
+	f.cmd = cmd
+	return f, nil
+}
+
+// Tmpfile 创建一个临时文件，它在关闭时被删除
+func Tmpfile() (*FILE, error) {
+	fp, err := os.CreateTemp("", "lua_")
+	if err != nil {
+		return nil, err
+	}
+	var f = newFILE(fp, IOFBF)
+	f.tmp = fp.Name()
+	return f, nil
+}
+
 func (f *FILE) fill() {
 	if f.cur < f.n {
 		return
 	}
+	if len(f.wbuff) > 0 {
+		f.Fflush()
+	}
 	n, err := f.fp.Read(f.buff)
 	if n == 0 || err == io.EOF {
 		f.eof = true
@@ -64,7 +152,7 @@ func (f *FILE) EOF() bool {
 	return f.eof
 }
 
-func (f *FILE) getc() (b byte, ok bool) {
+func (f *FILE) Getc() (b byte, ok bool) {
 
 	if f.EOF() {
 		return 0, false
@@ -73,7 +161,7 @@ func (f *FILE) getc() (b byte, ok bool) {
 	return f.buff[f.cur-1], true
 }
 
-func (f *FILE) ungetc(b byte) {
+func (f *FILE) Ungetc(b byte) {
 	f.eof = false
 	if f.cur == 0 {
 		buff := make([]byte, 1024+len(f.buff))
@@ -86,7 +174,7 @@ func (f *FILE) ungetc(b byte) {
 	f.buff[f.cur] = b
 }
 
-func (f *FILE) fread(data []byte) int {
+func (f *FILE) Fread(data []byte) int {
 	var cnt = 0
 	size := len(data)
 
@@ -103,10 +191,95 @@ func (f *FILE) fread(data []byte) int {
 	return cnt
 }
 
-func (f *FILE) fclose() {
-	f.err = f.fp.Close()
+// Fwrite 写入data，返回成功写入的字节数
+func (f *FILE) Fwrite(data []byte) int {
+	if f.cur < f.n { /* switching from reading: give back the read-ahead */
+		f.fp.Seek(int64(f.cur-f.n), io.SeekCurrent)
+		f.cur, f.n = 0, 0
+	}
+	if f.mode == IONBF {
+		n, err := f.fp.Write(data)
+		if err != nil {
+			f.err = err
+		}
+		return n
+	}
+	f.wbuff = append(f.wbuff, data...)
+	if len(f.wbuff) >= f.size || (f.mode == IOLBF && bytes.IndexByte(data, '\n') >= 0) {
+		if f.Fflush() != nil {
+			return 0
+		}
+	}
+	return len(data)
+}
+
+func (f *FILE) Fflush() error {
+	if len(f.wbuff) == 0 {
+		return nil
+	}
+	_, err := f.fp.Write(f.wbuff)
+	f.wbuff = f.wbuff[:0]
+	if err != nil {
+		f.err = err
+	}
+	return err
+}
+
+// Fseek 移动文件位置并返回新的位置，whence取io.SeekStart、io.SeekCurrent或io.SeekEnd
+func (f *FILE) Fseek(offset int64, whence int) (int64, error) {
+	if err := f.Fflush(); err != nil {
+		return 0, err
+	}
+	if whence == io.SeekCurrent { /* the file is ahead of us by the read-ahead */
+		offset -= int64(f.n - f.cur)
+	}
+	pos, err := f.fp.Seek(offset, whence)
+	if err != nil {
+		return 0, err
+	}
+	f.cur, f.n, f.eof = 0, 0, false
+	return pos, nil
+}
+
+// Setvbuf 设置输出的缓冲方式，size不大于0时使用LUAL_BUFFERSIZE
+func (f *FILE) Setvbuf(mode int, size int) error {
+	if mode != IOFBF && mode != IOLBF && mode != IONBF {
+		return syscall.EINVAL
+	}
+	if err := f.Fflush(); err != nil {
+		return err
+	}
+	if size <= 0 {
+		size = LUAL_BUFFERSIZE
+	}
+	f.mode, f.size = mode, size
+	return nil
+}
+
+// Fclose 关闭文件；对于Popen打开的文件还会等待进程结束，进程的退出状态不视为错误
+func (f *FILE) Fclose() error {
+	var err = f.Fflush()
+	if e := f.fp.Close(); err == nil {
+		err = e
+	}
+	if f.cmd != nil {
+		if e := f.cmd.Wait(); err == nil {
+			if _, exited := e.(*exec.ExitError); !exited {
+				err = e
+			}
+		}
+	}
+	if f.tmp != "" {
+		os.Remove(f.tmp)
+	}
+	return err
 }
 
-func (f *FILE) ferror() error {
+func (f *FILE) Ferror() error {
 	return f.err
 }
+
+func (f *FILE) Clearerr() {
+	f.err = nil
+	f.eof = false
+}
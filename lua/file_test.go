@@ -1,7 +1,9 @@
 package golua
 
 import (
+	"io"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -9,7 +11,7 @@ func TestFILE_ungetc(t *testing.T) {
 	f, _ := fopen("file_test.go", os.O_RDONLY)
 	var data []byte
 	for !f.EOF() {
-		b, ok := f.getc()
+		b, ok := f.Getc()
 		if !ok {
 			t.Error("getc failed")
 		}
@@ -17,11 +19,11 @@ func TestFILE_ungetc(t *testing.T) {
 	}
 
 	for i := len(data) - 1; i >= 0; i-- {
-		f.ungetc(data[i])
+		f.Ungetc(data[i])
 	}
 
 	for i := 0; !f.EOF(); i++ {
-		b, ok := f.getc()
+		b, ok := f.Getc()
 		if !ok {
 			t.Error("getc failed")
 		}
@@ -31,7 +33,7 @@ func TestFILE_ungetc(t *testing.T) {
 	}
 
 	for i := len(data) - 1; i >= 0; i-- {
-		f.ungetc(data[i])
+		f.Ungetc(data[i])
 	}
 }
 
@@ -39,7 +41,7 @@ func TestFILE_fread(t *testing.T) {
 	f, _ := fopen("file_test.go", os.O_RDONLY)
 	var data []byte
 	for !f.EOF() {
-		b, ok := f.getc()
+		b, ok := f.Getc()
 		if !ok {
 			t.Error("getc failed")
 		}
@@ -50,7 +52,7 @@ func TestFILE_fread(t *testing.T) {
 	var buf [31]byte
 	j := 0
 	for !f2.EOF() {
-		n := f2.fread(buf[:])
+		n := f2.Fread(buf[:])
 		for i := 0; i < n; i++ {
 			if buf[i] != data[j] {
 				t.Errorf("want %v got %v", data[j], data[i])
@@ -62,3 +64,35 @@ func TestFILE_fread(t *testing.T) {
 		t.Errorf("want %v got %v", len(data), j)
 	}
 }
+
+func TestFILE_fseek(t *testing.T) {
+	f, err := Fopen(filepath.Join(t.TempDir(), "seek.txt"), "w+")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Fclose()
+
+	if n := f.Fwrite([]byte("hello world")); n != 11 {
+		t.Fatalf("Fwrite = %d, want 11", n)
+	}
+	if pos, err := f.Fseek(0, io.SeekStart); pos != 0 || err != nil {
+		t.Fatalf("Fseek = %d, %v", pos, err)
+	}
+	b, _ := f.Getc()
+	if b != 'h' {
+		t.Errorf("want h got %c", b)
+	}
+	/* the read-ahead must not move the logical position */
+	if pos, _ := f.Fseek(0, io.SeekCurrent); pos != 1 {
+		t.Errorf("position after one getc = %d, want 1", pos)
+	}
+	f.Fwrite([]byte("E"))
+	f.Fseek(0, io.SeekStart)
+	var buf [11]byte
+	if n := f.Fread(buf[:]); string(buf[:n]) != "hEllo world" {
+		t.Errorf("read back %q", buf[:n])
+	}
+	if pos, _ := f.Fseek(-5, io.SeekEnd); pos != 6 {
+		t.Errorf("Fseek from end = %d, want 6", pos)
+	}
+}
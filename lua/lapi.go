@@ -52,6 +52,16 @@ func (L *LuaState) PushString(s string) {
 	L.Unlock()
 }
 
+// PushLString
+// 对应C函数：`LUA_API void lua_pushlstring (lua_State *L, const char *s, size_t len)'
+func (L *LuaState) PushLString(s []byte) {
+	L.Lock()
+	L.cCheckGC()
+	L.Top().SetString(L, L.sNewStr(s))
+	L.IncrTop()
+	L.Unlock()
+}
+
 // PushLiteral
 // 对应C函数：`lua_pushliteral(L, s)'
 func (L *LuaState) PushLiteral(s string) {
@@ -138,6 +148,23 @@ func (L *LuaState) Load(reader LuaReadFunc, data interface{}, chunkName []byte)
 	return status
 }
 
+// Dump 把栈顶的Lua函数写成预编译的二进制块，strip为true时不写入调试信息。
+// 栈顶不是Lua函数时返回1，否则返回最后一次调用writer的结果
+// 对应C函数：`LUA_API int lua_dump (lua_State *L, lua_Writer writer, void *data)'
+func (L *LuaState) Dump(writer LuaWriteFunc, data interface{}, strip bool) int {
+	var status int
+	L.Lock()
+	L.apiCheckNElems(1)
+	var o = L.AtTop(-1)
+	if o.IsFunction() && o.ClosureValue().IsLFunction() {
+		status = L.uDump(o.LFuncValue().p, writer, data, strip)
+	} else {
+		status = 1
+	}
+	L.Unlock()
+	return status
+}
+
 func index2adr(L *LuaState, idx int) *TValue {
 	p, _ := index2addr(L, idx)
 	return p
@@ -210,6 +237,92 @@ func (L *LuaState) Insert(idx int) {
 	L.Unlock()
 }
 
+// Replace
+// 对应C函数：`LUA_API void lua_replace (lua_State *L, int idx)'
+func (L *LuaState) Replace(idx int) {
+	L.Lock()
+	/* explicit test for incompatible code */
+	if idx == LUA_ENVIRONINDEX && L.ci == 0 {
+		L.DbgRunError("no calling environment")
+	}
+	L.apiCheckNElems(1)
+	var o = index2adr(L, idx)
+	L.apiCheckValidIndex(o)
+	if idx == LUA_ENVIRONINDEX {
+		var fn = L.CurrFunc().C()
+		L.apiCheck(L.AtTop(-1).IsTable())
+		fn.env = L.AtTop(-1).TableValue()
+		L.cBarrier(fn, L.AtTop(-1))
+	} else {
+		o.SetObj(L, L.AtTop(-1))
+		if idx < LUA_GLOBALSINDEX { /* function upvalue? */
+			L.cBarrier(L.CurrFunc().C(), L.AtTop(-1))
+		}
+	}
+	L.top--
+	L.Unlock()
+}
+
+// CheckStack
+// 对应C函数：`LUA_API int lua_checkstack (lua_State *L, int size)'
+func (L *LuaState) CheckStack(size int) bool {
+	var res = true
+	L.Lock()
+	if size > LUAI_MAXCSTACK || (L.top-L.base+size) > LUAI_MAXCSTACK {
+		res = false /* stack overflow */
+	} else if size > 0 {
+		L.dCheckStack(size)
+		if L.CI().top < L.top+size {
+			L.CI().top = L.top + size
+		}
+	}
+	L.Unlock()
+	return res
+}
+
+// XMove
+// 对应C函数：`LUA_API void lua_xmove (lua_State *from, lua_State *to, int n)'
+func (from *LuaState) XMove(to *LuaState, n int) {
+	if from == to {
+		return
+	}
+	from.Lock()
+	from.apiCheckNElems(n)
+	from.apiCheck(from.G() == to.G())
+	from.apiCheck(to.CI().top-to.top >= n)
+	from.top -= n
+	for i := 0; i < n; i++ {
+		to.stack[to.top].SetObj(to, &from.stack[from.top+i])
+		to.top++
+	}
+	from.Unlock()
+}
+
+// SetLevel
+// 对应C函数：`LUA_API void lua_setlevel (lua_State *from, lua_State *to)'
+func (from *LuaState) SetLevel(to *LuaState) {
+	to.nCCalls = from.nCCalls
+}
+
+// NewThread
+// 对应C函数：`LUA_API lua_State *lua_newthread (lua_State *L)'
+func (L *LuaState) NewThread() *LuaState {
+	L.Lock()
+	L.cCheckGC()
+	var L1 = L.eNewThread()
+	L.Top().SetThread(L, L1)
+	L.IncrTop()
+	L.Unlock()
+	LUAIUserStateThread(L1)
+	return L1
+}
+
+// Status
+// 对应C函数：`LUA_API int lua_status (lua_State *L)'
+func (L *LuaState) Status() int {
+	return int(L.status)
+}
+
 // AtPanic
 // 对应C函数：`LUA_API lua_CFunction lua_atpanic (lua_State *L, lua_CFunction panicf)'
 func (L *LuaState) AtPanic(fPanic LuaCFunction) LuaCFunction {
@@ -275,6 +388,19 @@ func (L *LuaState) RawSet(idx int) {
 	L.Unlock()
 }
 
+// RawSetI
+// 对应C函数：`LUA_API void lua_rawseti (lua_State *L, int idx, int n)'
+func (L *LuaState) RawSetI(idx int, n int) {
+	L.Lock()
+	L.apiCheckNElems(1)
+	var o = index2adr(L, idx)
+	L.apiCheck(o.IsTable())
+	o.TableValue().SetByNum(L, n).SetObj(L, L.AtTop(-1))
+	L.cBarrierT(o.TableValue(), L.AtTop(-1))
+	L.top--
+	L.Unlock()
+}
+
 // SetMetaTable
 // 对应C函数：`LUA_API int lua_setmetatable (lua_State *L, int objindex)'
 func (L *LuaState) SetMetaTable(objIndex int) int {
@@ -309,6 +435,35 @@ func (L *LuaState) SetMetaTable(objIndex int) int {
 	return 1
 }
 
+// SetFEnv 弹出栈顶的表，把它设为idx处函数、userdata或线程的环境；
+// 对于线程，设置的是它的全局表。idx处是其它类型的值时返回0
+// 对应C函数：`LUA_API int lua_setfenv (lua_State *L, int idx)'
+func (L *LuaState) SetFEnv(idx int) int {
+	var res = 1
+	L.Lock()
+	L.apiCheckNElems(1)
+	var o = index2adr(L, idx)
+	L.apiCheckValidIndex(o)
+	L.apiCheck(L.AtTop(-1).IsTable())
+	var env = L.AtTop(-1).TableValue()
+	switch o.gcType() {
+	case LUA_TFUNCTION:
+		o.ClosureValue().C().env = env
+	case LUA_TUSERDATA:
+		o.UdataValue().env = env
+	case LUA_TTHREAD:
+		o.ThreadValue().GlobalTable().SetTable(L, env)
+	default:
+		res = 0
+	}
+	if res != 0 {
+		L.cObjBarrier(o.GcValue(), env)
+	}
+	L.top--
+	L.Unlock()
+	return res
+}
+
 // Type
 // 对应C函数：`LUA_API int lua_type (lua_State *L, int idx)'
 func (L *LuaState) Type(idx int) ttype {
@@ -332,6 +487,13 @@ func (L *LuaState) IsFunction(idx int) bool {
 	return L.Type(idx) == LUA_TFUNCTION
 }
 
+// IsCFunction
+// 对应C函数：`LUA_API int lua_iscfunction (lua_State *L, int idx)'
+func (L *LuaState) IsCFunction(idx int) bool {
+	var o = index2adr(L, idx)
+	return o.IsFunction() && o.ClosureValue().IsCFunction()
+}
+
 // IsTable
 // 对应C函数：`lua_istable(L,n)'
 func (L *LuaState) IsTable(idx int) bool {
@@ -395,6 +557,26 @@ func (L *LuaState) ToUserData(idx int) interface{} {
 	}
 }
 
+// ToCFunction
+// 对应C函数：`LUA_API lua_CFunction lua_tocfunction (lua_State *L, int idx)'
+func (L *LuaState) ToCFunction(idx int) LuaCFunction {
+	var o = index2adr(L, idx)
+	if !o.IsFunction() || !o.ClosureValue().IsCFunction() {
+		return nil
+	}
+	return o.ClosureValue().C().f
+}
+
+// LuaToThread
+// 对应C函数：`LUA_API lua_State *lua_tothread (lua_State *L, int idx)'
+func (L *LuaState) LuaToThread(idx int) *LuaState {
+	var o = index2adr(L, idx)
+	if !o.IsThread() {
+		return nil
+	}
+	return o.ThreadValue()
+}
+
 // ToPointer
 // 对应C函数：`LUA_API const void *lua_topointer (lua_State *L, int idx) '
 func (L *LuaState) ToPointer(idx int) unsafe.Pointer {
@@ -403,11 +585,18 @@ func (L *LuaState) ToPointer(idx int) unsafe.Pointer {
 	case LUA_TTABLE:
 		return unsafe.Pointer(o.TableValue())
 	case LUA_TFUNCTION:
-		return reflect.ValueOf(o.ClosureValue()).Addr().UnsafePointer()
+		return reflect.ValueOf(o.ClosureValue()).UnsafePointer()
 	case LUA_TTHREAD:
 		return unsafe.Pointer(o.ThreadValue())
-	case LUA_TUSERDATA, LUA_TLIGHTUSERDATA:
-		return reflect.ValueOf(L.ToUserData(idx)).Addr().UnsafePointer()
+	case LUA_TUSERDATA:
+		return unsafe.Pointer(o.UdataValue())
+	case LUA_TLIGHTUSERDATA:
+		switch p := reflect.ValueOf(o.PointerValue()); p.Kind() {
+		case reflect.Ptr, reflect.UnsafePointer, reflect.Func, reflect.Map, reflect.Chan, reflect.Slice:
+			return p.UnsafePointer()
+		default:
+			return nil
+		}
 	default:
 		return nil
 	}
@@ -489,6 +678,26 @@ func (L *LuaState) GetMetaTable(objIndex int) int {
 	return res
 }
 
+// GetFEnv 把idx处函数、userdata或线程的环境压栈，其它类型的值压入nil
+// 对应C函数：`LUA_API void lua_getfenv (lua_State *L, int idx)'
+func (L *LuaState) GetFEnv(idx int) {
+	L.Lock()
+	var o = index2adr(L, idx)
+	L.apiCheckValidIndex(o)
+	switch o.gcType() {
+	case LUA_TFUNCTION:
+		L.Top().SetTable(L, o.ClosureValue().C().env)
+	case LUA_TUSERDATA:
+		L.Top().SetTable(L, o.UdataValue().env)
+	case LUA_TTHREAD:
+		L.Top().SetObj(L, o.ThreadValue().GlobalTable())
+	default:
+		L.Top().SetNil()
+	}
+	L.IncrTop()
+	L.Unlock()
+}
+
 // NewTable
 // 对应C函数：`lua_newtable(L)'
 func (L *LuaState) NewTable() {
@@ -502,11 +711,21 @@ func (L *LuaState) SetTable(idx int) {
 	L.apiCheckNElems(2)
 	var t = index2adr(L, idx)
 	L.apiCheckValidIndex(t)
-	L.vSetTable(t, L.AtTop(-2), L.AtTop(-2))
+	L.vSetTable(t, L.AtTop(-2), L.AtTop(-1))
 	L.top -= 2 /* pop index and value */
 	L.Unlock()
 }
 
+// GetTable
+// 对应C函数：`LUA_API void lua_gettable (lua_State *L, int idx)'
+func (L *LuaState) GetTable(idx int) {
+	L.Lock()
+	var t = index2adr(L, idx)
+	L.apiCheckValidIndex(t)
+	L.vGetTable(t, L.AtTop(-1), L.AtTop(-1))
+	L.Unlock()
+}
+
 // GetField
 // 对应C函数：`LUA_API void lua_getfield (lua_State *L, int idx, const char *k)'
 func (L *LuaState) GetField(idx int, k string) {
@@ -592,6 +811,36 @@ func (L *LuaState) PushInteger(n LuaInteger) {
 	L.Unlock()
 }
 
+// PushNumber
+// 对应C函数：`LUA_API void lua_pushnumber (lua_State *L, lua_Number n)'
+func (L *LuaState) PushNumber(n LuaNumber) {
+	L.Lock()
+	L.Top().SetNumber(n)
+	L.IncrTop()
+	L.Unlock()
+}
+
+// PushLightUserData
+// 对应C函数：`LUA_API void lua_pushlightuserdata (lua_State *L, void *p)'
+// p应当是指针、map、chan或者函数等可以取得地址的值，相同的地址视为同一个键
+func (L *LuaState) PushLightUserData(p interface{}) {
+	L.Lock()
+	L.Top().SetAny(p)
+	L.IncrTop()
+	L.Unlock()
+}
+
+// PushThread
+// 对应C函数：`LUA_API int lua_pushthread (lua_State *L)'
+// 返回L是否是主线程
+func (L *LuaState) PushThread() bool {
+	L.Lock()
+	L.Top().SetThread(L, L)
+	L.IncrTop()
+	L.Unlock()
+	return L.G().mainThread == L
+}
+
 // PushBoolean
 // 对应C函数：`LUA_API void lua_pushboolean (lua_State *L, int b)'
 func (L *LuaState) PushBoolean(b bool) {
@@ -604,9 +853,9 @@ func (L *LuaState) PushBoolean(b bool) {
 // ToInteger
 // 对应C函数：`LUA_API lua_Integer lua_tointeger (lua_State *L, int idx)'
 func (L *LuaState) ToInteger(idx int) LuaInteger {
-	var o = index2adr(L, idx)
 	var n TValue
-	if tonumber(o, &n) {
+	var o = vToNumber(index2adr(L, idx), &n)
+	if o != nil {
 		var num = o.NumberValue()
 		return lua_number2integer(num)
 	} else {
@@ -614,6 +863,18 @@ func (L *LuaState) ToInteger(idx int) LuaInteger {
 	}
 }
 
+// ToNumber
+// 对应C函数：`LUA_API lua_Number lua_tonumber (lua_State *L, int idx)'
+func (L *LuaState) ToNumber(idx int) LuaNumber {
+	var n TValue
+	var o = vToNumber(index2adr(L, idx), &n)
+	if o != nil {
+		return o.NumberValue()
+	} else {
+		return 0
+	}
+}
+
 // IsNumber
 // 对应C函数：`LUA_API int lua_isnumber (lua_State *L, int idx)'
 func (L *LuaState) IsNumber(idx int) bool {
@@ -622,6 +883,112 @@ func (L *LuaState) IsNumber(idx int) bool {
 	return tonumber(o, &n)
 }
 
+// RawEqual
+// 对应C函数：`LUA_API int lua_rawequal (lua_State *L, int index1, int index2)'
+func (L *LuaState) RawEqual(index1 int, index2 int) bool {
+	var o1 = index2adr(L, index1)
+	var o2 = index2adr(L, index2)
+	if o1 == LuaObjNil || o2 == LuaObjNil {
+		return false
+	}
+	return oRawEqualObj(o1, o2)
+}
+
+// LessThan
+// 对应C函数：`LUA_API int lua_lessthan (lua_State *L, int index1, int index2)'
+func (L *LuaState) LessThan(index1 int, index2 int) bool {
+	L.Lock() /* may call tag method */
+	var o1 = index2adr(L, index1)
+	var o2 = index2adr(L, index2)
+	var i = o1 != LuaObjNil && o2 != LuaObjNil && L.vLessThan(o1, o2)
+	L.Unlock()
+	return i
+}
+
+// ObjLen
+// 对应C函数：`LUA_API size_t lua_objlen (lua_State *L, int idx)'
+func (L *LuaState) ObjLen(idx int) int {
+	var o = index2adr(L, idx)
+	switch o.gcType() {
+	case LUA_TSTRING:
+		return o.StringValue().Len
+	case LUA_TUSERDATA:
+		return o.UdataValue().len
+	case LUA_TTABLE:
+		return o.TableValue().GetN()
+	case LUA_TNUMBER:
+		var l int
+		L.Lock() /* `luaV_tostring' may create a new string */
+		if o.vToString(L) {
+			l = o.StringValue().Len
+		}
+		L.Unlock()
+		return l
+	default:
+		return 0
+	}
+}
+
+// GC
+// 对应C函数：`LUA_API int lua_gc (lua_State *L, int what, int data)'
+func (L *LuaState) GC(what int, data int) int {
+	var res = 0
+	L.Lock()
+	var g = L.G()
+	switch what {
+	case LUA_GCSTOP:
+		g.GCThreshold = MAX_LUMEM
+	case LUA_GCRESTART:
+		g.GCThreshold = g.totalBytes
+	case LUA_GCCOLLECT:
+		L.cFullGC()
+	case LUA_GCCOUNT:
+		/* GC values are expressed in Kbytes: #bytes/2^10 */
+		res = g.totalBytes >> 10
+	case LUA_GCCOUNTB:
+		res = g.totalBytes & 0x3ff
+	case LUA_GCSTEP:
+		var a = lu_mem(data) << 10
+		if a <= g.totalBytes {
+			g.GCThreshold = g.totalBytes - a
+		} else {
+			g.GCThreshold = 0
+		}
+		for g.GCThreshold <= g.totalBytes {
+			L.cStep()
+			if g.gcState == GCSPause { /* end of cycle? */
+				res = 1 /* signal it */
+				break
+			}
+		}
+	case LUA_GCSETPAUSE:
+		res = g.gcPause
+		g.gcPause = data
+	case LUA_GCSETSTEPMUL:
+		res = g.gcStepMul
+		g.gcStepMul = data
+	default:
+		res = -1 /* invalid option */
+	}
+	L.Unlock()
+	return res
+}
+
+// SetMemLimit 设置这个状态（包括它的所有线程）可以使用的内存上限，单位是字节，返回原来的上限。
+// limit为0表示不限制。超过上限的分配会抛出LUA_ERRMEM错误（"not enough memory"）。
+// C的Lua通过lua_Alloc返回NULL报告内存不足，这里的内存由Go分配，所以改由宿主设置上限。
+func (L *LuaState) SetMemLimit(limit int) int {
+	L.Lock()
+	var g = L.G()
+	var old = g.memLimit
+	g.memLimit = limit
+	if g.GCThreshold != MAX_LUMEM { /* collector not stopped? */
+		g.setThreshold()
+	}
+	L.Unlock()
+	return old
+}
+
 // NewUserData
 // 对应C函数：`LUA_API void *lua_newuserdata (lua_State *L, size_t size) '
 func (L *LuaState) NewUserData(size int) interface{} {
@@ -633,3 +1000,58 @@ func (L *LuaState) NewUserData(size int) interface{} {
 	L.Unlock()
 	return u.data
 }
+
+// 对应C函数：`static const char *aux_upvalue (StkId fi, int n, TValue **val)'
+func auxUpvalue(fi StkId, n int) (name string, val *TValue, ok bool) {
+	if !fi.IsFunction() {
+		return "", nil, false
+	}
+	var f = fi.ClosureValue()
+	if f.IsCFunction() {
+		var cl = f.C()
+		if !(1 <= n && n <= int(cl.nUpValues)) {
+			return "", nil, false
+		}
+		return "", &cl.upValue[n-1], true
+	} else {
+		var cl = f.L()
+		var p = cl.p
+		if !(1 <= n && n <= len(p.upValues)) {
+			return "", nil, false
+		}
+		return string(p.upValues[n-1].GetStr()), cl.upVals[n-1].v, true
+	}
+}
+
+// GetUpvalue
+// 将funcIndex处的函数的第n个upvalue压入栈中，并返回它的名字（C函数的upvalue名字为空串）。
+// 没有这个upvalue时返回false，且不压入任何值。
+// 对应C函数：`LUA_API const char *lua_getupvalue (lua_State *L, int funcindex, int n)'
+func (L *LuaState) GetUpvalue(funcIndex int, n int) (string, bool) {
+	L.Lock()
+	var name, val, ok = auxUpvalue(index2adr(L, funcIndex), n)
+	if ok {
+		L.Top().SetObj(L, val)
+		L.IncrTop()
+	}
+	L.Unlock()
+	return name, ok
+}
+
+// SetUpvalue
+// 将栈顶的值弹出并赋给funcIndex处的函数的第n个upvalue，返回它的名字。
+// 没有这个upvalue时返回false，且栈保持不变。
+// 对应C函数：`LUA_API const char *lua_setupvalue (lua_State *L, int funcindex, int n)'
+func (L *LuaState) SetUpvalue(funcIndex int, n int) (string, bool) {
+	L.Lock()
+	var fi = index2adr(L, funcIndex)
+	L.apiCheckNElems(1)
+	var name, val, ok = auxUpvalue(fi, n)
+	if ok {
+		L.top--
+		val.SetObj(L, L.Top())
+		L.cBarrier(fi.ClosureValue().C(), L.Top())
+	}
+	L.Unlock()
+	return name, ok
+}
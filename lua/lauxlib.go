@@ -1,8 +1,10 @@
 package golua
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"strings"
 )
@@ -83,14 +85,14 @@ func (L *LuaState) LLoadFile(filename []byte) int {
 			return errFile(L, "open", fNameIndex, err)
 		}
 	}
-	c, _ := lf.f.getc()
+	c, _ := lf.f.Getc()
 	if c == '#' { /* Unix exec. file? */
 		lf.extraLine = 1
 		for !lf.f.EOF() && c != '\n' { /* skip first line */
-			c, _ = lf.f.getc()
+			c, _ = lf.f.Getc()
 		}
 		if c == '\n' {
-			c, _ = lf.f.getc()
+			c, _ = lf.f.Getc()
 		}
 	}
 	if c == LUA_SIGNATURE[0] && len(filename) != 0 { /* binary file？ */
@@ -101,15 +103,15 @@ func (L *LuaState) LLoadFile(filename []byte) int {
 		}
 		/* skip eventual `#!...' */
 		for !lf.f.EOF() && c != LUA_SIGNATURE[0] {
-			c, _ = lf.f.getc()
+			c, _ = lf.f.Getc()
 		}
 		lf.extraLine = 0
 	}
-	lf.f.ungetc(c)
+	lf.f.Ungetc(c)
 	status := L.Load(getF, &lf, []byte(L.ToString(-1)))
-	readStatus := lf.f.ferror()
+	readStatus := lf.f.Ferror()
 	if len(filename) != 0 {
-		lf.f.fclose() /* close file (even in case of errors) */
+		lf.f.Fclose() /* close file (even in case of errors) */
 	}
 	if readStatus != nil {
 		L.SetTop(fNameIndex) /* ignore results from `lua_load' */
@@ -119,6 +121,31 @@ func (L *LuaState) LLoadFile(filename []byte) int {
 	return status
 }
 
+// LLoadFileFS
+// 与LLoadFile相同，但是从fsys中读取名为filename的文件
+func (L *LuaState) LLoadFileFS(fsys fs.FS, filename string) int {
+	fNameIndex := L.GetTop() + 1 /* index of filename on the stack */
+	L.PushFString("@%s", filename)
+	data, err := fs.ReadFile(fsys, filename)
+	if err != nil {
+		return errFile(L, "open", fNameIndex, err)
+	}
+	if len(data) > 0 && data[0] == '#' { /* Unix exec. file? */
+		/* skip first line, but keep its newline so that line numbers stay right */
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i:]
+		} else {
+			data = nil
+		}
+		if len(data) > 1 && data[1] == LUA_SIGNATURE[0] { /* binary file? */
+			data = data[1:]
+		}
+	}
+	status := L.LLoadBuffer(data, L.ToString(-1))
+	L.Remove(fNameIndex)
+	return status
+}
+
 // LoadF
 // 对应C结构体：`struct LoadF'
 type LoadF struct {
@@ -138,7 +165,7 @@ func getF(L *LuaState, ud interface{}) (data []byte, size int) {
 	if lf.f.EOF() {
 		return nil, 0
 	}
-	size = lf.f.fread(lf.buff[:])
+	size = lf.f.Fread(lf.buff[:])
 	if size > 0 {
 		return lf.buff[:], size
 	}
@@ -150,6 +177,10 @@ const LUA_ERRFILE = LUA_ERRERR + 1 /* extra error code for `luaL_load' */
 // 对应C函数：`static int errfile (lua_State *L, const char *what, int fnameindex)'
 func errFile(L *LuaState, what string, fnameIndex int, err error) int {
 	filename := L.ToString(fnameIndex)[1:]
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) { /* the file name is already in the message */
+		err = pathErr.Err
+	}
 	L.PushFString("cannot %s %s: %s", what, filename, err.Error())
 	L.Remove(fnameIndex)
 	return LUA_ERRFILE
@@ -176,6 +207,43 @@ func LNewState() *LuaState {
 	return L
 }
 
+// 对应C函数：`abs_index(L, i)'
+func absIndex(L *LuaState, i int) int {
+	if i > 0 || i <= LUA_REGISTRYINDEX {
+		return i
+	}
+	return L.GetTop() + i + 1
+}
+
+// LGetMetaField
+// 对应C函数：`LUALIB_API int luaL_getmetafield (lua_State *L, int obj, const char *event)'
+func (L *LuaState) LGetMetaField(obj int, event string) bool {
+	if L.GetMetaTable(obj) == 0 { /* no metatable? */
+		return false
+	}
+	L.PushString(event)
+	L.RawGet(-2)
+	if L.IsNil(-1) {
+		L.Pop(2) /* remove metatable and metafield */
+		return false
+	} else {
+		L.Remove(-2) /* remove only metatable */
+		return true
+	}
+}
+
+// LCallMeta
+// 对应C函数：`LUALIB_API int luaL_callmeta (lua_State *L, int obj, const char *event)'
+func (L *LuaState) LCallMeta(obj int, event string) bool {
+	obj = absIndex(L, obj)
+	if !L.LGetMetaField(obj, event) { /* no metafield? */
+		return false
+	}
+	L.PushValue(obj)
+	L.Call(1, 1)
+	return true
+}
+
 // LTypeName
 // 对应C函数：`luaL_typename(L,i)'
 func (L *LuaState) LTypeName(idx int) string {
@@ -223,6 +291,26 @@ func (L *LuaState) IOpenLib(libName string, l []LReg, nup int) {
 	L.Pop(nup) /* remove upvalues */
 }
 
+// LGSub
+// 把s中所有的p替换为r，将结果压入栈中并返回
+// 对应C函数：`LUALIB_API const char *luaL_gsub (lua_State *L, const char *s, const char *p, const char *r)'
+func (L *LuaState) LGSub(s, p, r string) string {
+	var b LBuffer
+	L.LBuffInit(&b)
+	for {
+		var wild = strings.Index(s, p)
+		if wild < 0 {
+			break
+		}
+		b.AddString(s[:wild]) /* push prefix */
+		b.AddString(r)        /* push replace string */
+		s = s[wild+len(p):]   /* continue after `p' */
+	}
+	b.AddString(s) /* push last suffix */
+	b.PushResult()
+	return L.ToString(-1)
+}
+
 // LFindTable
 // 对应C函数：`LUALIB_API const char *luaL_findtable (lua_State *L, int idx, const char *fname, int szhint)
 func (L *LuaState) LFindTable(idx int, fName string, szHint int) error {
@@ -261,8 +349,14 @@ func (L *LuaState) LFindTable(idx int, fName string, szHint int) error {
 // LWhere
 // 对应C函数：`LUALIB_API void luaL_where (lua_State *L, int level)'
 func (L *LuaState) LWhere(level int) {
-	// todo: LWhere
-	/* ... */
+	var ar LuaDebug
+	if L.GetStack(level, &ar) { /* check function at level */
+		L.GetInfo("Sl", &ar)
+		if ar.CurrentLine > 0 { /* is there info? */
+			L.PushFString("%s:%d: ", ar.ShortSrc, ar.CurrentLine)
+			return
+		}
+	}
 	L.PushLiteral("") /* else, no information available... */
 }
 
@@ -275,6 +369,76 @@ func (L *LuaState) LError(format string, args ...interface{}) int {
 	return L.Error()
 }
 
+// LGetN
+// 对应C函数：`luaL_getn(L,i)'
+// 未定义LUA_COMPAT_GETN时，luaL_getn就是lua_objlen，luaL_setn不做任何事
+func (L *LuaState) LGetN(idx int) int {
+	return L.ObjLen(idx)
+}
+
+// LCheckOption
+// 对应C函数：`LUALIB_API int luaL_checkoption (lua_State *L, int narg, const char *def, const char *const lst[])'
+// def为""时表示该参数没有默认值
+func (L *LuaState) LCheckOption(nArg int, def string, lst []string) int {
+	var name string
+	if def != "" {
+		name = L.LOptString(nArg, def)
+	} else {
+		name = L.LCheckString(nArg)
+	}
+	for i := range lst {
+		if lst[i] == name {
+			return i
+		}
+	}
+	return L.LArgError(nArg, string(L.PushFString("invalid option "+LUA_QS, name)))
+}
+
+// LNewMetaTable
+// 在注册表中创建名为tName的元表并压入栈中；该名字已被使用时压入原来的值并返回false
+// 对应C函数：`LUALIB_API int luaL_newmetatable (lua_State *L, const char *tname)'
+func (L *LuaState) LNewMetaTable(tName string) bool {
+	L.GetField(LUA_REGISTRYINDEX, tName) /* get registry.name */
+	if !L.IsNil(-1) {                    /* name already in use? */
+		return false /* leave previous value on top, but return 0 */
+	}
+	L.Pop(1)
+	L.NewTable() /* create metatable */
+	L.PushValue(-1)
+	L.SetField(LUA_REGISTRYINDEX, tName) /* registry.name = metatable */
+	return true
+}
+
+// LGetMetaTable
+// 对应C函数：`luaL_getmetatable(L,n)'
+func (L *LuaState) LGetMetaTable(tName string) {
+	L.GetField(LUA_REGISTRYINDEX, tName)
+}
+
+// LCheckUData
+// 对应C函数：`LUALIB_API void *luaL_checkudata (lua_State *L, int ud, const char *tname)'
+func (L *LuaState) LCheckUData(ud int, tName string) *Udata {
+	if p, ok := L.ToUserData(ud).(*Udata); ok { /* value is a userdata? */
+		if L.GetMetaTable(ud) != 0 { /* does it have a metatable? */
+			L.GetField(LUA_REGISTRYINDEX, tName) /* get correct metatable */
+			if L.RawEqual(-1, -2) {              /* does it have the correct mt? */
+				L.Pop(2) /* remove both metatables */
+				return p
+			}
+		}
+	}
+	L.LTypeError(ud, tName) /* else error */
+	return nil              /* to avoid warnings */
+}
+
+// LCheckStack
+// 对应C函数：`LUALIB_API void luaL_checkstack (lua_State *L, int space, const char *mes)'
+func (L *LuaState) LCheckStack(space int, mes string) {
+	if !L.CheckStack(space) {
+		L.LError("stack overflow (%s)", mes)
+	}
+}
+
 // LCheckType
 // 对应C函数：`LUALIB_API void luaL_checktype (lua_State *L, int narg, int t)'
 func (L *LuaState) LCheckType(nArg int, t ttype) {
@@ -283,19 +447,102 @@ func (L *LuaState) LCheckType(nArg int, t ttype) {
 	}
 }
 
+// LCheckAny
+// 对应C函数：`LUALIB_API void luaL_checkany (lua_State *L, int narg)'
+func (L *LuaState) LCheckAny(nArg int) {
+	if L.Type(nArg) == LUA_TNONE {
+		L.LArgError(nArg, "value expected")
+	}
+}
+
 // =======================================================
 // Error-report functions
 // =======================================================
 
 // LArgError
 // 对应C函数：`LUALIB_API int luaL_argerror (lua_State *L, int narg, const char *extramsg)'
-func (L *LuaState) LArgError(nArg int, extraMsg string) {
-	// todo: LArgError
+func (L *LuaState) LArgError(nArg int, extraMsg string) int {
+	var ar LuaDebug
+	if !L.GetStack(0, &ar) { /* no stack frame? */
+		return L.LError("bad argument #%d (%s)", nArg, extraMsg)
+	}
+	L.GetInfo("n", &ar)
+	if ar.NameWhat == "method" {
+		nArg--         /* do not count `self' */
+		if nArg == 0 { /* error is in the self argument itself? */
+			return L.LError("calling "+LUA_QS+" on bad self (%s)", ar.Name, extraMsg)
+		}
+	}
+	if ar.Name == "" {
+		ar.Name = "?"
+	}
+	return L.LError("bad argument #%d to "+LUA_QS+" (%s)", nArg, ar.Name, extraMsg)
+}
+
+// LTypeError
+// 对应C函数：`LUALIB_API int luaL_typerror (lua_State *L, int narg, const char *tname)'
+func (L *LuaState) LTypeError(nArg int, tName string) int {
+	var msg = L.PushFString("%s expected, got %s", tName, L.LTypeName(nArg))
+	return L.LArgError(nArg, string(msg))
 }
 
 // 对应C函数：`static void tag_error (lua_State *L, int narg, int tag)'
 func (L *LuaState) tagError(nArg int, tag ttype) {
-	// todo: tagError
+	L.LTypeError(nArg, L.TypeName(tag))
+}
+
+// LCheckLString
+// 对应C函数：`LUALIB_API const char *luaL_checklstring (lua_State *L, int narg, size_t *len)'
+func (L *LuaState) LCheckLString(nArg int) ([]byte, int) {
+	var s, l = L.ToLString(nArg)
+	if s == nil && !L.IsString(nArg) { /* an empty string may have nil bytes */
+		L.tagError(nArg, LUA_TSTRING)
+	}
+	return s, l
+}
+
+// LCheckString
+// 对应C函数：`luaL_checkstring(L,n)'
+func (L *LuaState) LCheckString(nArg int) string {
+	var s, _ = L.LCheckLString(nArg)
+	return string(s)
+}
+
+// LOptLString
+// 对应C函数：`LUALIB_API const char *luaL_optlstring (lua_State *L, int narg, const char *def, size_t *len)'
+func (L *LuaState) LOptLString(nArg int, def string) ([]byte, int) {
+	if L.IsNoneOrNil(nArg) {
+		return []byte(def), len(def)
+	}
+	return L.LCheckLString(nArg)
+}
+
+// LOptString
+// 对应C函数：`luaL_optstring(L,n,d)'
+func (L *LuaState) LOptString(nArg int, def string) string {
+	if L.IsNoneOrNil(nArg) {
+		return def
+	}
+	return L.LCheckString(nArg)
+}
+
+// LCheckNumber
+// 对应C函数：`LUALIB_API lua_Number luaL_checknumber (lua_State *L, int narg)'
+func (L *LuaState) LCheckNumber(nArg int) LuaNumber {
+	var d = L.ToNumber(nArg)
+	if d == 0 && !L.IsNumber(nArg) { /* avoid extra test when d is not 0 */
+		L.tagError(nArg, LUA_TNUMBER)
+	}
+	return d
+}
+
+// LOptNumber
+// 对应C函数：`LUALIB_API lua_Number luaL_optnumber (lua_State *L, int narg, lua_Number def)'
+func (L *LuaState) LOptNumber(nArg int, def LuaNumber) LuaNumber {
+	if L.IsNoneOrNil(nArg) {
+		return def
+	}
+	return L.LCheckNumber(nArg)
 }
 
 // LCheckInteger
@@ -314,6 +561,21 @@ func (L *LuaState) LCheckInt(n int) int {
 	return int(L.LCheckInteger(n))
 }
 
+// LOptInteger
+// 对应C函数：`LUALIB_API lua_Integer luaL_optinteger (lua_State *L, int narg, lua_Integer def)'
+func (L *LuaState) LOptInteger(nArg int, def LuaInteger) LuaInteger {
+	if L.IsNoneOrNil(nArg) {
+		return def
+	}
+	return L.LCheckInteger(nArg)
+}
+
+// LOptInt
+// 对应C函数：`luaL_optint(L,n,d)'
+func (L *LuaState) LOptInt(n int, d int) int {
+	return int(L.LOptInteger(n, LuaInteger(d)))
+}
+
 // LArgCheck
 // 对应C函数：`luaL_argcheck(L, cond,numarg,extramsg)'
 func (L *LuaState) LArgCheck(cond bool, numArg int, extraMsg string) {
@@ -321,3 +583,82 @@ func (L *LuaState) LArgCheck(cond bool, numArg int, extraMsg string) {
 		L.LArgError(numArg, extraMsg)
 	}
 }
+
+// =======================================================
+// Generic Buffer manipulation
+// =======================================================
+
+// LBuffer
+// 对应C结构体：`struct luaL_Buffer'
+// 与C不同，内容累积在Go的切片中而不是栈上，因此使用期间栈不必保持平衡
+type LBuffer struct {
+	b []byte
+	L *LuaState
+}
+
+// LBuffInit
+// 对应C函数：`LUALIB_API void luaL_buffinit (lua_State *L, luaL_Buffer *B)'
+func (L *LuaState) LBuffInit(B *LBuffer) {
+	B.L = L
+	B.b = B.b[:0]
+}
+
+// 保证缓冲区还能放下n个字节。扩容由这里完成而不交给append，
+// 这样新分配的内存可以先登记，超过内存上限时在分配之前就抛出错误
+func (B *LBuffer) reserve(n int) {
+	var l = len(B.b)
+	if l+n > cap(B.b) {
+		var newCap = 2 * cap(B.b)
+		if newCap < l+n {
+			newCap = l + n
+		}
+		B.realloc(cap(B.b), newCap)
+		var b = make([]byte, l, newCap)
+		copy(b, B.b)
+		B.b = b
+	}
+}
+
+// AddChar
+// 对应C函数：`luaL_addchar(B,c)'
+func (B *LBuffer) AddChar(c byte) {
+	B.reserve(1)
+	B.b = append(B.b, c)
+}
+
+// AddLString
+// 对应C函数：`LUALIB_API void luaL_addlstring (luaL_Buffer *B, const char *s, size_t l)'
+func (B *LBuffer) AddLString(s []byte) {
+	B.reserve(len(s))
+	B.b = append(B.b, s...)
+}
+
+// AddString
+// 对应C函数：`LUALIB_API void luaL_addstring (luaL_Buffer *B, const char *s)'
+func (B *LBuffer) AddString(s string) {
+	B.reserve(len(s))
+	B.b = append(B.b, s...)
+}
+
+// AddValue 将栈顶的值（必须是字符串或数字）加入缓冲区并弹出
+// 对应C函数：`LUALIB_API void luaL_addvalue (luaL_Buffer *B)'
+func (B *LBuffer) AddValue() {
+	var s, _ = B.L.ToLString(-1)
+	B.reserve(len(s))
+	B.b = append(B.b, s...)
+	B.L.Pop(1)
+}
+
+// PushResult
+// 对应C函数：`LUALIB_API void luaL_pushresult (luaL_Buffer *B)'
+func (B *LBuffer) PushResult() {
+	B.L.PushLString(B.b)
+	B.realloc(cap(B.b), 0) /* 缓冲区的内容已经复制到新字符串中 */
+	B.b = nil
+}
+
+// 登记缓冲区的扩容和释放；出错时没有机会调用PushResult，由dRawRunProtected释放
+func (B *LBuffer) realloc(osize int, nsize int) {
+	B.L.mBufRealloc(osize, nsize)
+	B.L.G().lBufBytes += nsize - osize
+}
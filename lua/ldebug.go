@@ -1,7 +1,8 @@
 package golua
 
 import (
-	"log"
+	"fmt"
+	"strings"
 )
 
 // ResetHookCount
@@ -10,57 +11,723 @@ func ResetHookCount(L *LuaState) {
 	L.hookCount = L.baseHootCount
 }
 
+// 对应C函数：`static int currentpc (lua_State *L, CallInfo *ci)'
+func currentpc(L *LuaState, ci *CallInfo) int {
+	if !ci.IsLua() { /* function is not a Lua function? */
+		return -1
+	}
+	if ci == L.CI() {
+		ci.savedPc = L.savedPc
+	}
+	return ci.Func().L().p.pcRel(ci.savedPc)
+}
+
+// 对应C函数：`static int currentline (lua_State *L, CallInfo *ci)'
+func currentline(L *LuaState, ci *CallInfo) int {
+	var pc = currentpc(L, ci)
+	if pc < 0 {
+		return -1 /* only active lua functions have current-line information */
+	}
+	return ci.Func().L().p.getLine(pc)
+}
+
 // DbgRunError
 // 对应C函数：`void luaG_runerror (lua_State *L, const char *fmt, ...)'
 func (L *LuaState) DbgRunError(format string, args ...interface{}) {
-	// todo: gRunError
-	log.Printf(format, args...)
+	L.addInfo(L.oPushVfString([]byte(format), args))
+	L.gErrorMsg()
 }
 
+// 对应C函数：`static void addinfo (lua_State *L, const char *msg)'
+func (L *LuaState) addInfo(msg []byte) {
+	var ci = L.CI()
+	if ci.IsLua() { /* is Lua code? */
+		var line = currentline(L, ci) /* add file:line information */
+		var buff = oChunkId(string(ci.Func().L().p.source.Bytes), LUA_IDSIZE)
+		L.oPushFString("%s:%d: %s", buff, line, msg)
+	}
+}
+
+// 对应C函数：`void luaG_concaterror (lua_State *L, StkId p1, StkId p2)'
 func (L *LuaState) gConcatError(p1 StkId, p2 StkId) {
-	// todo: gConcatError
-	log.Println("concat error")
+	if p1.IsString() || p1.IsNumber() {
+		p1 = p2
+	}
+	LuaAssert(!p1.IsString() && !p1.IsNumber())
+	L.gTypeError(p1, "concatenate")
 }
 
 // 对应C函数：`void luaG_aritherror (lua_State *L, const TValue *p1, const TValue *p2)'
 func (L *LuaState) gArithError(p1 StkId, p2 StkId) {
-	// todo: gArithError
-	log.Println("arith error")
+	var temp TValue
+	if vToNumber(p1, &temp) == nil {
+		p2 = p1 /* first operand is wrong */
+	}
+	L.gTypeError(p2, "perform arithmetic on")
 }
 
+// 对应C函数：`void luaG_typeerror (lua_State *L, const TValue *o, const char *op)'
 func (L *LuaState) gTypeError(o *TValue, op string) {
-	// todo: gTypeError
-	log.Println("type error")
+	var name string
+	var kind string
+	var t = LuaTTypeNames[o.gcType()]
+	if isInStack(L, L.CI(), o) {
+		kind, name = getObjName(L, L.CI(), adr2idx(L, o)-L.CI().base)
+	}
+	if kind != "" {
+		L.DbgRunError("attempt to %s %s "+LUA_QS+" (a %s value)", op, kind, name, t)
+	} else {
+		L.DbgRunError("attempt to %s a %s value", op, t)
+	}
 }
 
-func gCheckCode(pt *Proto) int {
-	// todo: gCheckCode
-	log.Println("gCheckCode not implemented")
-	return 0
+// 对应C函数：`static int isinstack (CallInfo *ci, const TValue *o)'
+func isInStack(L *LuaState, ci *CallInfo, o *TValue) bool {
+	for p := ci.base; p < ci.top; p++ {
+		if o == &L.stack[p] {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+** {======================================================
+** Symbolic Execution and code checker
+** =======================================================
+ */
+
+// 对应C函数：`static int precheck (const Proto *pt)'
+func (p *Proto) precheck() bool {
+	var sizeCode = len(p.code)
+	switch {
+	case p.maxStackSize > MAXSTACK:
+		return false
+	case p.numParams+int(p.isVarArg&VARARG_HASARG) > p.maxStackSize:
+		return false
+	case p.isVarArg&VARARG_NEEDSARG != 0 && p.isVarArg&VARARG_HASARG == 0:
+		return false
+	case len(p.upValues) > p.nUps:
+		return false
+	case len(p.lineInfo) != sizeCode && len(p.lineInfo) != 0:
+		return false
+	case sizeCode == 0 || p.code[sizeCode-1].GetOpCode() != OP_RETURN:
+		return false
+	}
+	return true
+}
+
+// 对应C函数：`checkreg(pt,reg)'
+func (p *Proto) checkReg(reg int) bool {
+	return reg < p.maxStackSize
+}
+
+// 对应C函数：`checkopenop(pt,pc)'
+func (p *Proto) checkOpenOp(pc int) bool {
+	return gCheckOpenOp(p.code[pc+1])
 }
 
 // 对应C函数：`int luaG_checkopenop (Instruction i)'
 func gCheckOpenOp(i Instruction) bool {
-	// todo：gCheckOpenOp
-	log.Println("gCheckOpenOp not implemented")
+	switch i.GetOpCode() {
+	case OP_CALL, OP_TAILCALL, OP_RETURN, OP_SETLIST:
+		return i.GetArgB() == 0
+	default:
+		return false /* invalid instruction after an open call */
+	}
+}
+
+// 对应C函数：`static int checkArgMode (const Proto *pt, int r, enum OpArgMask mode)'
+func (p *Proto) checkArgMode(r int, mode OpArgMask) bool {
+	switch mode {
+	case OpArgN:
+		return r == 0
+	case OpArgU:
+	case OpArgR:
+		return p.checkReg(r)
+	case OpArgK:
+		if ISK(r) {
+			return INDEXK(r) < len(p.k)
+		}
+		return r < p.maxStackSize
+	}
 	return true
 }
 
-// 对应C函数：`int luaG_ordererror (lua_State *L, const TValue *p1, const TValue *p2) '
-func (L *LuaState) gOrderError(p1 *TValue, p2 *TValue) bool {
-	// todo：gOrderError
-	log.Println("gOrderError not implemented")
-	return false
+// symbexec 对函数原型进行符号执行，直到lastpc为止。
+// 返回最后一条修改寄存器reg的指令；reg为NO_REG时只做代码检查。
+// 代码不合法时，第二个返回值为false。
+// 对应C函数：`static Instruction symbexec (const Proto *pt, int lastpc, int reg)'
+func (p *Proto) symbexec(lastPc int, reg int) (Instruction, bool) {
+	var sizeCode = len(p.code)
+	var last = sizeCode - 1 /* points to final return (a `neutral' instruction) */
+	if !p.precheck() {
+		return 0, false
+	}
+	for pc := 0; pc < lastPc; pc++ {
+		var i = p.code[pc]
+		var op = i.GetOpCode()
+		var a = i.GetArgA()
+		var b, c int
+		if op >= NUM_OPCODES || !p.checkReg(a) {
+			return 0, false
+		}
+		switch op.getOpMode() {
+		case iABC:
+			b = i.GetArgB()
+			c = i.GetArgC()
+			if !p.checkArgMode(b, getBMode(op)) || !p.checkArgMode(c, getCMode(op)) {
+				return 0, false
+			}
+		case iABx:
+			b = i.GetArgBx()
+			if getBMode(op) == OpArgK && b >= len(p.k) {
+				return 0, false
+			}
+		case iAsBx:
+			b = i.GetArgSBx()
+			if getBMode(op) == OpArgR {
+				var dest = pc + 1 + b
+				if !(0 <= dest && dest < sizeCode) {
+					return 0, false
+				}
+				if dest > 0 {
+					/* check that it does not jump to a setlist count; this
+					   is tricky, because the count from a previous setlist may
+					   have the same value of an invalid setlist; so, we must
+					   go all the way back to the first of them (if any) */
+					var j int
+					for j = 0; j < dest; j++ {
+						var d = p.code[dest-1-j]
+						if !(d.GetOpCode() == OP_SETLIST && d.GetArgC() == 0) {
+							break
+						}
+					}
+					/* if 'j' is even, previous value is not a setlist (even if
+					   it looks like one) */
+					if j&1 != 0 {
+						return 0, false
+					}
+				}
+			}
+		}
+		if op.testAMode() {
+			if a == reg {
+				last = pc /* change register `a' */
+			}
+		}
+		if testTMode(op) {
+			if pc+2 >= sizeCode { /* check skip */
+				return 0, false
+			}
+			if p.code[pc+1].GetOpCode() != OP_JMP {
+				return 0, false
+			}
+		}
+		switch op {
+		case OP_LOADBOOL:
+			if c == 1 { /* does it jump? */
+				if pc+2 >= sizeCode { /* check its jump */
+					return 0, false
+				}
+				if p.code[pc+1].GetOpCode() == OP_SETLIST && p.code[pc+1].GetArgC() == 0 {
+					return 0, false
+				}
+			}
+		case OP_LOADNIL:
+			if a <= reg && reg <= b {
+				last = pc /* set registers from `a' to `b' */
+			}
+		case OP_GETUPVAL, OP_SETUPVAL:
+			if b >= p.nUps {
+				return 0, false
+			}
+		case OP_GETGLOBAL, OP_SETGLOBAL:
+			if !p.k[b].IsString() {
+				return 0, false
+			}
+		case OP_SELF:
+			if !p.checkReg(a + 1) {
+				return 0, false
+			}
+			if reg == a+1 {
+				last = pc
+			}
+		case OP_CONCAT:
+			if b >= c { /* at least two operands */
+				return 0, false
+			}
+		case OP_TFORLOOP:
+			if c < 1 { /* at least one result (control variable) */
+				return 0, false
+			}
+			if !p.checkReg(a + 2 + c) { /* space for results */
+				return 0, false
+			}
+			if reg >= a+2 {
+				last = pc /* affect all regs above its base */
+			}
+		case OP_FORLOOP, OP_FORPREP, OP_JMP:
+			if op != OP_JMP && !p.checkReg(a+3) {
+				return 0, false
+			}
+			var dest = pc + 1 + b
+			/* not full check and jump is forward and do not skip `lastpc'? */
+			if reg != NO_REG && pc < dest && dest <= lastPc {
+				pc += b /* do the jump */
+			}
+		case OP_CALL, OP_TAILCALL:
+			if b != 0 && !p.checkReg(a+b-1) {
+				return 0, false
+			}
+			c-- /* c = num. returns */
+			if c == LUA_MULTRET {
+				if !p.checkOpenOp(pc) {
+					return 0, false
+				}
+			} else if c != 0 && !p.checkReg(a+c-1) {
+				return 0, false
+			}
+			if reg >= a {
+				last = pc /* affect all registers above base */
+			}
+		case OP_RETURN:
+			b-- /* b = num. returns */
+			if b > 0 && !p.checkReg(a+b-1) {
+				return 0, false
+			}
+		case OP_SETLIST:
+			if b > 0 && !p.checkReg(a+b) {
+				return 0, false
+			}
+			if c == 0 {
+				pc++
+				if pc >= sizeCode-1 {
+					return 0, false
+				}
+			}
+		case OP_CLOSURE:
+			if b >= len(p.p) {
+				return 0, false
+			}
+			var nup = p.p[b].nUps
+			if pc+nup >= sizeCode {
+				return 0, false
+			}
+			for j := 1; j <= nup; j++ {
+				var op1 = p.code[pc+j].GetOpCode()
+				if op1 != OP_GETUPVAL && op1 != OP_MOVE {
+					return 0, false
+				}
+			}
+			if reg != NO_REG { /* tracing? */
+				pc += nup /* do not 'execute' these pseudo-instructions */
+			}
+		case OP_VARARG:
+			if p.isVarArg&VARARG_ISVARARG == 0 || p.isVarArg&VARARG_NEEDSARG != 0 {
+				return 0, false
+			}
+			b--
+			if b == LUA_MULTRET && !p.checkOpenOp(pc) {
+				return 0, false
+			}
+			if !p.checkReg(a + b - 1) {
+				return 0, false
+			}
+		}
+	}
+	return p.code[last], true
 }
 
 // 对应C函数：`int luaG_checkcode (const Proto *pt)'
 func (p *Proto) gCheckCode() bool {
-	// todo: gCheckCode
-	log.Println("gCheckCode not implemented")
-	return true
+	var _, ok = p.symbexec(len(p.code), NO_REG)
+	return ok
+}
+
+/* }====================================================== */
+
+// 对应C函数：`static const char *kname (Proto *p, int c)'
+func kname(p *Proto, c int) string {
+	if ISK(c) && p.k[INDEXK(c)].IsString() {
+		return string(p.k[INDEXK(c)].StringValue().GetStr())
+	}
+	return "?"
+}
+
+// getObjName 返回栈上第stackPos个寄存器中对象的种类（"local"、"global"、"field"、
+// "upvalue"或"method"）以及名字；找不到有用的名字时kind为空串。
+// 对应C函数：`static const char *getobjname (lua_State *L, CallInfo *ci, int stackpos, const char **name)'
+func getObjName(L *LuaState, ci *CallInfo, stackPos int) (kind string, name string) {
+	if ci.IsLua() { /* a Lua function? */
+		var p = ci.Func().L().p
+		var pc = currentpc(L, ci)
+		if name = p.fGetLocalName(stackPos+1, pc); name != "" { /* is a local? */
+			return "local", name
+		}
+		var i, ok = p.symbexec(pc, stackPos) /* try symbolic execution */
+		LuaAssert(pc != -1)
+		if !ok {
+			return "", ""
+		}
+		switch i.GetOpCode() {
+		case OP_GETGLOBAL:
+			var g = i.GetArgBx() /* global index */
+			LuaAssert(p.k[g].IsString())
+			return "global", string(p.k[g].StringValue().GetStr())
+		case OP_MOVE:
+			var a = i.GetArgA()
+			var b = i.GetArgB() /* move from `b' to `a' */
+			if b < a {
+				return getObjName(L, ci, b) /* get name for `b' */
+			}
+		case OP_GETTABLE:
+			var k = i.GetArgC() /* key index */
+			return "field", kname(p, k)
+		case OP_GETUPVAL:
+			var u = i.GetArgB() /* upvalue index */
+			if u < len(p.upValues) {
+				return "upvalue", string(p.upValues[u].GetStr())
+			}
+			return "upvalue", "?"
+		case OP_SELF:
+			var k = i.GetArgC() /* key index */
+			return "method", kname(p, k)
+		}
+	}
+	return "", "" /* no useful name found */
+}
+
+// getFuncName 返回第ci层调用的函数在调用者中的种类和名字。
+// 对应C函数：`static const char *getfuncname (lua_State *L, CallInfo *ci, const char **name)'
+func getFuncName(L *LuaState, ci int) (kind string, name string) {
+	if (L.baseCi[ci].IsLua() && L.baseCi[ci].tailCalls > 0) || ci == 0 || !L.baseCi[ci-1].IsLua() {
+		return "", "" /* calling function is not Lua (or is unknown) */
+	}
+	var caller = &L.baseCi[ci-1] /* calling function */
+	var i = caller.Func().L().p.code[currentpc(L, caller)]
+	switch i.GetOpCode() {
+	case OP_CALL, OP_TAILCALL, OP_TFORLOOP:
+		return getObjName(L, caller, i.GetArgA())
+	}
+	return "", "" /* no useful name can be found */
+}
+
+// SetHook
+// 设置调试钩子函数。f为nil或mask为0时关闭钩子。
+// 对应C函数：`LUA_API int lua_sethook (lua_State *L, lua_Hook func, int mask, int count)'
+func (L *LuaState) SetHook(f LuaHook, mask int, count int) int {
+	if f == nil || mask == 0 { /* turn off hooks? */
+		mask = 0
+		f = nil
+	}
+	L.hook = f
+	L.baseHootCount = count
+	ResetHookCount(L)
+	L.hookMask = lu_byte(mask)
+	return 1
+}
+
+// GetHook
+// 对应C函数：`LUA_API lua_Hook lua_gethook (lua_State *L)'
+func (L *LuaState) GetHook() LuaHook {
+	return L.hook
+}
+
+// GetHookMask
+// 对应C函数：`LUA_API int lua_gethookmask (lua_State *L)'
+func (L *LuaState) GetHookMask() int {
+	return int(L.hookMask)
+}
+
+// GetHookCount
+// 对应C函数：`LUA_API int lua_gethookcount (lua_State *L)'
+func (L *LuaState) GetHookCount() int {
+	return L.baseHootCount
+}
+
+// GetStack
+// 取得第level层调用的信息，供GetInfo等函数使用。level为0表示当前正在运行的函数。
+// 对应C函数：`LUA_API int lua_getstack (lua_State *L, int level, lua_Debug *ar)'
+func (L *LuaState) GetStack(level int, ar *LuaDebug) bool {
+	var ci int
+	L.Lock()
+	for ci = L.ci; level > 0 && ci > 0; ci-- {
+		level--
+		if L.baseCi[ci].fIsLua() { /* Lua function? */
+			level -= L.baseCi[ci].tailCalls /* skip lost tail calls */
+		}
+	}
+	var status = true
+	if level == 0 && ci > 0 { /* level found? */
+		ar.iCI = ci
+	} else if level < 0 { /* level is of a lost tail call? */
+		ar.iCI = 0
+	} else {
+		status = false /* no such level */
+	}
+	L.Unlock()
+	return status
+}
+
+// 对应C函数：`static Proto *getluaproto (CallInfo *ci)'
+func getLuaProto(ci *CallInfo) *Proto {
+	if ci.IsLua() {
+		return ci.Func().L().p
+	}
+	return nil
+}
+
+// 对应C函数：`static const char *findlocal (lua_State *L, CallInfo *ci, int n)'
+func findLocal(L *LuaState, ci int, n int) (string, bool) {
+	if fp := getLuaProto(&L.baseCi[ci]); fp != nil {
+		if name := fp.fGetLocalName(n, currentpc(L, &L.baseCi[ci])); name != "" {
+			return name, true /* is a local variable in a Lua function */
+		}
+	}
+	var limit int
+	if ci == L.ci {
+		limit = L.top
+	} else {
+		limit = adr2idx(L, L.baseCi[ci+1].fn)
+	}
+	if limit-L.baseCi[ci].base >= n && n > 0 { /* is 'n' inside 'ci' stack? */
+		return "(*temporary)", true
+	}
+	return "", false
+}
+
+// GetLocal
+// 将GetStack得到的调用中第n个局部变量的值压入栈中，并返回它的名字。
+// 没有这个局部变量时返回false，且不压入任何值。
+// 对应C函数：`LUA_API const char *lua_getlocal (lua_State *L, const lua_Debug *ar, int n)'
+func (L *LuaState) GetLocal(ar *LuaDebug, n int) (string, bool) {
+	var ci = ar.iCI
+	var name, ok = findLocal(L, ci, n)
+	L.Lock()
+	if ok {
+		L.Top().SetObj(L, &L.stack[L.baseCi[ci].base+(n-1)])
+		L.IncrTop()
+	}
+	L.Unlock()
+	return name, ok
+}
+
+// SetLocal
+// 将栈顶的值赋给GetStack得到的调用中第n个局部变量，并返回它的名字。
+// 无论是否存在这个局部变量，栈顶的值都会被弹出。
+// 对应C函数：`LUA_API const char *lua_setlocal (lua_State *L, const lua_Debug *ar, int n)'
+func (L *LuaState) SetLocal(ar *LuaDebug, n int) (string, bool) {
+	var ci = ar.iCI
+	var name, ok = findLocal(L, ci, n)
+	L.Lock()
+	if ok {
+		L.stack[L.baseCi[ci].base+(n-1)].SetObj(L, L.AtTop(-1))
+	}
+	L.top-- /* pop value */
+	L.Unlock()
+	return name, ok
+}
+
+// 对应C函数：`static void funcinfo (lua_Debug *ar, Closure *cl)'
+func funcInfo(ar *LuaDebug, cl Closure) {
+	if cl.IsCFunction() {
+		ar.Source = "=[C]"
+		ar.LineDefined = -1
+		ar.LastLineDefined = -1
+		ar.What = "C"
+	} else {
+		var p = cl.L().p
+		ar.Source = string(p.source.GetStr())
+		ar.LineDefined = p.lineDefined
+		ar.LastLineDefined = p.lastLineDefined
+		if ar.LineDefined == 0 {
+			ar.What = "main"
+		} else {
+			ar.What = "Lua"
+		}
+	}
+	ar.ShortSrc = []byte(oChunkId(ar.Source, LUA_IDSIZE))
+}
+
+// 对应C函数：`static void info_tailcall (lua_Debug *ar)'
+func infoTailCall(ar *LuaDebug) {
+	ar.Name = ""
+	ar.NameWhat = ""
+	ar.What = "tail"
+	ar.LastLineDefined = -1
+	ar.LineDefined = -1
+	ar.CurrentLine = -1
+	ar.Source = "=(tail call)"
+	ar.ShortSrc = []byte(oChunkId(ar.Source, LUA_IDSIZE))
+	ar.NUps = 0
+}
+
+// 对应C函数：`static void collectvalidlines (lua_State *L, Closure *f)'
+func collectValidLines(L *LuaState, f Closure) {
+	if f == nil || f.IsCFunction() {
+		L.Top().SetNil()
+	} else {
+		var t = L.hNew(0, 0)
+		for _, line := range f.L().p.lineInfo {
+			t.SetByNum(L, line).SetBoolean(true)
+		}
+		L.Top().SetTable(L, t)
+	}
+	L.IncTop()
+}
+
+// 对应C函数：`static int auxgetinfo (lua_State *L, const char *what, lua_Debug *ar, Closure *f, CallInfo *ci)'
+func auxGetInfo(L *LuaState, what string, ar *LuaDebug, f Closure, ci int) bool {
+	var status = true
+	if f == nil {
+		infoTailCall(ar)
+		return status
+	}
+	for _, c := range what {
+		switch c {
+		case 'S':
+			funcInfo(ar, f)
+		case 'l':
+			if ci != 0 {
+				ar.CurrentLine = currentline(L, &L.baseCi[ci])
+			} else {
+				ar.CurrentLine = -1
+			}
+		case 'u':
+			ar.NUps = int(f.C().nUpValues)
+		case 'n':
+			ar.NameWhat, ar.Name = "", ""
+			if ci != 0 {
+				ar.NameWhat, ar.Name = getFuncName(L, ci)
+			}
+		case 'L', 'f': /* handled by GetInfo */
+		default:
+			status = false /* invalid option */
+		}
+	}
+	return status
+}
+
+// GetInfo
+// 按what中的选项（'n'、'S'、'l'、'u'、'f'、'L'）填写ar的各个字段。
+// what以'>'开头时，查询的是栈顶的函数（并将其弹出），否则查询GetStack得到的调用。
+// 选项'f'将被查询的函数压入栈中，'L'压入一个以有效行号为键的表。
+// 对应C函数：`LUA_API int lua_getinfo (lua_State *L, const char *what, lua_Debug *ar)'
+func (L *LuaState) GetInfo(what string, ar *LuaDebug) bool {
+	var f Closure
+	var fn TValue /* the function itself, for option 'f' */
+	var ci int
+	L.Lock()
+	if strings.HasPrefix(what, ">") {
+		fn = *L.AtTop(-1)
+		L.apiCheck(fn.IsFunction())
+		what = what[1:] /* skip the '>' */
+		f = fn.ClosureValue()
+		L.top-- /* pop function */
+	} else if ar.iCI != 0 { /* no tail call? */
+		ci = ar.iCI
+		LuaAssert(L.baseCi[ci].fn.IsFunction())
+		fn = *L.baseCi[ci].fn
+		f = fn.ClosureValue()
+	}
+	var status = auxGetInfo(L, what, ar, f, ci)
+	if strings.ContainsRune(what, 'f') {
+		if f == nil {
+			L.Top().SetNil()
+		} else {
+			L.Top().SetObj(L, &fn)
+		}
+		L.IncTop()
+	}
+	if strings.ContainsRune(what, 'L') {
+		collectValidLines(L, f)
+	}
+	L.Unlock()
+	return status
+}
+
+const (
+	levels1 = 12 /* size of the first part of the stack */
+	levels2 = 10 /* size of the second part of the stack */
+)
+
+// Traceback
+// 返回L1从第level层开始的调用栈列表（"stack traceback:"），msg不为空时放在列表之前。
+// 调用层数过多时，中间的部分以"..."省略。
+// 对应C函数：`static int db_errorfb (lua_State *L)'
+func (L *LuaState) Traceback(L1 *LuaState, msg string, level int) string {
+	var b strings.Builder
+	var ar LuaDebug
+	var firstPart = true /* still before eventual `...' */
+	if msg != "" {
+		b.WriteString(msg)
+		b.WriteString("\n")
+	}
+	b.WriteString("stack traceback:")
+	for L1.GetStack(level, &ar) {
+		level++
+		if level > levels1 && firstPart {
+			/* no more than `levels2' more levels? */
+			if !L1.GetStack(level+levels2, &ar) {
+				level-- /* keep going */
+			} else {
+				b.WriteString("\n\t...")              /* too many levels */
+				for L1.GetStack(level+levels2, &ar) { /* find last levels */
+					level++
+				}
+			}
+			firstPart = false
+			continue
+		}
+		b.WriteString("\n\t")
+		L1.GetInfo("Snl", &ar)
+		fmt.Fprintf(&b, "%s:", ar.ShortSrc)
+		if ar.CurrentLine > 0 {
+			fmt.Fprintf(&b, "%d:", ar.CurrentLine)
+		}
+		if ar.NameWhat != "" { /* is there a name? */
+			fmt.Fprintf(&b, " in function "+LUA_QS, ar.Name)
+		} else if ar.What == "main" {
+			b.WriteString(" in main chunk")
+		} else if ar.What == "C" || ar.What == "tail" {
+			b.WriteString(" ?") /* C function or tail call */
+		} else {
+			fmt.Fprintf(&b, " in function <%s:%d>", ar.ShortSrc, ar.LineDefined)
+		}
+	}
+	return b.String()
+}
+
+// 对应C函数：`int luaG_ordererror (lua_State *L, const TValue *p1, const TValue *p2) '
+func (L *LuaState) gOrderError(p1 *TValue, p2 *TValue) bool {
+	var t1 = LuaTTypeNames[p1.gcType()]
+	var t2 = LuaTTypeNames[p2.gcType()]
+	if t1[2] == t2[2] {
+		L.DbgRunError("attempt to compare two %s values", t1)
+	} else {
+		L.DbgRunError("attempt to compare %s with %s", t1, t2)
+	}
+	return false
 }
 
 // 对应C函数：`void luaG_errormsg (lua_State *L)'
 func (L *LuaState) gErrorMsg() {
-	// todo: gErrorMsg
+	L.saveErrorInfo()
+	L.throwErrorMsg()
+}
+
+// 调用错误处理函数（如果有的话），然后抛出栈顶的错误对象；出错位置应已由saveErrorInfo记录
+func (L *LuaState) throwErrorMsg() {
+	if L.errFunc != 0 { /* is there an error handling function? */
+		var errFunc = restorestack(L, L.errFunc)
+		if !errFunc.IsFunction() {
+			L.dThrow(LUA_ERRERR)
+		}
+		SetObj(L, L.Top(), L.AtTop(-1)) /* move argument */
+		SetObj(L, L.AtTop(-1), errFunc) /* push function */
+		L.IncTop()
+		L.dCall(L.AtTop(-2), 1) /* call it */
+	}
+	L.dThrow(LUA_ERRRUN)
 }
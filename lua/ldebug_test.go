@@ -0,0 +1,427 @@
+package golua
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// 在保护模式下运行chunk，返回状态码和栈顶的错误信息
+func runChunk(L *LuaState, chunk string, name string) (int, string) {
+	status := L.LLoadBuffer([]byte(chunk), name)
+	if status == 0 {
+		status = L.PCall(0, LUA_MULTRET, 0)
+	}
+	if status != 0 {
+		msg := L.ToString(-1)
+		L.Pop(1)
+		return status, msg
+	}
+	return status, ""
+}
+
+func TestLuaState_DbgRunError(t *testing.T) {
+	tests := []struct {
+		name  string
+		chunk string
+		want  string
+	}{
+		{"arith", "local x\nreturn x + 1", "test:2: attempt to perform arithmetic on local 'x' (a nil value)"},
+		{"arith rc", "local x = {}\nreturn 1 + x", "test:2: attempt to perform arithmetic on local 'x' (a table value)"},
+		{"arith global", "return 2 * g", "test:1: attempt to perform arithmetic on global 'g' (a nil value)"},
+		{"concat", "local t = {}\nlocal s = 'a' .. t", "test:2: attempt to concatenate local 't' (a table value)"},
+		{"compare", "local a, b = {}, {}\nreturn a < b", "test:2: attempt to compare two table values"},
+		{"compare mixed", "local a, b = 1, 'x'\nreturn a < b", "test:2: attempt to compare number with string"},
+		{"index", "local t\n\nreturn t.x", "test:3: attempt to index local 't' (a nil value)"},
+		{"index field", "local cfg = {}\nreturn cfg.window.width", "test:2: attempt to index field 'window' (a nil value)"},
+		{"index upvalue", "local u\nlocal function f() return u.x end\nf()", "test:2: attempt to index upvalue 'u' (a nil value)"},
+		{"newindex", "local t = true\nt.x = 1", "test:2: attempt to index local 't' (a boolean value)"},
+		{"call", "local f = 1\nf()", "test:2: attempt to call local 'f' (a number value)"},
+		{"call global", "foo()", "test:1: attempt to call global 'foo' (a nil value)"},
+		{"call method", "local obj = {}\nobj:run()", "test:2: attempt to call method 'run' (a nil value)"},
+		{"call anonymous", "return (1)()", "test:1: attempt to call a number value"},
+		{"len", "local n = 1\nreturn #n", "test:2: attempt to get length of local 'n' (a number value)"},
+		{"for", "for i = 1, {} do end", "test:1: 'for' limit must be a number"},
+		{"nil index", "local t = {}\nt[nil] = 1", "test:2: table index is nil"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			L := LuaOpen()
+			defer L.Close()
+			status, msg := runChunk(L, tt.chunk, "=test")
+			if status != LUA_ERRRUN {
+				t.Fatalf("want status %d got %d (%s)", LUA_ERRRUN, status, msg)
+			}
+			if msg != tt.want {
+				t.Errorf("want %q got %q", tt.want, msg)
+			}
+			if L.GetTop() != 0 {
+				t.Errorf("stack not balanced: %d", L.GetTop())
+			}
+		})
+	}
+}
+
+func TestLuaState_DbgRunErrorRecover(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	if status, _ := runChunk(L, "local x = nil + 1", "=test"); status != LUA_ERRRUN {
+		t.Fatalf("want status %d got %d", LUA_ERRRUN, status)
+	}
+	/* the state must still be usable after an error */
+	if status, msg := runChunk(L, "local x = 1 + 2", "=test"); status != 0 {
+		t.Errorf("want status 0 got %d (%s)", status, msg)
+	}
+}
+
+func Test_oChunkId(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"=stdin", "stdin"},
+		{"@hello.lua", "hello.lua"},
+		{"return 1", `[string "return 1"]`},
+		{"local a = 1\nreturn a", `[string "local a = 1..."]`},
+	}
+	for _, tt := range tests {
+		if got := oChunkId(tt.source, LUA_IDSIZE); got != tt.want {
+			t.Errorf("oChunkId(%q) = %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestLuaState_ErrFunc(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	var trace string
+	L.PushCFunction(func(L *LuaState) int {
+		trace = L.Traceback(L, "", 0) /* the failing frame is still live */
+		L.PushString("handled: " + L.ToString(1))
+		return 1
+	})
+	L.LLoadBuffer([]byte("local function f(t)\n  return t.x\nend\nf()"), "=test")
+	if status := L.PCall(0, 0, 1); status != LUA_ERRRUN {
+		t.Fatalf("want status %d got %d", LUA_ERRRUN, status)
+	}
+	if msg := L.ToString(-1); msg != "handled: test:2: attempt to index local 't' (a nil value)" {
+		t.Errorf("unexpected message %q", msg)
+	}
+	if !strings.Contains(trace, "test:2: in function 'f'") {
+		t.Errorf("unexpected traceback %q", trace)
+	}
+	if L.GetTop() != 2 {
+		t.Errorf("want handler and message on the stack, top = %d", L.GetTop())
+	}
+}
+
+func TestLuaState_ErrFunc_ErrErr(t *testing.T) {
+	handlers := map[string]func(L *LuaState){
+		"erroring handler": func(L *LuaState) {
+			L.PushCFunction(func(L *LuaState) int {
+				L.PushString("oops")
+				return L.Error()
+			})
+		},
+		"not a function": func(L *LuaState) {
+			L.PushBoolean(true)
+		},
+	}
+	for name, push := range handlers {
+		t.Run(name, func(t *testing.T) {
+			L := LuaOpen()
+			defer L.Close()
+			push(L)
+			L.LLoadBuffer([]byte("error_here()"), "=test")
+			err := L.PCallErr(0, 0, 1)
+			if !errors.Is(err, ErrErr) {
+				t.Fatalf("want ErrErr got %v", err)
+			}
+			if err.Error() != "error in error handling" {
+				t.Errorf("unexpected message %q", err.Error())
+			}
+			if err := L.LDoStringErr("local x = 1"); err != nil {
+				t.Errorf("state not usable after LUA_ERRERR: %v", err)
+			}
+		})
+	}
+}
+
+func TestLuaState_Traceback(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	var traces []string
+	L.Register("capture", func(L *LuaState) int {
+		traces = append(traces, L.Traceback(L, "msg", 1))
+		return 0
+	})
+	chunk := "local function rec(n)\n  if n == 0 then capture() else rec(n - 1) end\nend\nrec(30)\n" +
+		"local function g() capture() end\nlocal function tail() return g() end\ntail()\n" +
+		"local t = {f = function() capture() end}\nt.f()"
+	if status, msg := runChunk(L, chunk, "=test"); status != 0 {
+		t.Fatalf("unexpected error %s", msg)
+	}
+	if len(traces) != 3 {
+		t.Fatalf("want 3 tracebacks got %d", len(traces))
+	}
+
+	/* deep stacks keep the first levels1-1 and the last levels2 levels */
+	lines := strings.Split(traces[0], "\n")
+	if lines[0] != "msg" || lines[1] != "stack traceback:" {
+		t.Errorf("unexpected header %q", lines[:2])
+	}
+	if len(lines) != 2+(levels1-1)+1+levels2 {
+		t.Errorf("unexpected number of lines %d:\n%s", len(lines), traces[0])
+	}
+	if lines[2] != "\ttest:2: in function 'rec'" || lines[2+levels1-1] != "\t..." ||
+		lines[len(lines)-1] != "\ttest:4: in main chunk" {
+		t.Errorf("unexpected traceback:\n%s", traces[0])
+	}
+
+	want := "msg\nstack traceback:\n\ttest:5: in function <test:5>\n\t(tail call): ?\n\ttest:7: in main chunk"
+	if traces[1] != want {
+		t.Errorf("want\n%s\ngot\n%s", want, traces[1])
+	}
+	want = "msg\nstack traceback:\n\ttest:8: in function 'f'\n\ttest:9: in main chunk"
+	if traces[2] != want {
+		t.Errorf("want\n%s\ngot\n%s", want, traces[2])
+	}
+}
+
+func TestLuaState_SetHook(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	var events []string
+	hook := func(L *LuaState, ar *LuaDebug) {
+		switch ar.Event {
+		case LUA_HOOKLINE:
+			events = append(events, fmt.Sprintf("line %d", ar.CurrentLine))
+		case LUA_HOOKCALL, LUA_HOOKRET:
+			L.GetInfo("nS", ar)
+			name := map[int]string{LUA_HOOKCALL: "call", LUA_HOOKRET: "return"}[ar.Event]
+			events = append(events, fmt.Sprintf("%s %s %s", name, ar.What, ar.Name))
+		case LUA_HOOKTAILRET:
+			events = append(events, "tail return")
+		}
+	}
+	L.SetHook(hook, LUA_MASKCALL|LUA_MASKRET|LUA_MASKLINE, 0)
+	if L.GetHook() == nil || L.GetHookMask() != LUA_MASKCALL|LUA_MASKRET|LUA_MASKLINE {
+		t.Errorf("hook not installed")
+	}
+	L.Register("gofn", func(L *LuaState) int { return 0 })
+	chunk := "local function f()\n  gofn()\nend\n" +
+		"local function g() return f() end\n" +
+		"for i = 1, 2 do\n  local x = i\nend\ng()"
+	status, msg := runChunk(L, chunk, "=test")
+	L.SetHook(nil, 0, 0)
+	if status != 0 {
+		t.Fatalf("unexpected error %s", msg)
+	}
+	want := []string{
+		"call main ",
+		"line 3", "line 4", "line 5", "line 6", "line 5", "line 6", "line 5", "line 8",
+		"call Lua g",
+		"line 4",
+		"call Lua f",
+		"line 2",
+		"call C gofn",
+		"return C gofn",
+		"line 3",
+		"return Lua ", /* f replaced g by a tail call, so it has no name */
+		"tail return",
+		"return main ",
+	}
+	if strings.Join(events, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(events, "\n"))
+	}
+	if L.GetHook() != nil || L.GetHookMask() != 0 {
+		t.Errorf("hook not removed")
+	}
+}
+
+func TestLuaState_SetHook_Count(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	var count int
+	L.SetHook(func(L *LuaState, ar *LuaDebug) {
+		if ar.Event != LUA_HOOKCOUNT {
+			t.Errorf("unexpected event %d", ar.Event)
+		}
+		count++
+	}, LUA_MASKCOUNT, 10)
+	if L.GetHookCount() != 10 {
+		t.Errorf("want count 10 got %d", L.GetHookCount())
+	}
+	if status, msg := runChunk(L, "local n = 0\nfor i = 1, 100 do n = n + i end", "=test"); status != 0 {
+		t.Fatalf("unexpected error %s", msg)
+	}
+	/* LOADK, LOADK, LOADK, LOADK, FORPREP, 100 * (ADD, FORLOOP), RETURN */
+	if count != 206/10 {
+		t.Errorf("want %d count events got %d", 206/10, count)
+	}
+}
+
+func TestLuaState_GetInfo(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	var ar LuaDebug
+	L.Register("inspect", func(L *LuaState) int {
+		if !L.GetStack(0, &ar) || !L.GetInfo("nSlu", &ar) {
+			t.Errorf("no info for level 0")
+		}
+		if ar.What != "C" || ar.Name != "inspect" || ar.NameWhat != "global" ||
+			ar.CurrentLine != -1 || string(ar.ShortSrc) != "[C]" || ar.LineDefined != -1 {
+			t.Errorf("unexpected info for level 0 %+v", ar)
+		}
+		if !L.GetStack(1, &ar) || !L.GetInfo("nSluf", &ar) {
+			t.Errorf("no info for level 1")
+		}
+		if ar.What != "Lua" || ar.Name != "f" || ar.NameWhat != "local" || ar.CurrentLine != 3 ||
+			ar.Source != "=test" || string(ar.ShortSrc) != "test" || ar.NUps != 1 ||
+			ar.LineDefined != 2 || ar.LastLineDefined != 4 {
+			t.Errorf("unexpected info for level 1 %+v", ar)
+		}
+		if !L.IsFunction(-1) {
+			t.Errorf("option 'f' must push the function")
+		}
+		if L.GetStack(3, &ar) {
+			t.Errorf("level 3 must not exist")
+		}
+		return 0
+	})
+	chunk := "local up = 1\nlocal function f()\n  inspect(up)\nend\nf()"
+	if status, msg := runChunk(L, chunk, "=test"); status != 0 {
+		t.Fatalf("unexpected error %s", msg)
+	}
+
+	/* option '>' inspects the function on the top of the stack */
+	L.LLoadBuffer([]byte("local a\n\nreturn a"), "=chunk")
+	L.GetInfo(">SL", &ar)
+	if ar.What != "main" || string(ar.ShortSrc) != "chunk" || !L.IsTable(-1) {
+		t.Errorf("unexpected info %+v", ar)
+	}
+	L.RawGetI(-1, 3)
+	if !L.ToBoolean(-1) {
+		t.Errorf("line 3 must be a valid line")
+	}
+	L.RawGetI(-2, 2)
+	if L.ToBoolean(-1) {
+		t.Errorf("line 2 must not be a valid line")
+	}
+	if L.GetInfo("x", &ar) {
+		t.Errorf("invalid option must fail")
+	}
+}
+
+func TestLuaState_LArgError(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	L.Register("need", func(L *LuaState) int {
+		L.LCheckInteger(2)
+		return 0
+	})
+	L.Register("tbl", func(L *LuaState) int {
+		L.LCheckType(1, LUA_TTABLE)
+		return 0
+	})
+	L.Register("num", func(L *LuaState) int {
+		L.LCheckInteger(1)
+		return 0
+	})
+	tests := []struct {
+		chunk string
+		want  string
+	}{
+		{"need(1, 'x')", "test:1: bad argument #2 to 'need' (number expected, got string)"},
+		{"need(1)", "test:1: bad argument #2 to 'need' (number expected, got no value)"},
+		{"tbl(1)", "test:1: bad argument #1 to 'tbl' (table expected, got number)"},
+		{"local o = {m = function(self) return tbl(self.x) end}\no:m()",
+			"test:1: bad argument #1 to 'tbl' (table expected, got nil)"},
+		{"local o = {m = num}\no:m()", "test:2: calling 'm' on bad self (number expected, got table)"},
+		{"local o = {m = need}\no:m('x')", "test:2: bad argument #1 to 'm' (number expected, got string)"},
+	}
+	for _, tt := range tests {
+		if _, msg := runChunk(L, tt.chunk, "=test"); msg != tt.want {
+			t.Errorf("want %q got %q", tt.want, msg)
+		}
+	}
+}
+
+func TestLuaState_GetLocal(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	var locals []string
+	L.Register("stop", func(L *LuaState) int {
+		var ar LuaDebug
+		if !L.GetStack(1, &ar) {
+			t.Fatalf("no caller")
+		}
+		for n := 1; ; n++ {
+			name, ok := L.GetLocal(&ar, n)
+			if !ok {
+				break
+			}
+			locals = append(locals, fmt.Sprintf("%s=%s", name, L.ToString(-1)))
+			L.Pop(1)
+			if name == "cfg" {
+				L.PushString("patched")
+				if name, ok := L.SetLocal(&ar, n); !ok || name != "cfg" {
+					t.Errorf("SetLocal failed")
+				}
+			}
+		}
+		if _, ok := L.GetLocal(&ar, 100); ok {
+			t.Errorf("local 100 must not exist")
+		}
+		return 0
+	})
+	chunk := "local function f(a, b)\n  local cfg = 'old'\n  do local hidden = 1 end\n  stop()\n  return cfg\nend\n" +
+		"return f(1, 2)"
+	L.LLoadBuffer([]byte(chunk), "=test")
+	if err := L.PCallErr(0, 1, 0); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got := L.ToString(-1); got != "patched" {
+		t.Errorf("SetLocal had no effect, f returned %q", got)
+	}
+	want := "a=1 b=2 cfg=old"
+	if got := strings.Join(locals, " "); got != want {
+		t.Errorf("want %q got %q", want, got)
+	}
+}
+
+func TestLuaState_GetUpvalue(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	L.LLoadBuffer([]byte("local limit, name = 10, 'x'\nreturn function() return limit end"), "=test")
+	L.Call(0, 1)
+	if name, ok := L.GetUpvalue(-1, 1); !ok || name != "limit" || L.ToString(-1) != "10" {
+		t.Errorf("unexpected upvalue %q %v %q", name, ok, L.ToString(-1))
+	}
+	L.Pop(1)
+	if _, ok := L.GetUpvalue(-1, 2); ok {
+		t.Errorf("upvalue 2 must not exist")
+	}
+	L.PushString("20")
+	if name, ok := L.SetUpvalue(-2, 1); !ok || name != "limit" {
+		t.Errorf("SetUpvalue failed")
+	}
+	L.PushString("30")
+	if _, ok := L.SetUpvalue(-2, 2); ok || L.GetTop() != 2 {
+		t.Errorf("SetUpvalue of a missing upvalue must leave the stack alone")
+	}
+	L.Pop(1)
+	L.Call(0, 1)
+	if got := L.ToString(-1); got != "20" {
+		t.Errorf("want patched upvalue 20 got %q", got)
+	}
+	L.Pop(1)
+
+	/* C functions have unnamed upvalues */
+	L.PushString("u")
+	L.PushCClosure(func(L *LuaState) int { return 0 }, 1)
+	if name, ok := L.GetUpvalue(-1, 1); !ok || name != "" || L.ToString(-1) != "u" {
+		t.Errorf("unexpected C upvalue %q %v", name, ok)
+	}
+}
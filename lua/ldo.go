@@ -1,7 +1,9 @@
 package golua
 
 import (
+	"fmt"
 	"luar/lua/mem"
+	"runtime/debug"
 	"unsafe"
 )
 
@@ -38,6 +40,7 @@ func (L *LuaState) dPCall(fun PFunc, u interface{}, oldTopIdx int, ef int) int {
 		L.base = L.CI().base
 		L.savedPc = L.CI().savedPc
 		L.allowHook = oldAllowHooks
+		restoreStackLimit(L)
 	}
 	L.errFunc = oldErrFunc
 	return status
@@ -49,14 +52,15 @@ func (L *LuaState) dRawRunProtected(f PFunc, ud interface{}) (status int) {
 	lj.status = 0
 	lj.previous = L.errorJmp /* chain new error handler */
 	L.errorJmp = &lj
+	var oldLBufBytes = L.G().lBufBytes
 	defer func() {
 		if err := recover(); err != nil {
-			if L.errorJmp == &lj { /* 这里有必要吗？*/
-				L.errorJmp = lj.previous
-				status = lj.status
-			} else {
+			if err != interface{}(&lj) { /* not thrown by `dThrow' to this level? */
 				panic(err)
 			}
+			L.errorJmp = lj.previous
+			L.mFreeLBuffers(oldLBufBytes) /* buffers of the unwound Go functions are gone */
+			status = lj.status
 		}
 	}()
 	f(L, ud)
@@ -115,7 +119,7 @@ func (L *LuaState) dProtectedParser(z *ZIO, name []byte) int {
 	p.name = name
 	p.buff.Init() /* 在go语言中基实不必做这一步的初始化 */
 	status := L.dPCall(parser, &p, L.top, L.errFunc)
-	p.buff.Free()
+	p.buff.Free(L)
 	return status
 }
 
@@ -208,13 +212,46 @@ func (L *LuaState) dCall(fn StkId, nResults int) {
 
 // 对应C函数：`void luaD_throw (lua_State *L, int errcode)'
 func (L *LuaState) dThrow(errCode int) {
+	if !L.errInfo.fresh { /* 这次错误没有记录位置，不能沿用上一个错误的 */
+		L.errInfo = errorInfo{}
+	}
+	L.errInfo.fresh = false
 	if L.errorJmp != nil {
 		L.errorJmp.status = errCode
-		panic(errCode)
-		// LUAI_THROW(L, L->errorJmp);
+		panic(L.errorJmp) /* LUAI_THROW(L, L->errorJmp) */
 	} else {
 		L.status = lu_byte(errCode)
-		// todo: dThrow (还没有完全实现)
+		resetStack(L, errCode)
+		if L.G().panic != nil {
+			L.Unlock()
+			L.G().panic(L)
+		}
+		/* C在这里调用exit(EXIT_FAILURE)；作为嵌入Go程序的库，改为以*LuaError为值panic，宿主可以recover */
+		panic(L.newLuaError(errCode))
+	}
+}
+
+// 对应C函数：`static void resetstack (lua_State *L, int status)'
+func resetStack(L *LuaState, status int) {
+	L.ci = 0
+	L.base = L.CI().base
+	L.fClose(&L.stack[L.base]) /* close eventual pending closures */
+	L.dSetErrorObj(status, L.base)
+	L.nCCalls = L.baseCCalls
+	L.allowHook = 1
+	restoreStackLimit(L)
+	L.errFunc = 0
+	L.errorJmp = nil
+}
+
+// 对应C函数：`static void restore_stack_limit (lua_State *L)'
+func restoreStackLimit(L *LuaState) {
+	LuaAssert(L.stackLast == L.stackSize-EXTRA_STACK-1)
+	if L.sizeCi > LUAI_MAXCALLS { /* there was an overflow? */
+		var inuse = L.ci
+		if inuse+1 < LUAI_MAXCALLS { /* can `undo' overflow? */
+			L.dReallocCI(LUAI_MAXCALLS)
+		}
 	}
 }
 
@@ -276,7 +313,7 @@ func (L *LuaState) dPrecall(fn StkId, nResults int) int {
 			L.dCallHook(LUA_HOOKCALL, -1)
 		}
 		L.Unlock()
-		n := L.CurrFunc().C().f(L) /* do the actual call */
+		n := L.callGoFunction(L.CurrFunc().C().f) /* do the actual call */
 		L.Lock()
 		if n < 0 { /* yielding? */
 			return PCRYIELD
@@ -287,6 +324,117 @@ func (L *LuaState) dPrecall(fn StkId, nResults int) int {
 	}
 }
 
+// callGoFunction 调用一个LuaCFunction。函数中发生的Go panic（而不是Lua错误）
+// 会被转换成Lua运行时错误，除非调用过SetRethrowGoPanics(true)。
+func (L *LuaState) callGoFunction(f LuaCFunction) int {
+	defer func() {
+		if L.G().rethrowGoPanics {
+			return /* don't recover: crash with the original Go stack */
+		}
+		if r := recover(); r != nil {
+			if _, ok := r.(*LuaLongJmp); ok {
+				panic(r) /* a Lua error; keep unwinding to its recover point */
+			}
+			if _, ok := r.(*LuaError); ok {
+				panic(r) /* an unprotected Lua error on its way to the host */
+			}
+			L.goPanicError(r, debug.Stack())
+		}
+	}()
+	return f(L)
+}
+
+// 将Go panic的值作为错误信息，抛出一个Lua运行时错误
+func (L *LuaState) goPanicError(v interface{}, stack []byte) {
+	L.Lock()
+	L.dCheckStack(1)
+	L.oPushFString("%s", fmt.Sprint(v))
+	L.saveErrorInfo()
+	L.errInfo.goPanic = v
+	L.errInfo.goStack = string(stack)
+	L.throwErrorMsg()
+}
+
+// 对应C函数：`static void resume (lua_State *L, void *ud)'
+func resume(L *LuaState, ud interface{}) {
+	var firstArg = ud.(int)
+	var ci = L.CI()
+	if L.status == 0 { /* start coroutine? */
+		LuaAssert(L.ci == 0 && firstArg > L.base)
+		if L.dPrecall(&L.stack[firstArg-1], LUA_MULTRET) != PCRLUA {
+			return
+		}
+	} else { /* resuming from previous yield */
+		LuaAssert(L.status == LUA_YIELD)
+		L.status = 0
+		if !ci.fIsLua() { /* `common' yield? */
+			/* finish interrupted execution of `OP_CALL' */
+			LuaAssert(L.baseCi[L.ci-1].savedPc.Ptr(-1).GetOpCode() == OP_CALL ||
+				L.baseCi[L.ci-1].savedPc.Ptr(-1).GetOpCode() == OP_TAILCALL)
+			if L.dPoscall(firstArg) != 0 { /* complete it... */
+				L.top = L.CI().top /* and correct top if not multiple results */
+			}
+		} else { /* yielded inside a hook: just continue its execution */
+			L.base = L.CI().base
+		}
+	}
+	L.vExecute(L.ci)
+}
+
+// 对应C函数：`static int resume_error (lua_State *L, const char *msg)'
+func resumeError(L *LuaState, msg string) int {
+	L.top = L.CI().base
+	L.Top().SetString(L, L.sNew([]byte(msg)))
+	L.IncTop()
+	L.Unlock()
+	return LUA_ERRRUN
+}
+
+// Resume
+// 对应C函数：`LUA_API int lua_resume (lua_State *L, int narg)'
+// 返回0表示协程执行完毕，LUA_YIELD表示协程挂起，其它值表示出错，此时错误对象位于栈顶
+func (L *LuaState) Resume(nArgs int) int {
+	var status int
+	L.Lock()
+	if L.status != LUA_YIELD && (L.status != 0 || L.ci != 0) {
+		return resumeError(L, "cannot resume non-suspended coroutine")
+	}
+	if L.nCCalls >= LUAI_MAXCCALLS {
+		return resumeError(L, "C stack overflow")
+	}
+	LUAIUserStateResume(L)
+	LuaAssert(L.errFunc == 0)
+	L.nCCalls++
+	L.baseCCalls = L.nCCalls
+	status = L.dRawRunProtected(resume, L.top-nArgs)
+	if status != 0 { /* error? */
+		L.status = lu_byte(status) /* mark thread as `dead' */
+		L.dSetErrorObj(status, L.top)
+		L.CI().top = L.top
+	} else {
+		LuaAssert(L.nCCalls == L.baseCCalls)
+		status = int(L.status)
+	}
+	L.nCCalls--
+	L.Unlock()
+	return status
+}
+
+// Yield
+// 对应C函数：`LUA_API int lua_yield (lua_State *L, int nresults)'
+// LuaCFunction应当以`return L.Yield(n)'的形式调用它
+func (L *LuaState) Yield(nResults int) int {
+	LUAIUserStateYield(L)
+	L.Lock()
+	if L.nCCalls > L.baseCCalls {
+		L.DbgRunError("attempt to yield across metamethod/C-call boundary")
+	}
+	L.base = L.top - nResults /* protect stack slots below */
+	L.status = LUA_YIELD
+	L.Unlock()
+	return -1
+}
+
 // 对应C函数：`static StkId tryfuncTM (lua_State *L, StkId func)'
 func tryFuncTM(L *LuaState, fn StkId) StkId {
 	tm := L.tGetTMByObj(fn, TM_CALL)
@@ -306,8 +454,30 @@ func tryFuncTM(L *LuaState, fn StkId) StkId {
 
 // 对应C函数：`void luaD_callhook (lua_State *L, int event, int line)'
 func (L *LuaState) dCallHook(event int, line int) {
-	// todo: dCallHook
-	panic("not implemented")
+	var hook = L.hook
+	if hook != nil && L.allowHook != 0 {
+		var top = L.top
+		var ciTop = L.CI().top
+		var ar LuaDebug
+		ar.Event = event
+		ar.CurrentLine = line
+		if event == LUA_HOOKTAILRET {
+			ar.iCI = 0 /* tail call; no debug information about it */
+		} else {
+			ar.iCI = L.ci
+		}
+		L.dCheckStack(LUA_MINSTACK) /* ensure minimum stack size */
+		L.CI().top = L.top + LUA_MINSTACK
+		LuaAssert(L.CI().top <= L.stackLast)
+		L.allowHook = 0 /* cannot call hooks inside a hook */
+		L.Unlock()
+		hook(L, &ar)
+		L.Lock()
+		LuaAssert(L.allowHook == 0)
+		L.allowHook = 1
+		L.CI().top = ciTop
+		L.top = top
+	}
 }
 
 // 对应C函数：`static StkId adjust_varargs (lua_State *L, Proto *p, int actual)'
@@ -366,11 +536,11 @@ func (L *LuaState) incCI() *CallInfo {
 // 这种static的C函数，只有一个地方被调用，可以放到被调用的地方，写成一个匿名函数。
 // 对应C函数：`static CallInfo *growCI (lua_State *L)'
 func growCI(L *LuaState) int {
-	if L.sizeCi > LUAI_MAXCCALLS { /* overflow while handling overflow? */
+	if L.sizeCi > LUAI_MAXCALLS { /* overflow while handling overflow? */
 		L.dThrow(LUA_ERRERR)
 	} else {
 		L.dReallocCI(2 * L.sizeCi)
-		if L.sizeCi > LUAI_MAXCCALLS {
+		if L.sizeCi > LUAI_MAXCALLS {
 			L.DbgRunError("stack overflow")
 		}
 	}
@@ -381,6 +551,7 @@ func growCI(L *LuaState) int {
 // 对应C函数：`void luaD_reallocCI (lua_State *L, int newsize)'
 func (L *LuaState) dReallocCI(newSize int) {
 	oldci := L.baseCi
+	L.MRealloc(len(oldci)*int(unsafe.Sizeof(CallInfo{})), newSize*int(unsafe.Sizeof(CallInfo{})))
 	L.baseCi = make([]CallInfo, newSize)
 	copy(L.baseCi, oldci)
 	L.sizeCi = newSize
@@ -1,6 +1,10 @@
 package golua
 
-import "testing"
+import (
+	"errors"
+	"strings"
+	"testing"
+)
 
 func TestLuaState_parser(t *testing.T) {
 	if LUA_SIGNATURE[0] != byte(27) {
@@ -14,14 +18,176 @@ func TestLuaState_parser(t *testing.T) {
 }
 
 func TestLuaState_dRawRunProtected(t *testing.T) {
-	L := &LuaState{}
+	L := LuaOpen()
+	defer L.Close()
 	status := L.dRawRunProtected(func(L *LuaState, ud interface{}) {
-		L.errorJmp.status = 100
+		L.dThrow(LUA_ERRRUN)
+	}, nil)
+	if status != LUA_ERRRUN {
+		t.Errorf("want %d got %v", LUA_ERRRUN, status)
+	}
+	if L.errorJmp != nil {
+		t.Errorf("error handler not restored")
+	}
+
+	/* panics that were not raised by dThrow are not swallowed */
+	defer func() {
+		if r := recover(); r != "panic in func" {
+			t.Errorf("want the original panic got %v", r)
+		}
+	}()
+	L.dRawRunProtected(func(L *LuaState, ud interface{}) {
 		panic("panic in func")
-		L.errorJmp.status = 200
 	}, nil)
+	t.Errorf("unreachable")
+}
+
+func TestLuaState_callGoFunction(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	L.Register("crash", func(L *LuaState) int {
+		var m map[string]int
+		m["x"] = 1
+		return 0
+	})
+	status, msg := runChunk(L, "crash()", "=test")
+	if status != LUA_ERRRUN || msg != "assignment to entry in nil map" {
+		t.Errorf("unexpected result %d %q", status, msg)
+	}
+
+	/* a Go panic is catchable from Lua and the state stays usable */
+	L.Register("check", func(L *LuaState) int {
+		if L.PCall(0, 0, 0) != LUA_ERRRUN {
+			t.Errorf("want pcall to fail")
+		}
+		L.PushString("ok")
+		return 1
+	})
+	if status, msg := runChunk(L, "check(crash)", "=test"); status != 0 {
+		t.Errorf("want status 0 got %d (%s)", status, msg)
+	}
+
+	L.SetTop(0)
+	L.LLoadBuffer([]byte("local t = {}\nt[1] = crash()"), "=test")
+	err := L.PCallErr(0, 0, 0)
+	le, ok := err.(*LuaError)
+	if !ok {
+		t.Fatalf("want *LuaError got %T", err)
+	}
+	if _, ok := le.GoPanic.(error); !ok || !strings.Contains(le.GoStack, "goroutine") {
+		t.Errorf("missing Go panic details: %v %q", le.GoPanic, le.GoStack)
+	}
+	if le.Line != 2 || !strings.Contains(le.Traceback, "[C]: in function 'crash'") {
+		t.Errorf("unexpected position %d %q", le.Line, le.Traceback)
+	}
+	if !errors.Is(err, ErrRun) || errors.Unwrap(err) != le.GoPanic {
+		t.Errorf("unexpected error chain for %v", err)
+	}
+}
+
+func TestLuaState_SetRethrowGoPanics(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	L.SetRethrowGoPanics(true)
+	L.Register("crash", func(L *LuaState) int {
+		panic("boom")
+	})
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("want the original panic got %v", r)
+		}
+	}()
+	L.LDoString("crash()")
+	t.Errorf("unreachable")
+}
+
+func TestLuaState_Resume(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	L.Register("yield", func(L *LuaState) int {
+		return L.Yield(L.GetTop())
+	})
+	co := L.NewThread()
+	if co.Status() != 0 || !L.IsThread(-1) {
+		t.Fatalf("unexpected new thread")
+	}
+	/* yields from a nested Lua call and gets resumed with new values */
+	if L.LLoadString("local function f(n) return yield(n * 2) end\n"+
+		"local a = f(...)\nlocal b = f(a)\nreturn 'done', b") != 0 {
+		t.Fatalf("load failed: %s", L.ToString(-1))
+	}
+	L.XMove(co, 1)
+	co.PushInteger(1)
+	var got []string
+	for nArgs := 1; ; nArgs = 1 {
+		status := co.Resume(nArgs)
+		got = append(got, co.ToString(-1))
+		if status != LUA_YIELD {
+			if status != 0 {
+				t.Fatalf("resume failed: %s", co.ToString(-1))
+			}
+			break
+		}
+		if co.Status() != LUA_YIELD {
+			t.Errorf("want LUA_YIELD status got %d", co.Status())
+		}
+		n := co.ToInteger(-1)
+		co.SetTop(0)
+		co.PushInteger(n + 1)
+	}
+	if strings.Join(got, " ") != "2 6 7" || co.ToString(-2) != "done" {
+		t.Errorf("unexpected results %v", got)
+	}
+}
+
+func TestLuaState_Yield_Boundary(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	L.Register("yield", func(L *LuaState) int {
+		return L.Yield(L.GetTop())
+	})
+	L.Register("call", func(L *LuaState) int {
+		L.Call(L.GetTop()-1, 0)
+		return 0
+	})
+	co := L.NewThread()
+	if co.LLoadString("call(yield, 1)") != 0 {
+		t.Fatalf("load failed: %s", co.ToString(-1))
+	}
+	status := co.Resume(0)
+	if status != LUA_ERRRUN ||
+		!strings.HasSuffix(co.ToString(-1), "attempt to yield across metamethod/C-call boundary") {
+		t.Errorf("want boundary error got %d %s", status, co.ToString(-1))
+	}
+	if co.Status() != LUA_ERRRUN {
+		t.Errorf("thread must be dead, status %d", co.Status())
+	}
+	if status := co.Resume(0); status != LUA_ERRRUN ||
+		co.ToString(-1) != "cannot resume non-suspended coroutine" {
+		t.Errorf("want resume error got %d %s", status, co.ToString(-1))
+	}
+}
 
-	if status != 100 {
-		t.Errorf("want 100 got %v", status)
+func TestLuaState_dThrowUnprotected(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	func() {
+		defer func() {
+			e, ok := recover().(*LuaError)
+			if !ok || e.Status != LUA_ERRRUN || e.Message != "boom" {
+				t.Errorf("want a *LuaError with message boom, got %#v", e)
+			}
+		}()
+		L.Register("fail", func(L *LuaState) int {
+			L.PushString("boom")
+			return L.Error()
+		})
+		L.GetGlobal("fail")
+		L.Call(0, 0) /* no protected call: the error goes to the host */
+		t.Errorf("unreachable")
+	}()
+	/* the state can still be used after recovering */
+	if L.GetTop() != 0 || L.LDoString("x = 1") != 0 {
+		t.Errorf("state not usable after an unprotected error: %s", L.ToString(-1))
 	}
 }
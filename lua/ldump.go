@@ -0,0 +1,180 @@
+package golua
+
+import "unsafe"
+
+// 对应C结构体：`struct DumpState'
+type dumpState struct {
+	L      *LuaState
+	writer LuaWriteFunc
+	data   interface{}
+	strip  bool
+	status int
+}
+
+// DumpMem
+// 对应C函数：`DumpMem(b,n,size,D)'
+func (D *dumpState) DumpMem(b unsafe.Pointer, n int, size int) {
+	if n > 0 {
+		D.DumpBlock(unsafe.Slice((*byte)(b), n*size))
+	}
+}
+
+// 对应C函数：`DumpVar(x,D)'
+func dumpVar[T any](D *dumpState, x T) {
+	D.DumpMem(unsafe.Pointer(&x), 1, int(unsafe.Sizeof(x)))
+}
+
+// DumpBlock
+// 对应C函数：`static void DumpBlock(const void* b, size_t size, DumpState* D)'
+func (D *dumpState) DumpBlock(b []byte) {
+	if D.status == 0 {
+		D.L.Unlock()
+		D.status = D.writer(D.L, b, len(b), D.data)
+		D.L.Lock()
+	}
+}
+
+// DumpChar
+// 对应C函数：`static void DumpChar(int y, DumpState* D)'
+func (D *dumpState) DumpChar(y int) {
+	dumpVar(D, byte(y))
+}
+
+// DumpInt
+// 对应C函数：`static void DumpInt(int x, DumpState* D)'
+func (D *dumpState) DumpInt(x int) {
+	dumpVar(D, x)
+}
+
+// DumpNumber
+// 对应C函数：`static void DumpNumber(lua_Number x, DumpState* D)'
+func (D *dumpState) DumpNumber(x LuaNumber) {
+	dumpVar(D, x)
+}
+
+// 对应C函数：`static void DumpVector(const void* b, int n, size_t size, DumpState* D)'
+func dumpVector[T any](D *dumpState, b []T, n int) {
+	D.DumpInt(n)
+	if n > 0 {
+		D.DumpMem(unsafe.Pointer(&b[0]), n, int(unsafe.Sizeof(b[0])))
+	}
+}
+
+// DumpString
+// 对应C函数：`static void DumpString(const TString* s, DumpState* D)'
+func (D *dumpState) DumpString(s *TString) {
+	if s == nil {
+		dumpVar(D, uintptr(0))
+	} else {
+		var size = uintptr(s.Len + 1) /* include trailing '\0' */
+		dumpVar(D, size)
+		D.DumpBlock(append(s.GetStr()[:s.Len:s.Len], 0))
+	}
+}
+
+// DumpCode
+// 对应C函数：`DumpCode(f,D)'
+func (D *dumpState) DumpCode(f *Proto) {
+	dumpVector(D, f.code, len(f.code))
+}
+
+// DumpConstants
+// 对应C函数：`static void DumpConstants(const Proto* f, DumpState* D)'
+func (D *dumpState) DumpConstants(f *Proto) {
+	var n = len(f.k)
+	D.DumpInt(n)
+	for i := 0; i < n; i++ {
+		var o = &f.k[i]
+		D.DumpChar(int(o.tt))
+		switch o.tt {
+		case LUA_TNIL:
+		case LUA_TBOOLEAN:
+			if o.BooleanValue() {
+				D.DumpChar(1)
+			} else {
+				D.DumpChar(0)
+			}
+		case LUA_TNUMBER:
+			D.DumpNumber(o.NumberValue())
+		case LUA_TSTRING:
+			D.DumpString(o.StringValue())
+		default:
+			LuaAssert(false)
+		}
+	}
+	n = len(f.p)
+	D.DumpInt(n)
+	for i := 0; i < n; i++ {
+		D.DumpFunction(f.p[i], f.source)
+	}
+}
+
+// DumpDebug
+// 对应C函数：`static void DumpDebug(const Proto* f, DumpState* D)'
+func (D *dumpState) DumpDebug(f *Proto) {
+	var n = len(f.lineInfo)
+	if D.strip {
+		n = 0
+	}
+	dumpVector(D, f.lineInfo, n)
+	n = len(f.locVars)
+	if D.strip {
+		n = 0
+	}
+	D.DumpInt(n)
+	for i := 0; i < n; i++ {
+		D.DumpString(f.locVars[i].varName)
+		D.DumpInt(f.locVars[i].startPc)
+		D.DumpInt(f.locVars[i].endPc)
+	}
+	n = len(f.upValues)
+	if D.strip {
+		n = 0
+	}
+	D.DumpInt(n)
+	for i := 0; i < n; i++ {
+		D.DumpString(f.upValues[i])
+	}
+}
+
+// DumpFunction
+// 对应C函数：`static void DumpFunction(const Proto* f, const TString* p, DumpState* D)'
+func (D *dumpState) DumpFunction(f *Proto, p *TString) {
+	if f.source == p || D.strip {
+		D.DumpString(nil)
+	} else {
+		D.DumpString(f.source)
+	}
+	D.DumpInt(f.lineDefined)
+	D.DumpInt(f.lastLineDefined)
+	D.DumpChar(f.nUps)
+	D.DumpChar(f.numParams)
+	D.DumpChar(int(f.isVarArg))
+	D.DumpChar(f.maxStackSize)
+	D.DumpCode(f)
+	D.DumpConstants(f)
+	D.DumpDebug(f)
+}
+
+// DumpHeader
+// 对应C函数：`static void DumpHeader(DumpState* D)'
+func (D *dumpState) DumpHeader() {
+	var h [LUAC_HEADERSIZE]byte
+	uHeader(h[:])
+	D.DumpBlock(h[:])
+}
+
+// uDump dump Lua function as precompiled chunk
+// 对应C函数：`int luaU_dump (lua_State* L, const Proto* f, lua_Writer w, void* data, int strip)'
+func (L *LuaState) uDump(f *Proto, w LuaWriteFunc, data interface{}, strip bool) int {
+	var D = dumpState{
+		L:      L,
+		writer: w,
+		data:   data,
+		strip:  strip,
+		status: 0,
+	}
+	D.DumpHeader()
+	D.DumpFunction(f, nil)
+	return D.status
+}
@@ -0,0 +1,51 @@
+package golua
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLuaState_Dump(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	if L.LLoadString("local a, b = ... return a .. b, 1.5, #a") != 0 {
+		t.Fatal(L.ToString(-1))
+	}
+	var chunk []byte
+	var write = func(L *LuaState, p []byte, sz int, ud interface{}) int {
+		chunk = append(chunk, p[:sz]...)
+		return 0
+	}
+	if status := L.Dump(write, nil, false); status != 0 {
+		t.Fatalf("Dump returned %d", status)
+	}
+	var header = make([]byte, LUAC_HEADERSIZE)
+	uHeader(header)
+	if !bytes.HasPrefix(chunk, header) {
+		t.Fatalf("chunk does not start with the header: % x", chunk[:LUAC_HEADERSIZE])
+	}
+	if L.LLoadBuffer(chunk, "=dumped") != 0 {
+		t.Fatal(L.ToString(-1))
+	}
+	L.PushString("ab")
+	L.PushString("cd")
+	L.Call(2, 3)
+	if L.ToString(-3) != "abcd" || L.ToNumber(-2) != 1.5 || L.ToInteger(-1) != 2 {
+		t.Errorf("unexpected results %q %v %v", L.ToString(-3), L.ToNumber(-2), L.ToInteger(-1))
+	}
+
+	/* the first error from the writer stops the dump */
+	var calls int
+	var fail = func(L *LuaState, p []byte, sz int, ud interface{}) int {
+		calls++
+		return 7
+	}
+	L.PushValue(-4)
+	if status := L.Dump(fail, nil, true); status != 7 || calls != 1 {
+		t.Errorf("status %d after %d calls", status, calls)
+	}
+	L.PushCFunction(func(L *LuaState) int { return 0 })
+	if L.Dump(write, nil, false) != 1 {
+		t.Error("a C function cannot be dumped")
+	}
+}
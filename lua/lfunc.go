@@ -5,12 +5,11 @@ import "unsafe"
 // 对应C函数：`Closure *luaF_newCclosure (lua_State *L, int nelems, Table *e)'
 func (L *LuaState) fNewCClosure(nElems int, e *Table) *CClosure {
 	var c = &CClosure{
-		ClosureHeader: ClosureHeader{},
+		ClosureHeader: ClosureHeader{isC: true},
 		f:             nil,
 		upValue:       make([]TValue, nElems),
 	}
 	L.cLink(c, LUA_TFUNCTION)
-	c.isC = true
 	c.env = e
 	c.nUpValues = lu_byte(nElems)
 	return c
@@ -39,25 +38,22 @@ func (L *LuaState) fFindUpVal(level StkId) *UpVal {
 		if !(uintptr(unsafe.Pointer(p.v)) >= uintptr(unsafe.Pointer(level))) {
 			break
 		}
+		LuaAssert(p.v != &p.value)
 		if p.v == level { /* found a corresponding up-value? */
 			if isdead(g, p) { /* is it dead? */
 				p.ChangeWhite() /* resurrect it */
-				return p
 			}
+			return p
 		}
 		pp = &p.next
 	}
+	L.MRealloc(0, int(unsafe.Sizeof(UpVal{})))
 	var uv = &UpVal{} /* not found: create a new one */
 	uv.tt = LUA_TUPVAL
 	uv.marked = g.cWhite()
 	uv.v = level  /* current value lives in the stack */
 	uv.next = *pp /* chain it in the proper position */
 	*pp = uv
-	uv.lPrev = &g.uvHead
-	uv.lNext = g.uvHead.lNext
-	g.uvHead.lNext.lPrev = uv
-	g.uvHead.lNext = uv
-	LuaAssert(uv.lNext.lPrev == uv && uv.lPrev.lNext == uv)
 	return uv
 }
 
@@ -73,15 +69,17 @@ func fNewUpVal(L *LuaState) *UpVal {
 
 // 对应C函数：`void luaF_close (lua_State *L, StkId level)'
 func (L *LuaState) fClose(level StkId) {
-	// todo: fClose (这个函数还没有实现)
-	// g := L.G()
 	for L.openUpval != nil {
 		uv := L.openUpval.ToUpval()
 		if uintptr(unsafe.Pointer(uv.v)) < uintptr(unsafe.Pointer(level)) {
 			break
 		}
-		LuaAssert(!uv.IsBlack() && uv.v != &uv.value)
-		L.openUpval = uv.next /* remove from `open' list */
+		LuaAssert(uv.v != &uv.value)
+		L.openUpval = uv.next   /* remove from `open' list */
+		if !isdead(L.G(), uv) { /* a dead upvalue is simply dropped */
+			uv.value.SetObj(L, uv.v)
+			uv.v = &uv.value /* now current value lives here */
+		}
 	}
 }
 
@@ -106,3 +104,18 @@ func (L *LuaState) fNewProto() *Proto {
 	L.cLink(f, LUA_TPROTO)
 	return f
 }
+
+// Look for n-th local variable at line `line' in function `func'.
+// Returns "" if not found.
+// 对应C函数：`const char *luaF_getlocalname (const Proto *f, int local_number, int pc)'
+func (p *Proto) fGetLocalName(localNumber int, pc int) string {
+	for i := 0; i < len(p.locVars) && p.locVars[i].startPc <= pc; i++ {
+		if pc < p.locVars[i].endPc { /* is variable active? */
+			localNumber--
+			if localNumber == 0 {
+				return string(p.locVars[i].varName.GetStr())
+			}
+		}
+	}
+	return "" /* not found */
+}
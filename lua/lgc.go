@@ -1,5 +1,26 @@
 package golua
 
+import (
+	"strings"
+	"unsafe"
+)
+
+// 对象的内存由Go回收：只有线程和userdata挂在rootGC上（线程回收时要调用LUAIUserStateFree，
+// userdata可能需要调用__gc），字符串表中的字符串也会被清扫，其它对象一旦在Lua中不可达，
+// 就不再被任何结构引用，直接成为Go的垃圾。
+//
+// 因为无法遍历所有对象，标记不使用灰色和黑色：被标记的对象染成另一种白色，
+// atomic中翻转currentWhite之后，它们就成为下一轮回收的白色，没有被标记的对象则成为死对象。
+// 整个标记阶段在一步之内完成（见singleStep中GCSPropagate的说明），所以也不需要写屏障；
+// 清扫和终结器的调用仍然像C中一样分步进行。
+
+const (
+	GCSTEPSIZE     = 1024
+	GCSWEEPMAX     = 40
+	GCSWEEPCOST    = 10
+	GCFINALIZECOST = 100
+)
+
 /* Possible states of the Garbage Collector */
 const (
 	GCSPause       = 0
@@ -9,14 +30,30 @@ const (
 	GCSFinalize    = 4
 )
 
-// FIXEDBIT bit 5 - object is fixed (should not be collected)
+// Layout for bit use in `marked' field:
+// bit 0 - object is white (type 0)
+// bit 1 - object is white (type 1)
+// bit 2 - object is black
+// bit 3 - for userdata: has been finalized
+// bit 3 - for tables: has weak keys
+// bit 4 - for tables: has weak values
+// bit 5 - object is fixed (should not be collected)
+// bit 6 - object is "super" fixed (only the main thread)
 const (
-	WHITE0BIT = 0
-	WHITE1BIT = 1
-	BLACKBIT  = 2
-	FIXEDBIT  = 5
-	SFIXEDBIT = 6
-	WHITEBITS = 1<<WHITE0BIT | 1<<WHITE1BIT
+	WHITE0BIT    = 0
+	WHITE1BIT    = 1
+	BLACKBIT     = 2
+	FINALIZEDBIT = 3
+	KEYWEAKBIT   = 3
+	VALUEWEAKBIT = 4
+	FIXEDBIT     = 5
+	SFIXEDBIT    = 6
+	WHITEBITS    = 1<<WHITE0BIT | 1<<WHITE1BIT
+
+	KEYWEAK   = 1 << KEYWEAKBIT
+	VALUEWEAK = 1 << VALUEWEAKBIT
+
+	maskMarks = ^lu_byte(1<<BLACKBIT | WHITEBITS)
 )
 
 // IsWhite
@@ -43,53 +80,674 @@ func (c *CommonHeader) ChangeWhite() {
 	c.marked ^= WHITEBITS
 }
 
+// 对应C函数：`makewhite(g,x)'
+func (c *CommonHeader) makeWhite(g *GlobalState) {
+	c.marked = c.marked&maskMarks | g.cWhite()
+}
+
+// 对应C函数：`isfinalized(u)'
+func (u *Udata) isFinalized() bool {
+	return u.marked&(1<<FINALIZEDBIT) != 0
+}
+
+// 对应C函数：`markfinalized(u)'
+func (u *Udata) markFinalized() {
+	u.marked |= 1 << FINALIZEDBIT
+}
+
+// 对应C函数：`otherwhite(g)'
+func (g *GlobalState) otherWhite() lu_byte {
+	return g.currentWhite ^ WHITEBITS
+}
+
 // 对应C函数：`isdead(g,v)'
 func isdead(g *GlobalState, v GCObject) bool {
-	// todo: isdead
-	// log.Println("isdead: not implemented ")
-	return false
+	return v.gch().marked&g.otherWhite()&WHITEBITS != 0
 }
 
-// 对应C函数：`luaC_checkGC(L)'
-func (L *LuaState) cCheckGC() {
-	// todo: cCheckGC
-	// log.Println("cCheckGC not implemented")
+// isMarked 对象在这一轮回收中是否已经被标记。与isdead的判断相同：
+// 被标记的对象和上一轮留下的死对象都带着另一种白色，后者已经不可达，不会再被遍历到。
+func (g *GlobalState) isMarked(o *GCHeader) bool {
+	return o.marked&g.otherWhite()&WHITEBITS != 0
+}
+
+// objSize 估算对象当前占用的字节数，用于设置下一次回收的阈值
+func objSize(o GCObject) lu_mem {
+	switch o.gcType() {
+	case LUA_TSTRING:
+		return int(unsafe.Sizeof(TString{})) + o.ToTString().Len
+	case LUA_TUSERDATA:
+		return int(unsafe.Sizeof(Udata{})) + o.ToUdata().len
+	case LUA_TTABLE:
+		var h = o.ToTable()
+		var n = int(unsafe.Sizeof(Table{})) + len(h.array)*int(unsafe.Sizeof(TValue{}))
+		if &h.node[0] != DummyNode {
+			n += len(h.node) * int(unsafe.Sizeof(Node{}))
+		}
+		return n
+	case LUA_TFUNCTION:
+		var cl = o.ToClosure()
+		if cl.IsCFunction() {
+			return int(unsafe.Sizeof(CClosure{})) + len(cl.C().upValue)*int(unsafe.Sizeof(TValue{}))
+		}
+		return int(unsafe.Sizeof(LClosure{})) + len(cl.L().upVals)*int(unsafe.Sizeof(uintptr(0)))
+	case LUA_TUPVAL:
+		return int(unsafe.Sizeof(UpVal{}))
+	case LUA_TPROTO:
+		var f = o.(*Proto)
+		return int(unsafe.Sizeof(Proto{})) +
+			len(f.k)*int(unsafe.Sizeof(TValue{})) +
+			len(f.code)*int(unsafe.Sizeof(Instruction(0))) +
+			len(f.p)*int(unsafe.Sizeof(uintptr(0))) +
+			len(f.lineInfo)*int(unsafe.Sizeof(int(0))) +
+			len(f.locVars)*int(unsafe.Sizeof(LocVar{})) +
+			len(f.upValues)*int(unsafe.Sizeof(uintptr(0)))
+	case LUA_TTHREAD:
+		var th = o.ToThread()
+		return int(unsafe.Sizeof(LuaState{})) +
+			len(th.stack)*int(unsafe.Sizeof(TValue{})) +
+			len(th.baseCi)*int(unsafe.Sizeof(CallInfo{}))
+	default:
+		LuaAssert(false)
+		return 0
+	}
+}
+
+// 对应C函数：`static void removeentry (Node *n)'
+func removeEntry(n *Node) {
+	LuaAssert(n.GetVal().IsNil())
+	if n.GetKey().IsCollectable() {
+		n.GetKey().tt = LUA_TDEADKEY /* dead key; remove it */
+	}
+}
+
+// 对应C函数：`markvalue(g,o)'
+func (g *GlobalState) markValue(o *TValue) {
+	if o.IsCollectable() && !g.isMarked(o.value.gc.gch()) {
+		g.reallyMarkObject(o.value.gc)
+	}
+}
+
+// 对应C函数：`markobject(g,t)'，只用于Table
+func (g *GlobalState) markTable(h *Table) {
+	if h != nil && !g.isMarked(&h.CommonHeader) {
+		g.reallyMarkObject(h)
+	}
+}
+
+// 对应C函数：`stringmark(s)'
+func (g *GlobalState) stringMark(s *TString) {
+	if s != nil && !g.isMarked(&s.CommonHeader) {
+		g.reallyMarkObject(s)
+	}
+}
+
+// 对应C函数：`static void reallymarkobject (global_State *g, GCObject *o)'
+func (g *GlobalState) reallyMarkObject(o GCObject) {
+	var h = o.gch()
+	LuaAssert(!g.isMarked(h))
+	h.marked = h.marked&maskMarks | g.otherWhite()&WHITEBITS
+	g.estimate += objSize(o)
+	switch o.gcType() {
+	case LUA_TSTRING:
+		return
+	case LUA_TUSERDATA:
+		var u = o.ToUdata()
+		g.markTable(u.metatable)
+		g.markTable(u.env)
+	case LUA_TUPVAL:
+		g.markValue(o.ToUpval().v)
+	case LUA_TFUNCTION, LUA_TTABLE, LUA_TTHREAD, LUA_TPROTO:
+		g.gray = append(g.gray, o)
+	default:
+		LuaAssert(false)
+	}
+}
+
+// 对应C函数：`static void marktmu (global_State *g)'
+func (g *GlobalState) markTmu() {
+	if g.tmUData == nil {
+		return
+	}
+	var u = g.tmUData
+	for {
+		u = u.GetNext()
+		u.gch().makeWhite(g) /* may be marked, if left from previous GC */
+		g.reallyMarkObject(u)
+		if u == g.tmUData {
+			break
+		}
+	}
+}
+
+// cSeparateUData 把需要调用__gc的白色userdata移到tmUData链表中，all为true时不论颜色
+// 对应C函数：`size_t luaC_separateudata (lua_State *L, int all)'
+func (L *LuaState) cSeparateUData(all bool) lu_mem {
+	var g = L.G()
+	var deadMem lu_mem = 0
+	var p = &g.mainThread.next
+	for *p != nil {
+		var curr = *p
+		var u = curr.ToUdata()
+		if !(!g.isMarked(&u.CommonHeader) || all) || u.isFinalized() {
+			p = &u.next /* don't bother with them */
+		} else if FastTM(L, u.metatable, TM_GC) == nil {
+			u.markFinalized() /* don't need finalization */
+			p = &u.next
+		} else { /* must call its gc method */
+			deadMem += objSize(u)
+			u.markFinalized()
+			*p = u.next
+			/* link `curr' at the end of `tmudata' list */
+			if g.tmUData == nil { /* list is empty? */
+				u.next = u /* creates a circular list */
+				g.tmUData = u
+			} else {
+				u.next = g.tmUData.GetNext()
+				g.tmUData.SetNext(u)
+				g.tmUData = u
+			}
+		}
+	}
+	return deadMem
+}
+
+// 对应C函数：`static int traversetable (global_State *g, Table *h)'
+func (g *GlobalState) traverseTable(h *Table) bool {
+	var weakKey, weakValue = false, false
+	g.markTable(h.metatable)
+	var mode = gFastTM(g, h.metatable, TM_MODE)
+	if mode != nil && mode.IsString() { /* is there a weak mode? */
+		var s = string(mode.StringValue().GetStr())
+		weakKey = strings.IndexByte(s, 'k') >= 0
+		weakValue = strings.IndexByte(s, 'v') >= 0
+		if weakKey || weakValue { /* is really weak? */
+			h.marked &^= KEYWEAK | VALUEWEAK /* clear bits */
+			if weakKey {
+				h.marked |= KEYWEAK
+			}
+			if weakValue {
+				h.marked |= VALUEWEAK
+			}
+			g.weak = append(g.weak, h) /* must be cleared after GC */
+		}
+	}
+	if weakKey && weakValue {
+		return true
+	}
+	if !weakValue {
+		for i := h.sizeArray - 1; i >= 0; i-- {
+			g.markValue(&h.array[i])
+		}
+	}
+	if &h.node[0] == DummyNode {
+		return weakKey || weakValue
+	}
+	for i := h.SizeNode() - 1; i >= 0; i-- {
+		var n = h.GetNode(i)
+		LuaAssert(n.GetKey().gcType() != LUA_TDEADKEY || n.GetVal().IsNil())
+		if n.GetVal().IsNil() {
+			removeEntry(n) /* remove empty entries */
+		} else {
+			LuaAssert(!n.GetKey().IsNil())
+			if !weakKey {
+				g.markValue(n.GetKeyVal())
+			}
+			if !weakValue {
+				g.markValue(n.GetVal())
+			}
+		}
+	}
+	return weakKey || weakValue
+}
+
+// All marks are conditional because a GC may happen while the
+// prototype is still being created
+// 对应C函数：`static void traverseproto (global_State *g, Proto *f)'
+func (g *GlobalState) traverseProto(f *Proto) {
+	g.stringMark(f.source)
+	for i := range f.k { /* mark literals */
+		g.markValue(&f.k[i])
+	}
+	for _, name := range f.upValues { /* mark upvalue names */
+		g.stringMark(name)
+	}
+	for _, p := range f.p { /* mark nested protos */
+		if p != nil && !g.isMarked(&p.CommonHeader) {
+			g.reallyMarkObject(p)
+		}
+	}
+	for i := range f.locVars { /* mark local-variable names */
+		g.stringMark(f.locVars[i].varName)
+	}
+}
+
+// 对应C函数：`static void traverseclosure (global_State *g, Closure *cl)'
+func (g *GlobalState) traverseClosure(cl Closure) {
+	if cl.IsCFunction() {
+		var c = cl.C()
+		g.markTable(c.env)
+		for i := range c.upValue { /* mark its upvalues */
+			g.markValue(&c.upValue[i])
+		}
+	} else {
+		var l = cl.L()
+		g.markTable(l.env)
+		LuaAssert(int(l.nUpValues) == l.p.nUps)
+		if !g.isMarked(&l.p.CommonHeader) {
+			g.reallyMarkObject(l.p)
+		}
+		for _, uv := range l.upVals { /* mark its upvalues */
+			if uv != nil && !g.isMarked(&uv.CommonHeader) {
+				g.reallyMarkObject(uv)
+			}
+		}
+	}
+}
+
+// 对应C函数：`static void checkstacksizes (lua_State *L, StkId max)'
+func (L *LuaState) checkStackSizes(max int) {
+	var ciUsed = L.ci             /* number of `ci' in use */
+	var sUsed = max               /* part of stack in use */
+	if L.sizeCi > LUAI_MAXCALLS { /* handling overflow? */
+		return /* do not touch the stacks */
+	}
+	if 4*ciUsed < L.sizeCi && 2*BASIC_CI_SIZE < L.sizeCi {
+		L.dReallocCI(L.sizeCi / 2) /* still big enough... */
+	}
+	if 4*sUsed < L.stackSize && 2*(BASIC_STACK_SIZE+EXTRA_STACK) < L.stackSize {
+		L.dReAllocStack(L.stackSize / 2) /* still big enough... */
+	}
+}
+
+// 对应C函数：`static void traversestack (global_State *g, lua_State *l)'
+func (g *GlobalState) traverseStack(l *LuaState) {
+	g.markValue(l.GlobalTable())
+	var lim = l.top
+	for ci := 0; ci <= l.ci; ci++ {
+		LuaAssert(l.baseCi[ci].top <= l.stackLast)
+		if lim < l.baseCi[ci].top {
+			lim = l.baseCi[ci].top
+		}
+	}
+	var o = 0
+	for ; o < l.top; o++ {
+		g.markValue(&l.stack[o])
+	}
+	for ; o <= lim; o++ {
+		l.stack[o].SetNil()
+	}
+	l.checkStackSizes(lim)
+}
+
+// traverse one object of the gray list.
+// Returns `quantity' traversed.
+// 对应C函数：`static l_mem propagatemark (global_State *g)'
+func (g *GlobalState) propagateMark() lu_mem {
+	var o = g.gray[len(g.gray)-1]
+	g.gray = g.gray[:len(g.gray)-1]
+	switch o.gcType() {
+	case LUA_TTABLE:
+		g.traverseTable(o.ToTable())
+	case LUA_TFUNCTION:
+		g.traverseClosure(o.ToClosure())
+	case LUA_TTHREAD:
+		g.traverseStack(o.ToThread())
+	case LUA_TPROTO:
+		g.traverseProto(o.(*Proto))
+	default:
+		LuaAssert(false)
+		return 0
+	}
+	return objSize(o)
+}
+
+// 对应C函数：`static size_t propagateall (global_State *g)'
+func (g *GlobalState) propagateAll() lu_mem {
+	var m lu_mem = 0
+	for len(g.gray) > 0 {
+		m += g.propagateMark()
+	}
+	return m
+}
+
+// The next function tells whether a key or value can be cleared from
+// a weak table. Non-collectable objects are never removed from weak
+// tables. Strings behave as `values', so are never removed too. for
+// other objects: if really collected, cannot keep them; for userdata
+// being finalized, keep them in keys, but not in values
+// 对应C函数：`static int iscleared (const TValue *o, int iskey)'
+func (g *GlobalState) isCleared(o *TValue, isKey bool) bool {
+	if !o.IsCollectable() {
+		return false
+	}
+	if o.IsString() {
+		g.stringMark(o.StringValue()) /* strings are `values', so are never weak */
+		return false
+	}
+	return !g.isMarked(o.value.gc.gch()) ||
+		(o.IsUserdata() && !isKey && o.UdataValue().isFinalized())
+}
+
+// clear collected entries from weaktables
+// 对应C函数：`static void cleartable (GCObject *l)'
+func (g *GlobalState) clearTable(l []GCObject) {
+	for _, o := range l {
+		var h = o.ToTable()
+		if h.marked&VALUEWEAK != 0 {
+			for i := h.sizeArray - 1; i >= 0; i-- {
+				var v = &h.array[i]
+				if g.isCleared(v, false) { /* value was collected? */
+					v.SetNil() /* remove value */
+				}
+			}
+		}
+		if &h.node[0] == DummyNode {
+			continue
+		}
+		for i := h.SizeNode() - 1; i >= 0; i-- {
+			var n = h.GetNode(i)
+			if !n.GetVal().IsNil() && /* non-empty entry? */
+				(g.isCleared(n.GetKeyVal(), true) || g.isCleared(n.GetVal(), false)) {
+				n.GetVal().SetNil() /* remove value ... */
+				removeEntry(n)      /* remove entry from table */
+			}
+		}
+	}
+}
+
+// 对应C函数：`static void freeobj (lua_State *L, GCObject *o)'
+// 对象的内存由Go回收，这里只需要解除它与其它结构之间的联系
+func (L *LuaState) freeObj(o GCObject) {
+	switch o.gcType() {
+	case LUA_TUPVAL, LUA_TUSERDATA:
+	case LUA_TTHREAD:
+		var th = o.ToThread()
+		LuaAssert(th != L && th != L.G().mainThread)
+		L.eFreeThread(th)
+	case LUA_TSTRING:
+		L.G().StrT.NrUse--
+	default:
+		LuaAssert(false)
+	}
+}
+
+// 对应C函数：`sweepwholelist(L,p)'
+func (L *LuaState) sweepWholeList(p *GCObject) {
+	L.sweepList(p, MAX_LUMEM)
+}
+
+// 对应C函数：`static GCObject **sweeplist (lua_State *L, GCObject **p, lu_mem count)'
+func (L *LuaState) sweepList(p *GCObject, count lu_mem) *GCObject {
+	var g = L.G()
+	var deadMask = g.otherWhite()
+	for *p != nil && count > 0 {
+		count--
+		var curr = *p
+		var h = curr.gch()
+		if curr.gcType() == LUA_TTHREAD { /* sweep open upvalues of each thread */
+			L.sweepWholeList(&curr.ToThread().openUpval)
+		}
+		if (h.marked^WHITEBITS)&deadMask != 0 { /* not dead? */
+			LuaAssert(!isdead(g, curr) || h.marked&(1<<FIXEDBIT) != 0)
+			h.makeWhite(g) /* make it white (for next cycle) */
+			p = &h.next
+		} else { /* must erase `curr' */
+			LuaAssert(isdead(g, curr) || deadMask == 1<<SFIXEDBIT)
+			*p = h.next
+			if curr == g.rootGC { /* is the first element of the list? */
+				g.rootGC = h.next /* adjust first */
+			}
+			L.freeObj(curr)
+		}
+	}
+	return p
+}
+
+// 对应C函数：`static void checkSizes (lua_State *L)'
+func (L *LuaState) checkSizes() {
+	var g = L.G()
+	/* check size of string hash */
+	if g.StrT.NrUse < g.StrT.Size/4 && g.StrT.Size > MINSTRTABSIZE*2 {
+		L.sResize(g.StrT.Size / 2) /* table is too big */
+	}
+	/* check size of buffer */
+	if g.buff.size > LUA_MINBUFFER*2 { /* buffer too big? */
+		g.buff.Resize(L, g.buff.size/2)
+	}
+}
+
+// 对应C函数：`static void GCTM (lua_State *L)'
+func (L *LuaState) gcTM() {
+	var g = L.G()
+	var o = g.tmUData.GetNext() /* get first element */
+	var udata = o.ToUdata()
+	/* remove udata from `tmudata' */
+	if o == g.tmUData { /* last element? */
+		g.tmUData = nil
+	} else {
+		g.tmUData.SetNext(udata.next)
+	}
+	udata.next = g.mainThread.next /* return it to `root' list */
+	g.mainThread.next = o
+	udata.makeWhite(g)
+	var tm = FastTM(L, udata.metatable, TM_GC)
+	if tm != nil {
+		var oldAh = L.allowHook
+		var oldT = g.GCThreshold
+		L.allowHook = 0                  /* stop debug hooks during GC tag method */
+		g.GCThreshold = 2 * g.totalBytes /* avoid GC steps */
+		L.Top().SetObj(L, tm)
+		L.AtTop(1).SetUserData(L, udata)
+		L.top += 2
+		L.dCall(L.AtTop(-2), 0)
+		L.allowHook = oldAh  /* restore hooks */
+		g.GCThreshold = oldT /* restore threshold */
+	}
+}
+
+// Call all GC tag methods
+// 对应C函数：`void luaC_callGCTM (lua_State *L)'
+func (L *LuaState) cCallGCTM() {
+	for L.G().tmUData != nil {
+		L.gcTM()
+	}
 }
 
 // 对应C函数：`void luaC_freeall (lua_State *L)'
 func (L *LuaState) cFreeAll() {
-	// todo: cFreeAll
-	// log.Println("cFreeAll not implemented")
+	var g = L.G()
+	g.currentWhite = WHITEBITS | 1<<SFIXEDBIT /* mask to collect all elements */
+	L.sweepWholeList(&g.rootGC)
+	for i := range g.StrT.Hash { /* free all string lists */
+		L.sweepWholeList(&g.StrT.Hash[i])
+	}
 }
 
+// 对应C函数：`static void markmt (global_State *g)'
+func (g *GlobalState) markMt() {
+	for i := range g.mt {
+		g.markTable(g.mt[i])
+	}
+}
+
+// mark root set
+// 对应C函数：`static void markroot (lua_State *L)'
+func (L *LuaState) markRoot() {
+	var g = L.G()
+	g.gray = nil
+	g.weak = nil
+	g.estimate = 0
+	g.reallyMarkObject(g.mainThread)
+	/* make global table be traversed before main stack */
+	g.markValue(g.mainThread.GlobalTable())
+	g.markValue(L.Registry())
+	g.markMt()
+	g.gcState = GCSPropagate
+}
+
+// 对应C函数：`static void atomic (lua_State *L)'
+func (L *LuaState) atomic() {
+	var g = L.G()
+	if !g.isMarked(&L.CommonHeader) {
+		g.reallyMarkObject(L) /* mark running thread */
+	}
+	g.propagateAll()
+	var udSize = L.cSeparateUData(false) /* separate userdata to be finalized */
+	g.markTmu()                          /* mark `preserved' userdata */
+	udSize += g.propagateAll()           /* remark, to propagate `preserveness' */
+	g.clearTable(g.weak)                 /* remove collected objects from weak tables */
+	g.weak = nil
+	/* flip current white */
+	g.currentWhite = g.otherWhite()
+	g.sweepStrGC = 0
+	g.sweepGc = &g.rootGC
+	g.gcState = GCSSweepString
+	/* 未被标记的对象都已不可达，它们的内存即将交给Go回收；缓冲区不是对象，要单独加回 */
+	g.totalBytes = g.estimate + g.bufBytes
+	g.estimate = g.totalBytes - udSize /* first estimate */
+}
+
+// 对应C函数：`static l_mem singlestep (lua_State *L)'
+func (L *LuaState) singleStep() lu_mem {
+	var g = L.G()
+	switch g.gcState {
+	case GCSPause:
+		L.markRoot() /* start a new collection */
+		fallthrough
+	case GCSPropagate:
+		// 标记阶段不分步：这个移植中并不是每次写入都经过写屏障，
+		// 让Lua代码在标记的中途运行会漏标对象，所以在一步之内完成标记和atomic
+		var work = g.propagateAll()
+		L.atomic()
+		return work
+	case GCSSweepString:
+		L.sweepWholeList(&g.StrT.Hash[g.sweepStrGC])
+		g.sweepStrGC++
+		if g.sweepStrGC >= int(g.StrT.Size) { /* nothing more to sweep? */
+			g.gcState = GCSSweep /* end sweep-string phase */
+		}
+		return GCSWEEPCOST
+	case GCSSweep:
+		g.sweepGc = L.sweepList(g.sweepGc, GCSWEEPMAX)
+		if *g.sweepGc == nil { /* nothing more to sweep? */
+			L.checkSizes()
+			g.gcState = GCSFinalize /* end sweep phase */
+		}
+		return GCSWEEPMAX * GCSWEEPCOST
+	case GCSFinalize:
+		if g.tmUData != nil {
+			L.gcTM()
+			if g.estimate > GCFINALIZECOST {
+				g.estimate -= GCFINALIZECOST
+			}
+			return GCFINALIZECOST
+		}
+		g.gcState = GCSPause /* end collection */
+		g.gcDept = 0
+		return 0
+	default:
+		LuaAssert(false)
+		return 0
+	}
+}
+
+// 对应C函数：`setthreshold(g)'
+func (g *GlobalState) setThreshold() {
+	g.GCThreshold = (g.estimate / 100) * g.gcPause
+	if g.memLimit > 0 { /* 在到达内存上限之前留出回收的机会 */
+		var mid = g.estimate + (g.memLimit-g.estimate)/2
+		if g.GCThreshold > mid {
+			g.GCThreshold = mid
+		}
+	}
+}
+
+// 对应C函数：`luaC_checkGC(L)'
+func (L *LuaState) cCheckGC() {
+	if L.G().totalBytes >= L.G().GCThreshold {
+		L.cStep()
+	}
+}
+
+// 对应C函数：`void luaC_step (lua_State *L)'
+func (L *LuaState) cStep() {
+	var g = L.G()
+	var lim = (GCSTEPSIZE / 100) * g.gcStepMul
+	if lim == 0 {
+		lim = (MAX_LUMEM - 1) / 2 /* no limit */
+	}
+	g.gcDept += g.totalBytes - g.GCThreshold
+	for {
+		lim -= L.singleStep()
+		if g.gcState == GCSPause || lim <= 0 {
+			break
+		}
+	}
+	if g.gcState != GCSPause {
+		if g.gcDept < GCSTEPSIZE {
+			g.GCThreshold = g.totalBytes + GCSTEPSIZE
+		} else {
+			g.gcDept -= GCSTEPSIZE
+			g.GCThreshold = g.totalBytes
+		}
+	} else {
+		g.setThreshold()
+	}
+}
+
+// 对应C函数：`void luaC_fullgc (lua_State *L)'
+func (L *LuaState) cFullGC() {
+	var g = L.G()
+	if g.gcState <= GCSPropagate {
+		/* reset sweep marks to sweep all elements (returning them to white) */
+		g.sweepStrGC = 0
+		g.sweepGc = &g.rootGC
+		/* reset other collector lists */
+		g.gray = nil
+		g.weak = nil
+		g.gcState = GCSSweepString
+	}
+	LuaAssert(g.gcState != GCSPause && g.gcState != GCSPropagate)
+	/* finish any pending sweep phase */
+	for g.gcState != GCSFinalize {
+		LuaAssert(g.gcState == GCSSweepString || g.gcState == GCSSweep)
+		L.singleStep()
+	}
+	L.markRoot()
+	for g.gcState != GCSPause {
+		L.singleStep()
+	}
+	g.setThreshold()
+}
+
+// 标记在一步之内完成，Lua代码不会看到标记到一半的对象，所以写屏障什么也不用做。
+
 // 对应C函数：` luaC_barrier(L,p,v)'
 func (L *LuaState) cBarrier(p GCObject, v *TValue) {
-	// todo: cBarrier
-	// log.Println("cBarrier not implemented")
 }
 
 // 对应C函数：`luaC_barriert(L,t,v)'
 func (L *LuaState) cBarrierT(t *Table, v *TValue) {
-	// todo: cBarrierT
-	// log.Println("cBarrierT not implemented")
 }
 
 // 对应C函数：`luaC_objbarrier(L,p,o)'
 func (L *LuaState) cObjBarrier(p GCObject, o GCObject) {
-	// todo: cObjBarrier
-	// log.Println("cObjBarrier not implemented")
 }
 
 // 对应C函数：`luaC_objbarriert(L,t,o)'
 func (L *LuaState) cObjBarrierT(t *Table, o GCObject) {
-	// todo: cObjBarrierT
 }
 
 // 对应C函数：`void luaC_link (lua_State *L, GCObject *o, lu_byte tt)'
+// 只有线程挂到rootGC上，其它对象不可达之后直接由Go回收
 func (L *LuaState) cLink(o GCObject, tt ttype) {
 	var g = L.G()
-	o.SetNext(g.rootGC)
-	g.rootGC = o
-	o.SetMarked(g.cWhite())
 	o.setType(tt)
+	L.MRealloc(0, objSize(o)) /* 内存不足时对象还没有挂到任何链表上 */
+	if tt == LUA_TTHREAD {
+		o.SetNext(g.rootGC)
+		g.rootGC = o
+	}
+	o.SetMarked(g.cWhite())
 }
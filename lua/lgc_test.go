@@ -0,0 +1,195 @@
+package golua
+
+import (
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestUserdataFinalizers(t *testing.T) {
+	L := LuaOpen()
+	var finalized []string
+	L.NewTable() /* metatable shared by all the userdata */
+	L.PushCFunction(func(L *LuaState) int {
+		finalized = append(finalized, L.ToUserData(1).(*Udata).Value().(string))
+		return 0
+	})
+	L.SetField(-2, "__gc")
+	for _, name := range []string{"a", "b", "c"} {
+		L.NewUserData(0)
+		L.ToUserData(-1).(*Udata).SetValue(name)
+		L.PushValue(-2)
+		L.SetMetaTable(-2)
+		if name != "c" {
+			L.Pop(1) /* only `c' stays on the stack */
+		}
+	}
+	L.GC(LUA_GCCOLLECT, 0)
+	if len(finalized) != 2 || finalized[0] == "c" || finalized[1] == "c" {
+		t.Fatalf("unexpected finalizers before Close: %v", finalized)
+	}
+	L.Close()
+	if len(finalized) != 3 || finalized[2] != "c" {
+		t.Fatalf("Close must finalize the remaining userdata: %v", finalized)
+	}
+}
+
+func TestWeakValues(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	L.NewTable() /* weak table */
+	L.NewTable()
+	L.PushLiteral("v")
+	L.SetField(-2, "__mode")
+	L.SetMetaTable(-2)
+	L.NewTable()
+	L.RawSetI(-2, 1) /* only reachable through the weak table */
+	L.PushLiteral("str")
+	L.RawSetI(-2, 2) /* strings are values, never removed */
+	L.GC(LUA_GCCOLLECT, 0)
+	L.RawGetI(-1, 1)
+	if !L.IsNil(-1) {
+		t.Errorf("collected value still in the weak table")
+	}
+	L.RawGetI(-2, 2)
+	if L.ToString(-1) != "str" {
+		t.Errorf("string removed from the weak table")
+	}
+}
+
+/* buffers are not collectable objects, so the atomic phase must not drop their bytes */
+func TestBufferBytesAcrossGC(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	var g = L.G()
+	var b LBuffer
+	L.LBuffInit(&b)
+	b.AddString(strings.Repeat("x", 1<<20))
+	L.GC(LUA_GCCOLLECT, 0)
+	if g.bufBytes != cap(b.b)+g.buff.size || g.totalBytes < g.bufBytes {
+		t.Errorf("after GC: bufBytes %d, totalBytes %d, buffer %d", g.bufBytes, g.totalBytes, cap(b.b))
+	}
+	b.PushResult()
+	L.Pop(1)
+	if g.lBufBytes != 0 || g.bufBytes != g.buff.size {
+		t.Errorf("after PushResult: lBufBytes %d, bufBytes %d", g.lBufBytes, g.bufBytes)
+	}
+
+	/* a buffer abandoned by an error is released when the error is caught */
+	L.PushCFunction(func(L *LuaState) int {
+		var b LBuffer
+		L.LBuffInit(&b)
+		b.AddString(strings.Repeat("y", 1<<16))
+		return L.LError("abandoned")
+	})
+	if L.PCall(0, 0, 0) == 0 {
+		t.Fatal("error expected")
+	}
+	if g.lBufBytes != 0 || g.bufBytes != g.buff.size {
+		t.Errorf("after error: lBufBytes %d, bufBytes %d", g.lBufBytes, g.bufBytes)
+	}
+}
+
+/* freeing a dead thread must not subtract bytes the atomic phase already dropped */
+func TestDeadThreadBytes(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	for i := 0; i < 2000; i++ {
+		L.NewThread()
+		L.Pop(1)
+	}
+	L.GC(LUA_GCCOLLECT, 0)
+	L.GC(LUA_GCCOLLECT, 0)
+	if g := L.G(); g.totalBytes < g.bufBytes {
+		t.Errorf("totalBytes %d below the buffer bytes %d", g.totalBytes, g.bufBytes)
+	}
+}
+
+// 进程的常驻内存，读不到/proc时返回0
+func residentBytes() uint64 {
+	b, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0
+	}
+	var f = strings.Fields(string(b))
+	if len(f) < 2 {
+		return 0
+	}
+	pages, _ := strconv.ParseUint(f[1], 10, 64)
+	return pages * uint64(os.Getpagesize())
+}
+
+func median(x []uint64) uint64 {
+	var s = append([]uint64(nil), x...)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	return s[len(s)/2]
+}
+
+func mean(x []uint64) uint64 {
+	var sum uint64
+	for _, v := range x {
+		sum += v
+	}
+	return sum / uint64(len(x))
+}
+
+// Every iteration creates a new string, a table and a closure, and drops
+// the ones created 1000 iterations before. The strings are not held
+// weakly by the string table: they stay there until the string sweep of
+// the next GC cycle frees them, so the heap swings by a cycle's worth of
+// garbage between samples. The bounds below are budgets above that swing.
+func TestSoakFlatMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("soak test")
+	}
+	const (
+		heapBudget = 512 << 10 /* allowed distance of any sample above the median */
+		riseBudget = 128 << 10 /* allowed growth between the halves of the run */
+		rssBudget  = 4 << 20   /* RSS is noisier than the heap */
+	)
+	L := LuaOpen()
+	defer L.Close()
+	var heap, rss []uint64
+	L.Register("sample", func(L *LuaState) int {
+		var m runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&m)
+		heap = append(heap, m.HeapAlloc)
+		rss = append(rss, residentBytes())
+		return 0
+	})
+	if L.LDoString(`
+		local cache = {}
+		for i = 1, 1000000 do
+			local k = "key" .. i
+			local t = {k, i, {}}
+			cache[i % 1000] = function() return t end
+			if i % 50000 == 0 then sample() end
+		end`) != 0 {
+		t.Fatal(L.ToString(-1))
+	}
+	/* the first sample is taken while the working set is still being built */
+	heap, rss = heap[1:], rss[1:]
+	var m = median(heap)
+	for i, h := range heap {
+		if h > m+heapBudget {
+			t.Errorf("heap sample %d is %d bytes, median %d: %v", i, h, m, heap)
+		}
+	}
+	var half = len(heap) / 2
+	if first, second := mean(heap[:half]), mean(heap[half:]); second > first+riseBudget {
+		t.Errorf("heap keeps rising: mean %d then %d bytes: %v", first, second, heap)
+	}
+	if rss[0] == 0 {
+		return /* no /proc */
+	}
+	m = median(rss)
+	for i, r := range rss {
+		if r > m+rssBudget {
+			t.Errorf("RSS sample %d is %d bytes, median %d: %v", i, r, m, rss)
+		}
+	}
+}
@@ -1,17 +1,222 @@
 package lib
 
-import golua "luar/lua"
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	golua "luar/lua"
+)
 
 type (
 	LuaState     = golua.LuaState
 	LuaCFunction = golua.LuaCFunction
 )
 
-func Assert(L *LuaState) int {
-	// todo: Assert
+// 对应C函数：`static int luaB_print (lua_State *L)'
+func print(L *LuaState) int {
+	var n = L.GetTop() /* number of arguments */
+	L.GetGlobal("tostring")
+	for i := 1; i <= n; i++ {
+		L.PushValue(-1) /* function to be called */
+		L.PushValue(i)  /* value to print */
+		L.Call(1, 1)
+		if !L.IsString(-1) { /* get result */
+			return L.LError("'tostring' must return a string to 'print'")
+		}
+		if i > 1 {
+			fmt.Fprint(os.Stdout, "\t")
+		}
+		fmt.Fprint(os.Stdout, L.ToString(-1))
+		L.Pop(1) /* pop result */
+	}
+	fmt.Fprint(os.Stdout, "\n")
 	return 0
 }
 
+// 对应C函数：`static int luaB_tonumber (lua_State *L)'
+func toNumber(L *LuaState) int {
+	var base = L.LOptInt(2, 10)
+	if base == 10 { /* standard conversion */
+		L.LCheckAny(1)
+		if L.IsNumber(1) {
+			L.PushNumber(L.ToNumber(1))
+			return 1
+		}
+	} else {
+		var s = L.LCheckString(1)
+		L.LArgCheck(2 <= base && base <= 36, 2, "base out of range")
+		s = strings.TrimLeft(s, " \f\n\r\t\v")
+		var neg = strings.HasPrefix(s, "-")
+		if neg || strings.HasPrefix(s, "+") {
+			s = s[1:]
+		}
+		if base == 16 && (strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X")) {
+			s = s[2:]
+		}
+		s = strings.TrimRight(s, " \f\n\r\t\v") /* skip trailing spaces */
+		if n, err := strconv.ParseUint(s, base, 64); err == nil || errors.Is(err, strconv.ErrRange) {
+			if neg { /* strtoul negates the result */
+				n = -n
+			}
+			L.PushNumber(golua.LuaNumber(n))
+			return 1
+		}
+	}
+	L.PushNil() /* else not a number */
+	return 1
+}
+
+// 对应C函数：`static int luaB_error (lua_State *L)'
+func luaError(L *LuaState) int {
+	var level = L.LOptInt(2, 1)
+	L.SetTop(1)
+	if L.IsString(1) && level > 0 { /* add extra information? */
+		L.LWhere(level)
+		L.PushValue(1)
+		L.Concat(2)
+	}
+	return L.Error()
+}
+
+// 对应C函数：`static int luaB_getmetatable (lua_State *L)'
+func getMetaTable(L *LuaState) int {
+	L.LCheckAny(1)
+	if L.GetMetaTable(1) == 0 {
+		L.PushNil()
+		return 1 /* no metatable */
+	}
+	L.LGetMetaField(1, "__metatable")
+	return 1 /* returns either __metatable field (if present) or metatable */
+}
+
+// 对应C函数：`static int luaB_setmetatable (lua_State *L)'
+func setMetaTable(L *LuaState) int {
+	var t = L.Type(2)
+	L.LCheckType(1, golua.LUA_TTABLE)
+	L.LArgCheck(t == golua.LUA_TNIL || t == golua.LUA_TTABLE, 2, "nil or table expected")
+	if L.LGetMetaField(1, "__metatable") {
+		L.LError("cannot change a protected metatable")
+	}
+	L.SetTop(2)
+	L.SetMetaTable(1)
+	return 1
+}
+
+// 对应C函数：`static void getfunc (lua_State *L, int opt)'
+func getFunc(L *LuaState, opt bool) {
+	if L.IsFunction(1) {
+		L.PushValue(1)
+	} else {
+		var ar golua.LuaDebug
+		var level int
+		if opt {
+			level = L.LOptInt(1, 1)
+		} else {
+			level = L.LCheckInt(1)
+		}
+		L.LArgCheck(level >= 0, 1, "level must be non-negative")
+		if !L.GetStack(level, &ar) {
+			L.LArgError(1, "invalid level")
+		}
+		L.GetInfo("f", &ar)
+		if L.IsNil(-1) {
+			L.LError("no function environment for tail call at level %d", level)
+		}
+	}
+}
+
+// 对应C函数：`static int luaB_getfenv (lua_State *L)'
+func getFEnv(L *LuaState) int {
+	getFunc(L, true)
+	if L.IsCFunction(-1) { /* is a C function? */
+		L.PushValue(golua.LUA_GLOBALSINDEX) /* return the thread's global env. */
+	} else {
+		L.GetFEnv(-1)
+	}
+	return 1
+}
+
+// 对应C函数：`static int luaB_setfenv (lua_State *L)'
+func setFEnv(L *LuaState) int {
+	L.LCheckType(2, golua.LUA_TTABLE)
+	getFunc(L, false)
+	L.PushValue(2)
+	if L.IsNumber(1) && L.ToNumber(1) == 0 {
+		/* change environment of current thread */
+		L.PushThread()
+		L.Insert(-2)
+		L.SetFEnv(-2)
+		return 0
+	} else if L.IsCFunction(-2) || L.SetFEnv(-2) == 0 {
+		L.LError("'setfenv' cannot change environment of given object")
+	}
+	return 1
+}
+
+// 对应C函数：`static int luaB_rawequal (lua_State *L)'
+func rawEqual(L *LuaState) int {
+	L.LCheckAny(1)
+	L.LCheckAny(2)
+	L.PushBoolean(L.RawEqual(1, 2))
+	return 1
+}
+
+// 对应C函数：`static int luaB_rawget (lua_State *L)'
+func rawGet(L *LuaState) int {
+	L.LCheckType(1, golua.LUA_TTABLE)
+	L.LCheckAny(2)
+	L.SetTop(2)
+	L.RawGet(1)
+	return 1
+}
+
+// 对应C函数：`static int luaB_rawset (lua_State *L)'
+func rawSet(L *LuaState) int {
+	L.LCheckType(1, golua.LUA_TTABLE)
+	L.LCheckAny(2)
+	L.LCheckAny(3)
+	L.SetTop(3)
+	L.RawSet(1)
+	return 1
+}
+
+// 对应C函数：`static int luaB_gcinfo (lua_State *L)'
+func gcInfo(L *LuaState) int {
+	L.PushInteger(L.GetGCCount())
+	return 1
+}
+
+// 对应C函数：`static int luaB_collectgarbage (lua_State *L)'
+func collectGarbage(L *LuaState) int {
+	var opts = []string{"stop", "restart", "collect",
+		"count", "step", "setpause", "setstepmul"}
+	var optsNum = []int{golua.LUA_GCSTOP, golua.LUA_GCRESTART, golua.LUA_GCCOLLECT,
+		golua.LUA_GCCOUNT, golua.LUA_GCSTEP, golua.LUA_GCSETPAUSE, golua.LUA_GCSETSTEPMUL}
+	var o = L.LCheckOption(1, "collect", opts)
+	var ex = L.LOptInt(2, 0)
+	var res = L.GC(optsNum[o], ex)
+	switch optsNum[o] {
+	case golua.LUA_GCCOUNT:
+		var b = L.GC(golua.LUA_GCCOUNTB, 0)
+		L.PushNumber(golua.LuaNumber(res) + golua.LuaNumber(b)/1024)
+	case golua.LUA_GCSTEP:
+		L.PushBoolean(res != 0)
+	default:
+		L.PushNumber(golua.LuaNumber(res))
+	}
+	return 1
+}
+
+// 对应C函数：`static int luaB_type (lua_State *L)'
+func luaType(L *LuaState) int {
+	L.LCheckAny(1)
+	L.PushString(L.LTypeName(1))
+	return 1
+}
+
 // ipairs
 // 对应C函数：`static int luaB_ipairs (lua_State *L)'
 func ipairs(L *LuaState) int {
@@ -58,6 +263,164 @@ func next(L *LuaState) int {
 	}
 }
 
+// 对应C函数：`static int load_aux (lua_State *L, int status)'
+func loadAux(L *LuaState, status int) int {
+	if status == 0 { /* OK? */
+		return 1
+	} else {
+		L.PushNil()
+		L.Insert(-2) /* put before error message */
+		return 2     /* return nil plus error message */
+	}
+}
+
+// 对应C函数：`static int luaB_loadstring (lua_State *L)'
+func loadString(L *LuaState) int {
+	var s, _ = L.LCheckLString(1)
+	var chunkName = L.LOptString(2, string(s))
+	return loadAux(L, L.LLoadBuffer(s, chunkName))
+}
+
+// 对应C函数：`static int luaB_loadfile (lua_State *L)'
+func loadFile(L *LuaState) int {
+	var fName []byte /* nil: load from stdin */
+	if !L.IsNoneOrNil(1) {
+		fName = []byte(L.LCheckString(1))
+	}
+	return loadAux(L, L.LLoadFile(fName))
+}
+
+// Reader for generic `load' function: `lua_load' uses the
+// stack for internal stuff, so the reader cannot change the
+// stack top. Instead, it keeps its resulting string in a
+// reserved slot inside the stack.
+const RESERVEDSLOT = 3
+
+// 对应C函数：`static const char *generic_reader (lua_State *L, void *ud, size_t *size)'
+func genericReader(L *LuaState, ud interface{}) ([]byte, int) {
+	L.LCheckStack(2, "too many nested functions")
+	L.PushValue(1) /* get function */
+	L.Call(0, 1)   /* call it */
+	if L.IsNil(-1) {
+		return nil, 0
+	} else if L.IsString(-1) {
+		L.Replace(RESERVEDSLOT) /* save string in a reserved stack slot */
+		return L.ToLString(RESERVEDSLOT)
+	}
+	L.LError("reader function must return a string")
+	return nil, 0
+}
+
+// 对应C函数：`static int luaB_load (lua_State *L)'
+func load(L *LuaState) int {
+	var cName = L.LOptString(2, "=(load)")
+	L.LCheckType(1, golua.LUA_TFUNCTION)
+	L.SetTop(RESERVEDSLOT) /* create reserved slot */
+	var status = L.Load(genericReader, nil, []byte(cName))
+	return loadAux(L, status)
+}
+
+// 对应C函数：`static int luaB_dofile (lua_State *L)'
+func doFile(L *LuaState) int {
+	var fName []byte /* nil: load from stdin */
+	if !L.IsNoneOrNil(1) {
+		fName = []byte(L.LCheckString(1))
+	}
+	var n = L.GetTop()
+	if L.LLoadFile(fName) != 0 {
+		L.Error()
+	}
+	L.Call(0, golua.LUA_MULTRET)
+	return L.GetTop() - n
+}
+
+// Assert
+// 对应C函数：`static int luaB_assert (lua_State *L)'
+func Assert(L *LuaState) int {
+	L.LCheckAny(1)
+	if !L.ToBoolean(1) {
+		return L.LError("%s", L.LOptString(2, "assertion failed!"))
+	}
+	return L.GetTop()
+}
+
+// 对应C函数：`static int luaB_unpack (lua_State *L)'
+func unpack(L *LuaState) int {
+	L.LCheckType(1, golua.LUA_TTABLE)
+	var i = L.LOptInt(2, 1)
+	var e int
+	if L.IsNoneOrNil(3) {
+		e = L.ObjLen(1)
+	} else {
+		e = L.LCheckInt(3)
+	}
+	if i > e {
+		return 0 /* empty range */
+	}
+	var n = e - i + 1               /* number of elements */
+	if n <= 0 || !L.CheckStack(n) { /* n <= 0 means arith. overflow */
+		return L.LError("too many results to unpack")
+	}
+	L.RawGetI(1, i) /* push arg[i] (avoiding overflow problems) */
+	for i < e {     /* push arg[i + 1...e] */
+		i++
+		L.RawGetI(1, i)
+	}
+	return n
+}
+
+// 对应C函数：`static int luaB_select (lua_State *L)'
+func luaSelect(L *LuaState) int {
+	var n = L.GetTop()
+	if L.Type(1) == golua.LUA_TSTRING && strings.HasPrefix(L.ToString(1), "#") {
+		L.PushInteger(n - 1)
+		return 1
+	} else {
+		var i = L.LCheckInt(1)
+		if i < 0 {
+			i = n + i
+		} else if i > n {
+			i = n
+		}
+		L.LArgCheck(1 <= i, 1, "index out of range")
+		return n - i
+	}
+}
+
+// 对应C函数：`static int luaB_pcall (lua_State *L)'
+func pcall(L *LuaState) int {
+	L.LCheckAny(1)
+	var status = L.PCall(L.GetTop()-1, golua.LUA_MULTRET, 0)
+	L.PushBoolean(status == 0)
+	L.Insert(1)
+	return L.GetTop() /* return status + all results */
+}
+
+// 对应C函数：`static int luaB_tostring (lua_State *L)'
+func toString(L *LuaState) int {
+	L.LCheckAny(1)
+	if L.LCallMeta(1, "__tostring") { /* is there a metafield? */
+		return 1 /* use its value */
+	}
+	switch L.Type(1) {
+	case golua.LUA_TNUMBER:
+		L.PushString(L.ToString(1))
+	case golua.LUA_TSTRING:
+		L.PushValue(1)
+	case golua.LUA_TBOOLEAN:
+		if L.ToBoolean(1) {
+			L.PushLiteral("true")
+		} else {
+			L.PushLiteral("false")
+		}
+	case golua.LUA_TNIL:
+		L.PushLiteral("nil")
+	default:
+		L.PushFString("%s: %p", L.LTypeName(1), L.ToPointer(1))
+	}
+	return 1
+}
+
 // 对应C函数：`static int luaB_newproxy (lua_State *L) '
 func newProxy(L *LuaState) int {
 	L.SetTop(1)
@@ -83,10 +446,177 @@ func newProxy(L *LuaState) int {
 	return 1
 }
 
-var coFuncs = []golua.LReg{}
+// 对应C函数：`static int luaB_xpcall (lua_State *L)'
+func xpcall(L *LuaState) int {
+	L.LCheckAny(2)
+	L.SetTop(2)
+	L.Insert(1) /* put error function under function to be called */
+	var status = L.PCall(0, golua.LUA_MULTRET, 1)
+	L.PushBoolean(status == 0)
+	L.Replace(1)
+	return L.GetTop() /* return status + all results */
+}
+
+// 协程的状态
+const (
+	CO_RUN = iota /* running */
+	CO_SUS        /* suspended */
+	CO_NOR        /* 'normal' (it resumed another coroutine) */
+	CO_DEAD
+)
+
+var statNames = []string{"running", "suspended", "normal", "dead"}
+
+// 对应C函数：`static int costatus (lua_State *L, lua_State *co)'
+func coStatus(L *LuaState, co *LuaState) int {
+	if L == co {
+		return CO_RUN
+	}
+	switch co.Status() {
+	case golua.LUA_YIELD:
+		return CO_SUS
+	case 0:
+		var ar golua.LuaDebug
+		if co.GetStack(0, &ar) { /* does it have frames? */
+			return CO_NOR /* it is running */
+		} else if co.GetTop() == 0 {
+			return CO_DEAD
+		} else {
+			return CO_SUS /* initial state */
+		}
+	default: /* some error occured */
+		return CO_DEAD
+	}
+}
+
+// 对应C函数：`static int luaB_costatus (lua_State *L)'
+func coStatusB(L *LuaState) int {
+	var co = L.LuaToThread(1)
+	L.LArgCheck(co != nil, 1, "coroutine expected")
+	L.PushString(statNames[coStatus(L, co)])
+	return 1
+}
+
+// 对应C函数：`static int auxresume (lua_State *L, lua_State *co, int narg)'
+func auxResume(L *LuaState, co *LuaState, nArg int) int {
+	var status = coStatus(L, co)
+	if !co.CheckStack(nArg) {
+		L.LError("too many arguments to resume")
+	}
+	if status != CO_SUS {
+		L.PushFString("cannot resume %s coroutine", statNames[status])
+		return -1 /* error flag */
+	}
+	L.XMove(co, nArg)
+	L.SetLevel(co)
+	status = co.Resume(nArg)
+	if status == 0 || status == golua.LUA_YIELD {
+		var nRes = co.GetTop()
+		if !L.CheckStack(nRes + 1) {
+			L.LError("too many results to resume")
+		}
+		co.XMove(L, nRes) /* move yielded values */
+		return nRes
+	} else {
+		co.XMove(L, 1) /* move error message */
+		return -1      /* error flag */
+	}
+}
+
+// 对应C函数：`static int luaB_coresume (lua_State *L)'
+func coResume(L *LuaState) int {
+	var co = L.LuaToThread(1)
+	L.LArgCheck(co != nil, 1, "coroutine expected")
+	var r = auxResume(L, co, L.GetTop()-1)
+	if r < 0 {
+		L.PushBoolean(false)
+		L.Insert(-2)
+		return 2 /* return false + error message */
+	} else {
+		L.PushBoolean(true)
+		L.Insert(-(r + 1))
+		return r + 1 /* return true + `resume' returns */
+	}
+}
+
+// 对应C函数：`static int auxwrap (lua_State *L)'
+func auxWrap(L *LuaState) int {
+	var co = L.LuaToThread(golua.LuaUpValueIndex(1))
+	var r = auxResume(L, co, L.GetTop())
+	if r < 0 {
+		if L.IsString(-1) { /* error object is a string? */
+			L.LWhere(1) /* get extra info */
+			L.Insert(-2)
+			L.Concat(2)
+		}
+		L.Error() /* propagate error */
+	}
+	return r
+}
+
+// 对应C函数：`static int luaB_cocreate (lua_State *L)'
+func coCreate(L *LuaState) int {
+	var NL = L.NewThread()
+	L.LArgCheck(L.IsFunction(1) && !L.IsCFunction(1), 1, "Lua function expected")
+	L.PushValue(1) /* move function to top */
+	L.XMove(NL, 1) /* move function from L to NL */
+	return 1
+}
+
+// 对应C函数：`static int luaB_cowrap (lua_State *L)'
+func coWrap(L *LuaState) int {
+	coCreate(L)
+	L.PushCClosure(auxWrap, 1)
+	return 1
+}
+
+// 对应C函数：`static int luaB_yield (lua_State *L)'
+func coYield(L *LuaState) int {
+	return L.Yield(L.GetTop())
+}
+
+// 对应C函数：`static int luaB_corunning (lua_State *L)'
+func coRunning(L *LuaState) int {
+	if L.PushThread() {
+		L.PushNil() /* main thread is not a coroutine */
+	}
+	return 1
+}
+
+var coFuncs = []golua.LReg{
+	{Name: "create", Func: coCreate},
+	{Name: "resume", Func: coResume},
+	{Name: "running", Func: coRunning},
+	{Name: "status", Func: coStatusB},
+	{Name: "wrap", Func: coWrap},
+	{Name: "yield", Func: coYield},
+}
 
 var baseFuncs = []golua.LReg{
-	{"assert", Assert},
+	{Name: "assert", Func: Assert},
+	{Name: "collectgarbage", Func: collectGarbage},
+	{Name: "dofile", Func: doFile},
+	{Name: "error", Func: luaError},
+	{Name: "gcinfo", Func: gcInfo},
+	{Name: "getfenv", Func: getFEnv},
+	{Name: "getmetatable", Func: getMetaTable},
+	{Name: "loadfile", Func: loadFile},
+	{Name: "load", Func: load},
+	{Name: "loadstring", Func: loadString},
+	{Name: "next", Func: next},
+	{Name: "pcall", Func: pcall},
+	{Name: "print", Func: print},
+	{Name: "rawequal", Func: rawEqual},
+	{Name: "rawget", Func: rawGet},
+	{Name: "rawset", Func: rawSet},
+	{Name: "select", Func: luaSelect},
+	{Name: "setfenv", Func: setFEnv},
+	{Name: "setmetatable", Func: setMetaTable},
+	{Name: "tonumber", Func: toNumber},
+	{Name: "tostring", Func: toString},
+	{Name: "type", Func: luaType},
+	{Name: "unpack", Func: unpack},
+	{Name: "xpcall", Func: xpcall},
 }
 
 // 对应C函数：`static void auxopen (lua_State *L, const char *name, lua_CFunction f, lua_CFunction u)'
@@ -0,0 +1,405 @@
+package lib
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	golua "luar/lua"
+)
+
+func TestFEnvSandbox(t *testing.T) {
+	var L = golua.LuaOpen()
+	defer L.Close()
+	OpenLibs(L)
+
+	/* a plugin whose globals live in a private table that falls back to _G */
+	if L.LLoadString("x = 1; y = print ~= nil; return getfenv(1) == getfenv(0)") != 0 {
+		t.Fatal(L.ToString(-1))
+	}
+	L.NewTable()
+	L.NewTable()
+	L.PushValue(golua.LUA_GLOBALSINDEX)
+	L.SetField(-2, "__index")
+	L.SetMetaTable(-2)
+	L.PushValue(-1)
+	L.Insert(1) /* keep the sandbox below the chunk */
+	if L.SetFEnv(-2) == 0 {
+		t.Fatal("cannot set the environment of a Lua function")
+	}
+	L.PushValue(-1)
+	L.GetFEnv(-1)
+	if !L.RawEqual(-1, 1) {
+		t.Fatal("GetFEnv does not return the table given to SetFEnv")
+	}
+	L.Pop(2)
+	if L.PCall(0, 1, 0) != 0 {
+		t.Fatal(L.ToString(-1))
+	}
+	if L.ToBoolean(-1) {
+		t.Error("getfenv(1) inside the plugin returned the thread's globals")
+	}
+	L.Pop(1)
+	L.GetField(1, "x")
+	L.GetField(1, "y")
+	L.GetGlobal("x")
+	if L.ToInteger(-3) != 1 || !L.ToBoolean(-2) || !L.IsNil(-1) {
+		t.Errorf("sandbox x=%v y=%v, global x=%v", L.ToInteger(-3), L.ToBoolean(-2), L.TypeName(L.Type(-1)))
+	}
+	L.SetTop(0)
+
+	/* userdata and threads */
+	L.NewUserData(0)
+	L.GetFEnv(-1)
+	if !L.RawEqual(-1, golua.LUA_GLOBALSINDEX) {
+		t.Error("a new userdata does not start with the globals as its environment")
+	}
+	L.Pop(1)
+	L.NewTable()
+	if L.SetFEnv(-2) == 0 {
+		t.Error("cannot set the environment of a userdata")
+	}
+	L.GetFEnv(-1)
+	if !L.IsTable(-1) || L.RawEqual(-1, golua.LUA_GLOBALSINDEX) {
+		t.Error("userdata environment was not changed")
+	}
+	L.SetTop(0)
+
+	var co = L.NewThread()
+	L.NewTable()
+	L.PushInteger(7)
+	L.SetField(-2, "seven")
+	if L.SetFEnv(1) == 0 {
+		t.Fatal("cannot set the environment of a thread")
+	}
+	if co.LDoStringErr("return seven == 7 and print == nil") != nil || !co.ToBoolean(-1) {
+		t.Error("thread does not see its own globals")
+	}
+	L.PushNumber(1)
+	L.NewTable()
+	if L.SetFEnv(-2) != 0 {
+		t.Error("SetFEnv accepted a number")
+	}
+	L.GetFEnv(-1)
+	if !L.IsNil(-1) {
+		t.Error("GetFEnv of a number is not nil")
+	}
+}
+
+func TestFEnvGoFunctionAndGC(t *testing.T) {
+	var L = golua.LuaOpen()
+	defer L.Close()
+	OpenLibs(L)
+
+	/* a Go function reads its environment through LUA_ENVIRONINDEX */
+	L.PushCFunction(func(L *LuaState) int {
+		L.GetField(golua.LUA_ENVIRONINDEX, "name")
+		return 1
+	})
+	L.NewTable()
+	L.PushString("private")
+	L.SetField(-2, "name")
+	if L.SetFEnv(-2) == 0 {
+		t.Fatal("cannot set the environment of a Go function")
+	}
+	L.SetGlobal("whoami")
+
+	/* the environments are only reachable through their owners */
+	L.NewUserData(0)
+	L.NewTable()
+	L.PushInteger(42)
+	L.SetField(-2, "answer")
+	L.SetFEnv(-2)
+	L.SetGlobal("u")
+	L.GC(golua.LUA_GCCOLLECT, 0)
+
+	if err := L.LDoStringErr(`
+	assert(whoami() == "private")
+	assert(getfenv(whoami) == _G)  -- as in C Lua, getfenv hides the environment of Go functions
+	collectgarbage()
+	return whoami()`); err != nil {
+		t.Fatal(err)
+	}
+	if L.ToString(-1) != "private" {
+		t.Errorf("Go function environment after GC: %q", L.ToString(-1))
+	}
+	L.SetTop(0)
+	L.GetGlobal("u")
+	L.GetFEnv(-1)
+	L.GetField(-1, "answer")
+	if L.ToInteger(-1) != 42 {
+		t.Errorf("userdata environment after GC: %v", L.ToString(-1))
+	}
+}
+
+func TestGetSetFEnv(t *testing.T) {
+	err := doString(t, `
+	local G = getfenv()
+	assert(G == _G and getfenv(0) == _G and getfenv(print) == _G)
+	local env = setmetatable({}, {__index = _G})
+	local function f() return who end
+	assert(setfenv(f, env) == f and getfenv(f) == env)
+	env.who = "sandbox"
+	who = "global"
+	assert(f() == "sandbox")
+
+	local function g()
+		setfenv(1, {result = "changed"})
+		return result
+	end
+	assert(g() == "changed" and getfenv(g).result == "changed")
+
+	local function level2() return getfenv(2) end
+	local function h() local e = level2(); return e end
+	setfenv(h, env)
+	assert(h() == env)
+
+	local ok, msg = pcall(setfenv, print, {})
+	assert(not ok and msg:find("'setfenv' cannot change environment of given object", 1, true), msg)
+	ok, msg = pcall(getfenv, -1)
+	assert(not ok and msg:find("level must be non-negative", 1, true), msg)
+	ok, msg = pcall(getfenv, 50)
+	assert(not ok and msg:find("invalid level", 1, true), msg)
+	ok, msg = pcall(setfenv, f, 1)
+	assert(not ok and msg:find("table expected", 1, true), msg)
+
+	setfenv(0, setmetatable({marker = "thread"}, {__index = G}))
+	assert(marker == nil and getfenv(0).marker == "thread")  -- running functions keep their env
+	assert(loadstring("return marker")() == "thread")        -- new functions get the thread's
+	setfenv(0, G)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	err := doString(t, `
+	local function count(t)
+		local n = 0
+		for _ in pairs(t) do n = n + 1 end
+		return n
+	end
+
+	-- weak tables drop the entries whose keys or values are collected
+	local keep = {}
+	local k = setmetatable({}, {__mode = "k"})
+	local v = setmetatable({}, {__mode = "v"})
+	local kv = setmetatable({}, {__mode = "kv"})
+	k[keep] = 1; k[{}] = 2; k.s = {}
+	v[1] = keep; v[2] = {}; v[3] = "str"; v.x = function() end
+	kv[keep] = keep; kv[{}] = 1; kv[1] = {}; kv.s = "s"
+	collectgarbage()
+	assert(count(k) == 2 and k[keep] == 1 and k.s)
+	assert(count(v) == 2 and v[1] == keep and v[3] == "str")
+	assert(count(kv) == 2 and kv[keep] == keep and kv.s == "s")
+
+	-- __gc finalizers run once, after the userdata became unreachable
+	local log = {}
+	local p = newproxy(true)
+	getmetatable(p).__gc = function(u) log[#log + 1] = u end
+	local alive = newproxy(p)
+	p = nil
+	for i = 1, 3 do newproxy(alive) end
+	collectgarbage()
+	assert(#log == 4, #log)
+	log = {}
+	collectgarbage()
+	assert(#log == 0)
+
+	-- memory is accounted and released
+	local before = collectgarbage("count")
+	local big = {}
+	for i = 1, 10000 do big[i] = {i} end
+	local during = collectgarbage("count")
+	assert(during > before + 100, during - before)
+	big = nil
+	collectgarbage("collect")
+	assert(collectgarbage("count") < during - 100)
+
+	-- step finishes a cycle eventually; pause and stepmul return the old values
+	repeat until collectgarbage("step")
+	assert(collectgarbage("setpause", 100) == 200)
+	assert(collectgarbage("setpause", 200) == 100)
+	assert(collectgarbage("setstepmul", 400) == 200)
+	assert(collectgarbage("setstepmul", 200) == 400)
+	collectgarbage("stop")
+	local stopped = collectgarbage("count")
+	for i = 1, 1000 do local _ = {} end
+	assert(collectgarbage("count") > stopped)
+	collectgarbage("restart")
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemLimit(t *testing.T) {
+	L := golua.LuaOpen()
+	defer L.Close()
+	OpenLibs(L)
+	L.GC(golua.LUA_GCCOLLECT, 0)
+	L.SetMemLimit(L.GC(golua.LUA_GCCOUNT, 0)<<10 + 1<<20) /* 1M above the current use */
+	for _, src := range []string{
+		`local s = string.rep("x", 1e9)`,
+		`local t = {} for i = 1, 1e9 do t[i] = i end`,
+		`local t = {} for i = 1, 1e9 do t["k" .. i] = true end`,
+	} {
+		if status := L.LDoString(src); status != golua.LUA_ERRMEM {
+			t.Fatalf("%s: status %d, want LUA_ERRMEM: %s", src, status, L.ToString(-1))
+		}
+		if msg := L.ToString(-1); msg != "not enough memory" {
+			t.Errorf("%s: message %q", src, msg)
+		}
+		L.Pop(1)
+	}
+	/* garbage is collected before the limit is reached */
+	if L.LDoString(`
+		for i = 1, 10000 do local s = string.rep("x", 1000) .. i end
+		assert(not pcall(string.rep, "x", 1e9))`) != 0 {
+		t.Fatal(L.ToString(-1))
+	}
+	if L.SetMemLimit(0) == 0 {
+		t.Error("SetMemLimit must return the previous limit")
+	}
+}
+
+func TestPrint(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout = os.Stdout
+	os.Stdout = w
+	err = doString(t, `
+	print(1, "two", nil, true)
+	print()
+	local mt = {__tostring = function() return "custom" end}
+	print(setmetatable({}, mt))
+	tostring = function() return {} end
+	assert(not pcall(print, "x")) -- tostring must return a string
+	`)
+	os.Stdout = stdout
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _ := io.ReadAll(r)
+	if string(out) != "1\ttwo\tnil\ttrue\n\ncustom\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestBaseConversions(t *testing.T) {
+	err := doString(t, `
+	assert(tostring(nil) == "nil" and tostring(true) == "true" and tostring(12) == "12")
+	assert(tostring(1.5) == "1.5" and tostring("s") == "s")
+	assert(string.find(tostring({}), "^table: ") and string.find(tostring(print), "^function: "))
+	assert(not pcall(tostring))
+
+	assert(tonumber("10") == 10 and tonumber("  0x1F  ") == 31 and tonumber("1e2") == 100)
+	assert(tonumber("z", 36) == 35 and tonumber("ff", 16) == 255 and tonumber("777", 8) == 511)
+	assert(tonumber("  11  ", 2) == 3 and tonumber("12", 2) == nil)
+	assert(tonumber("abc") == nil and tonumber({}) == nil and tonumber("") == nil)
+	assert(not pcall(tonumber, "10", 1) and not pcall(tonumber, "10", 37))
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSelectAndUnpack(t *testing.T) {
+	err := doString(t, `
+	assert(select("#") == 0 and select("#", nil, nil) == 2)
+	assert(select(2, "a", "b", "c") == "b" and select(-1, "a", "b", "c") == "c")
+	local a, b = select(-2, "a", "b", "c")
+	assert(a == "b" and b == "c")
+	assert(select(5, "a") == nil)
+	assert(not pcall(select, 0, "a") and not pcall(select, -2, "a"))
+
+	local t = {1, 2, 3, nil, 5}
+	assert(select("#", unpack({})) == 0)
+	local x, y, z = unpack(t)
+	assert(x == 1 and y == 2 and z == 3)
+	assert(select("#", unpack(t, 2, 5)) == 4 and select(4, unpack(t, 2, 5)) == 5)
+	assert(select("#", unpack(t, 3, 2)) == 0)
+	local p, q = unpack(t, -1, 0)
+	assert(p == nil and q == nil and select("#", unpack(t, -1, 0)) == 2)
+	assert(not pcall(unpack, {}, 1, 1e8)) -- too many results
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestErrorAndProtectedCalls(t *testing.T) {
+	err := doString(t, `
+	local function lvl1() error("one") end
+	local function lvl2() error("two", 2) end
+	local function callsLvl2() lvl2() end
+	local ok, msg = pcall(lvl1)
+	assert(not ok and string.find(msg, ":2: one$"), msg)
+	ok, msg = pcall(callsLvl2)
+	assert(not ok and string.find(msg, ":4: two$"), msg)
+	ok, msg = pcall(error, "plain", 0)
+	assert(not ok and msg == "plain")
+	local e = {}
+	ok, msg = pcall(error, e)
+	assert(not ok and msg == e) -- error objects other than strings are kept
+	ok, msg = pcall(error)
+	assert(not ok and msg == nil)
+
+	assert(select("#", pcall(function() return 1, 2 end)) == 3)
+	assert(not pcall(pcall))
+
+	local function handler(m) return "handled: " .. m end
+	ok, msg = xpcall(function() error("x", 0) end, handler)
+	assert(not ok and msg == "handled: x")
+	local r1, r2 = xpcall(function() return "fine" end, handler)
+	assert(r1 == true and r2 == "fine")
+	ok, msg = xpcall(function() error("x") end, function() error("again") end)
+	assert(not ok and msg == "error in error handling", msg)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* a failing message handler turns the status into LUA_ERRERR */
+	var L = golua.LuaOpen()
+	defer L.Close()
+	OpenLibs(L)
+	L.PushCFunction(func(L *LuaState) int { return L.LError("again") })
+	if L.LLoadString(`error("x")`) != 0 {
+		t.Fatal(L.ToString(-1))
+	}
+	if status := L.PCall(0, 0, 1); status != golua.LUA_ERRERR || L.ToString(-1) != "error in error handling" {
+		t.Errorf("status %d, message %q", status, L.ToString(-1))
+	}
+}
+
+func TestLoadWithReader(t *testing.T) {
+	err := doString(t, `
+	local parts = {"return ", "1 ", "+ ", "41"}
+	local i = 0
+	local f = load(function() i = i + 1 return parts[i] end, "=pieces")
+	assert(f() == 42)
+
+	local f2, msg = load(function() return nil end)
+	assert(f2 and f2() == nil) -- empty chunk
+
+	f2, msg = load(function() return "x = " end, "=bad")
+	assert(f2 == nil and string.find(msg, "^bad:"), msg)
+	f2, msg = load(function() return {} end)
+	assert(f2 == nil and string.find(msg, "reader function must return a string"), msg)
+
+	local env = {}
+	f = loadstring("y = 5")
+	setfenv(f, env)
+	f()
+	assert(env.y == 5 and y == nil)
+	assert(loadstring("return ...", "=va")(7) == 7)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
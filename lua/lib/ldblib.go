@@ -0,0 +1,374 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	golua "luar/lua"
+)
+
+// 对应C函数：`static int db_getregistry (lua_State *L)'
+func dbGetRegistry(L *LuaState) int {
+	L.PushValue(golua.LUA_REGISTRYINDEX)
+	return 1
+}
+
+// 对应C函数：`static int db_getmetatable (lua_State *L)'
+func dbGetMetaTable(L *LuaState) int {
+	L.LCheckAny(1)
+	if L.GetMetaTable(1) == 0 {
+		L.PushNil() /* no metatable */
+	}
+	return 1
+}
+
+// 对应C函数：`static int db_setmetatable (lua_State *L)'
+func dbSetMetaTable(L *LuaState) int {
+	var t = L.Type(2)
+	L.LArgCheck(t == golua.LUA_TNIL || t == golua.LUA_TTABLE, 2, "nil or table expected")
+	L.SetTop(2)
+	L.PushBoolean(L.SetMetaTable(1) != 0)
+	return 1
+}
+
+// 对应C函数：`static int db_getfenv (lua_State *L)'
+func dbGetFEnv(L *LuaState) int {
+	L.LCheckAny(1)
+	L.GetFEnv(1)
+	return 1
+}
+
+// 对应C函数：`static int db_setfenv (lua_State *L)'
+func dbSetFEnv(L *LuaState) int {
+	L.LCheckType(2, golua.LUA_TTABLE)
+	L.SetTop(2)
+	if L.SetFEnv(1) == 0 {
+		L.LError("'setfenv' cannot change environment of given object")
+	}
+	return 1
+}
+
+// 对应C函数：`static void settabss (lua_State *L, const char *i, const char *v)'
+func setTabSS(L *LuaState, i string, v string) {
+	L.PushString(v)
+	L.SetField(-2, i)
+}
+
+// 对应C函数：`static void settabsi (lua_State *L, const char *i, int v)'
+func setTabSI(L *LuaState, i string, v int) {
+	L.PushInteger(v)
+	L.SetField(-2, i)
+}
+
+// 对应C函数：`static lua_State *getthread (lua_State *L, int *arg)'
+func getThread(L *LuaState) (L1 *LuaState, arg int) {
+	if L.IsThread(1) {
+		return L.LuaToThread(1), 1
+	}
+	return L, 0
+}
+
+// 对应C函数：`static void treatstackoption (lua_State *L, lua_State *L1, const char *fname)'
+func treatStackOption(L *LuaState, L1 *LuaState, fName string) {
+	if L == L1 {
+		L.PushValue(-2)
+		L.Remove(-3)
+	} else {
+		L1.XMove(L, 1)
+	}
+	L.SetField(-2, fName)
+}
+
+// 对应C函数：`static int db_getinfo (lua_State *L)'
+func dbGetInfo(L *LuaState) int {
+	var ar golua.LuaDebug
+	var L1, arg = getThread(L)
+	var options = L.LOptString(arg+2, "flnSu")
+	if L.IsNumber(arg + 1) {
+		if !L1.GetStack(int(L.ToInteger(arg+1)), &ar) {
+			L.PushNil() /* level out of range */
+			return 1
+		}
+	} else if L.IsFunction(arg + 1) {
+		options = ">" + options
+		L.PushValue(arg + 1)
+		L.XMove(L1, 1)
+	} else {
+		return L.LArgError(arg+1, "function or level expected")
+	}
+	if !L1.GetInfo(options, &ar) {
+		return L.LArgError(arg+2, "invalid option")
+	}
+	L.CreateTable(0, 2)
+	if strings.Contains(options, "S") {
+		setTabSS(L, "source", ar.Source)
+		setTabSS(L, "short_src", string(ar.ShortSrc))
+		setTabSI(L, "linedefined", ar.LineDefined)
+		setTabSI(L, "lastlinedefined", ar.LastLineDefined)
+		setTabSS(L, "what", ar.What)
+	}
+	if strings.Contains(options, "l") {
+		setTabSI(L, "currentline", ar.CurrentLine)
+	}
+	if strings.Contains(options, "u") {
+		setTabSI(L, "nups", ar.NUps)
+	}
+	if strings.Contains(options, "n") {
+		if ar.Name != "" {
+			setTabSS(L, "name", ar.Name)
+		}
+		setTabSS(L, "namewhat", ar.NameWhat)
+	}
+	if strings.Contains(options, "L") {
+		treatStackOption(L, L1, "activelines")
+	}
+	if strings.Contains(options, "f") {
+		treatStackOption(L, L1, "func")
+	}
+	return 1 /* return table */
+}
+
+// 对应C函数：`static int db_getlocal (lua_State *L)'
+func dbGetLocal(L *LuaState) int {
+	var L1, arg = getThread(L)
+	var ar golua.LuaDebug
+	if !L1.GetStack(L.LCheckInt(arg+1), &ar) { /* out of range? */
+		return L.LArgError(arg+1, "level out of range")
+	}
+	if name, ok := L1.GetLocal(&ar, L.LCheckInt(arg+2)); ok {
+		L1.XMove(L, 1)
+		L.PushString(name)
+		L.PushValue(-2)
+		return 2
+	}
+	L.PushNil()
+	return 1
+}
+
+// 对应C函数：`static int db_setlocal (lua_State *L)'
+func dbSetLocal(L *LuaState) int {
+	var L1, arg = getThread(L)
+	var ar golua.LuaDebug
+	if !L1.GetStack(L.LCheckInt(arg+1), &ar) { /* out of range? */
+		return L.LArgError(arg+1, "level out of range")
+	}
+	L.LCheckAny(arg + 3)
+	L.SetTop(arg + 3)
+	L.XMove(L1, 1)
+	if name, ok := L1.SetLocal(&ar, L.LCheckInt(arg+2)); ok {
+		L.PushString(name)
+	} else {
+		L.PushNil()
+	}
+	return 1
+}
+
+// 对应C函数：`static int auxupvalue (lua_State *L, int get)'
+func auxUpvalue(L *LuaState, get bool) int {
+	var name string
+	var ok bool
+	var n = L.LCheckInt(2)
+	L.LCheckType(1, golua.LUA_TFUNCTION)
+	if L.IsCFunction(1) {
+		return 0 /* cannot touch C upvalues from Lua */
+	}
+	if get {
+		name, ok = L.GetUpvalue(1, n)
+	} else {
+		name, ok = L.SetUpvalue(1, n)
+	}
+	if !ok {
+		return 0
+	}
+	L.PushString(name)
+	if get {
+		L.Insert(-2)
+		return 2
+	}
+	return 1
+}
+
+// 对应C函数：`static int db_getupvalue (lua_State *L)'
+func dbGetUpvalue(L *LuaState) int {
+	return auxUpvalue(L, true)
+}
+
+// 对应C函数：`static int db_setupvalue (lua_State *L)'
+func dbSetUpvalue(L *LuaState) int {
+	L.LCheckAny(3)
+	return auxUpvalue(L, false)
+}
+
+// 注册表中钩子表的键，对应C中的`KEY_HOOK'
+var keyHook = new(byte)
+
+var hookNames = []string{"call", "return", "line", "count", "tail return"}
+
+// 对应C函数：`static void hookf (lua_State *L, lua_Debug *ar)'
+func hookF(L *LuaState, ar *golua.LuaDebug) {
+	L.PushLightUserData(keyHook)
+	L.RawGet(golua.LUA_REGISTRYINDEX)
+	L.PushLightUserData(L)
+	L.RawGet(-2)
+	if L.IsFunction(-1) {
+		L.PushString(hookNames[ar.Event])
+		if ar.CurrentLine >= 0 {
+			L.PushInteger(ar.CurrentLine)
+		} else {
+			L.PushNil()
+		}
+		golua.LuaAssert(L.GetInfo("lS", ar))
+		L.Call(2, 0)
+	}
+}
+
+// 对应C函数：`static int makemask (const char *smask, int count)'
+func makeMask(sMask string, count int) int {
+	var mask = 0
+	if strings.Contains(sMask, "c") {
+		mask |= golua.LUA_MASKCALL
+	}
+	if strings.Contains(sMask, "r") {
+		mask |= golua.LUA_MASKRET
+	}
+	if strings.Contains(sMask, "l") {
+		mask |= golua.LUA_MASKLINE
+	}
+	if count > 0 {
+		mask |= golua.LUA_MASKCOUNT
+	}
+	return mask
+}
+
+// 对应C函数：`static char *unmakemask (int mask, char *smask)'
+func unmakeMask(mask int) string {
+	var sMask []byte
+	if mask&golua.LUA_MASKCALL != 0 {
+		sMask = append(sMask, 'c')
+	}
+	if mask&golua.LUA_MASKRET != 0 {
+		sMask = append(sMask, 'r')
+	}
+	if mask&golua.LUA_MASKLINE != 0 {
+		sMask = append(sMask, 'l')
+	}
+	return string(sMask)
+}
+
+// 对应C函数：`static void gethooktable (lua_State *L)'
+func getHookTable(L *LuaState) {
+	L.PushLightUserData(keyHook)
+	L.RawGet(golua.LUA_REGISTRYINDEX)
+	if !L.IsTable(-1) {
+		L.Pop(1)
+		L.CreateTable(0, 1)
+		L.PushLightUserData(keyHook)
+		L.PushValue(-2)
+		L.RawSet(golua.LUA_REGISTRYINDEX)
+	}
+}
+
+// 对应C函数：`static int db_sethook (lua_State *L)'
+func dbSetHook(L *LuaState) int {
+	var mask, count int
+	var fn golua.LuaHook
+	var L1, arg = getThread(L)
+	if L.IsNoneOrNil(arg + 1) {
+		L.SetTop(arg + 1)
+		fn, mask, count = nil, 0, 0 /* turn off hooks */
+	} else {
+		var sMask = L.LCheckString(arg + 2)
+		L.LCheckType(arg+1, golua.LUA_TFUNCTION)
+		count = L.LOptInt(arg+3, 0)
+		fn, mask = hookF, makeMask(sMask, count)
+	}
+	getHookTable(L)
+	L.PushLightUserData(L1)
+	L.PushValue(arg + 1)
+	L.RawSet(-3)                /* set new hook */
+	L.Pop(1)                    /* remove hook table */
+	L1.SetHook(fn, mask, count) /* set hooks */
+	return 0
+}
+
+// 对应C函数：`static int db_gethook (lua_State *L)'
+func dbGetHook(L *LuaState) int {
+	var L1, _ = getThread(L)
+	var mask = L1.GetHookMask()
+	var hook = L1.GetHook()
+	if hook != nil && reflect.ValueOf(hook).Pointer() != reflect.ValueOf(hookF).Pointer() { /* external hook? */
+		L.PushLiteral("external hook")
+	} else {
+		getHookTable(L)
+		L.PushLightUserData(L1)
+		L.RawGet(-2) /* get hook */
+		L.Remove(-2) /* remove hook table */
+	}
+	L.PushString(unmakeMask(mask))
+	L.PushInteger(L1.GetHookCount())
+	return 3
+}
+
+// 对应C函数：`static int db_debug (lua_State *L)'
+func dbDebug(L *LuaState) int {
+	var in = bufio.NewReader(os.Stdin)
+	for {
+		fmt.Fprint(os.Stderr, "lua_debug> ")
+		var line, err = in.ReadString('\n')
+		if (err != nil && line == "") || line == "cont\n" {
+			return 0
+		}
+		if L.LLoadBuffer([]byte(line), "=(debug command)") != 0 ||
+			L.PCall(0, 0, 0) != 0 {
+			fmt.Fprintln(os.Stderr, L.ToString(-1))
+		}
+		L.SetTop(0) /* remove eventual returns */
+	}
+}
+
+// 对应C函数：`static int db_errorfb (lua_State *L)'
+func dbErrorFB(L *LuaState) int {
+	var level int
+	var L1, arg = getThread(L)
+	if L.IsNumber(arg + 2) {
+		level = int(L.ToInteger(arg + 2))
+		L.Pop(1)
+	} else if L == L1 {
+		level = 1 /* level 0 may be this own function */
+	}
+	if L.GetTop() == arg {
+		L.PushString(L.Traceback(L1, "", level))
+	} else if !L.IsString(arg + 1) {
+		return 1 /* message is not a string */
+	} else {
+		L.PushString(L.ToString(arg+1) + "\n" + L.Traceback(L1, "", level))
+	}
+	return 1
+}
+
+var dbLib = []golua.LReg{
+	{Name: "debug", Func: dbDebug},
+	{Name: "getfenv", Func: dbGetFEnv},
+	{Name: "gethook", Func: dbGetHook},
+	{Name: "getinfo", Func: dbGetInfo},
+	{Name: "getlocal", Func: dbGetLocal},
+	{Name: "getregistry", Func: dbGetRegistry},
+	{Name: "getmetatable", Func: dbGetMetaTable},
+	{Name: "getupvalue", Func: dbGetUpvalue},
+	{Name: "setfenv", Func: dbSetFEnv},
+	{Name: "sethook", Func: dbSetHook},
+	{Name: "setlocal", Func: dbSetLocal},
+	{Name: "setmetatable", Func: dbSetMetaTable},
+	{Name: "setupvalue", Func: dbSetUpvalue},
+	{Name: "traceback", Func: dbErrorFB},
+}
+
+// LuaOpenDebug
+// 对应C函数：`LUALIB_API int luaopen_debug (lua_State *L)'
+func LuaOpenDebug(L *LuaState) int {
+	L.LRegister(LUA_DBLIBNAME, dbLib)
+	return 1
+}
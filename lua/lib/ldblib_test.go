@@ -0,0 +1,134 @@
+package lib
+
+import "testing"
+
+func TestDebugGetInfo(t *testing.T) {
+	err := doString(t, `
+	local function f(a, b)
+		local info = debug.getinfo(1, "nSlu")
+		return info
+	end
+	local info = f()
+	assert(info.name == "f" and info.namewhat == "local")
+	assert(info.what == "Lua" and string.find(info.short_src, "^%[string"))
+	assert(info.linedefined == 2 and info.lastlinedefined == 5)
+	assert(info.currentline == 3 and info.nups == 0)
+	assert(info.func == nil and info.activelines == nil) -- not requested
+
+	local c = debug.getinfo(print)
+	assert(c.what == "C" and c.short_src == "[C]" and c.currentline == -1)
+	local l = debug.getinfo(f, "fL")
+	assert(l.func == f and l.activelines[3] and l.activelines[4] and not l.activelines[2])
+	assert(debug.getinfo(100) == nil)
+	assert(not pcall(debug.getinfo, 1, ">"))
+	assert(not pcall(debug.getinfo, {}))
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDebugLocalsAndUpvalues(t *testing.T) {
+	err := doString(t, `
+	local function f(a, b)
+		local c = a + b
+		assert(debug.getlocal(1, 1) == "a")
+		local name, v = debug.getlocal(1, 3)
+		assert(name == "c" and v == 3)
+		assert(debug.setlocal(1, 3, 10) == "c")
+		assert(debug.getlocal(1, 100) == nil)
+		return c
+	end
+	assert(f(1, 2) == 10)
+	assert(not pcall(debug.getlocal, 100, 1))
+
+	local up1, up2 = 1, "two"
+	local function g() return up1, up2 end
+	assert(select("#", debug.getupvalue(g, 1)) == 2)
+	local n, v = debug.getupvalue(g, 2)
+	assert(n == "up2" and v == "two")
+	assert(debug.getupvalue(g, 3) == nil)
+	assert(debug.setupvalue(g, 1, 42) == "up1" and up1 == 42)
+	assert(debug.getupvalue(print, 1) == nil) -- C functions have no named upvalues
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDebugHooks(t *testing.T) {
+	err := doString(t, `
+	assert(debug.gethook() == nil)
+	local events = {}
+	local function hook(event, line)
+		events[event] = (events[event] or 0) + 1
+	end
+	debug.sethook(hook, "crl")
+	local function f() return 1 end
+	f()
+	debug.sethook()
+	assert(events.call >= 1 and events["return"] >= 1 and events.line >= 1)
+
+	local counts = 0
+	debug.sethook(function() counts = counts + 1 end, "", 10)
+	local h, mask, count = debug.gethook()
+	assert(type(h) == "function" and mask == "" and count == 10)
+	for i = 1, 100 do end
+	debug.sethook()
+	assert(counts > 0)
+
+	debug.sethook(hook, "cr")
+	h, mask, count = debug.gethook()
+	debug.sethook()
+	assert(h == hook and mask == "cr" and count == 0)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDebugTraceback(t *testing.T) {
+	err := doString(t, `
+	local function lvl2() local tb = debug.traceback("msg", 1) return tb end
+	local function lvl1() local tb = lvl2() return tb end -- no tail calls
+	local tb = lvl1()
+	assert(string.find(tb, "^msg\nstack traceback:\n"), tb)
+	assert(string.find(tb, "in function 'lvl2'") and string.find(tb, "in function 'lvl1'"), tb)
+	local skip = (function() local s = debug.traceback("", 2) return s end)()
+	assert(not string.find(skip, "in function <"), skip)
+	local m = {}
+	assert(debug.traceback(m) == m) -- non-string messages are returned untouched
+
+	local co = coroutine.create(function() coroutine.yield() end)
+	coroutine.resume(co)
+	assert(string.find(debug.traceback(co), "in function 'yield'"), debug.traceback(co))
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDebugEnvAndRegistry(t *testing.T) {
+	err := doString(t, `
+	local function f() return x end
+	local env = {x = "sandboxed"}
+	assert(debug.setfenv(f, env) == f and debug.getfenv(f) == env and f() == "sandboxed")
+	assert(not pcall(debug.setfenv, f, 1))
+	local u = newproxy()
+	assert(type(debug.getfenv(u)) == "table")
+	debug.setfenv(u, env)
+	assert(debug.getfenv(u) == env)
+	assert(debug.getfenv(1) == nil) -- numbers have no environment
+
+	local reg = debug.getregistry()
+	assert(type(reg) == "table" and reg._LOADED and reg._LOADED.debug == debug)
+
+	local mt = {}
+	assert(debug.setmetatable(10, mt) == true and debug.getmetatable(1) == mt)
+	debug.setmetatable(10, nil)
+	assert(getmetatable(1) == nil)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
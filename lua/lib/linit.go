@@ -6,7 +6,14 @@ import golua "luar/lua"
 // 对应C函数：`LUALIB_API void luaL_openlibs (lua_State *L)'
 func OpenLibs(L *LuaState) {
 	var libs = []golua.LReg{
-		{"", LuaOpenBase},
+		{Name: "", Func: LuaOpenBase},
+		{Name: LUA_LOADLIBNAME, Func: LuaOpenPackage},
+		{Name: LUA_TABLIBNAME, Func: LuaOpenTable},
+		{Name: LUA_IOLIBNAME, Func: LuaOpenIO},
+		{Name: LUA_OSLIBNAME, Func: LuaOpenOS},
+		{Name: LUA_STRLIBNAME, Func: LuaOpenString},
+		{Name: LUA_MATHLIBNAME, Func: LuaOpenMath},
+		{Name: LUA_DBLIBNAME, Func: LuaOpenDebug},
 	}
 	for _, l := range libs {
 		L.PushCFunction(l.Func)
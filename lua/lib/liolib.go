@@ -0,0 +1,606 @@
+package lib
+
+import (
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	golua "luar/lua"
+)
+
+const (
+	IO_INPUT  = 1
+	IO_OUTPUT = 2
+)
+
+var fNames = []string{"input", "output"}
+
+// strError 对应C中的strerror(errno)，同时返回错误码；不是系统错误时错误码为0
+func strError(err error) (string, int) {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) { /* the file name is added by the caller */
+		err = pathErr.Err
+	}
+	var en syscall.Errno
+	if errors.As(err, &en) {
+		return en.Error(), int(en)
+	}
+	return err.Error(), 0
+}
+
+// 对应C函数：`static int pushresult (lua_State *L, int i, const char *filename)'
+// C中根据i判断是否成功并从errno取得错误，这里err为nil表示成功
+func pushResult(L *LuaState, err error, filename string) int {
+	if err == nil {
+		L.PushBoolean(true)
+		return 1
+	}
+	var msg, en = strError(err)
+	L.PushNil()
+	if filename != "" {
+		L.PushFString("%s: %s", filename, msg)
+	} else {
+		L.PushFString("%s", msg)
+	}
+	L.PushInteger(en)
+	return 3
+}
+
+// 对应C函数：`static void fileerror (lua_State *L, int arg, const char *filename)'
+func fileError(L *LuaState, arg int, filename string, err error) {
+	var msg, _ = strError(err)
+	L.PushFString("%s: %s", filename, msg)
+	L.LArgError(arg, L.ToString(-1))
+}
+
+// 对应C函数：`tofilep(L)'
+func toFileP(L *LuaState) *golua.Udata {
+	return L.LCheckUData(1, LUA_FILEHANDLE)
+}
+
+// 对应C函数：`static int io_type (lua_State *L)'
+func ioType(L *LuaState) int {
+	L.LCheckAny(1)
+	var ud, _ = L.ToUserData(1).(*golua.Udata)
+	L.GetField(golua.LUA_REGISTRYINDEX, LUA_FILEHANDLE)
+	if ud == nil || L.GetMetaTable(1) == 0 || !L.RawEqual(-2, -1) {
+		L.PushNil() /* not a file */
+	} else if ud.Value() == nil {
+		L.PushLiteral("closed file")
+	} else {
+		L.PushLiteral("file")
+	}
+	return 1
+}
+
+// 对应C函数：`static FILE *tofile (lua_State *L)'
+func toFile(L *LuaState) *golua.FILE {
+	var f, _ = toFileP(L).Value().(*golua.FILE)
+	if f == nil {
+		L.LError("attempt to use a closed file")
+	}
+	return f
+}
+
+// newFile 压入一个处于关闭状态的文件句柄，打开的文件由调用者通过SetValue设置
+// 对应C函数：`static FILE **newfile (lua_State *L)'
+func newFile(L *LuaState) *golua.Udata {
+	L.NewUserData(0)
+	var pf = L.ToUserData(-1).(*golua.Udata) /* file handle is currently `closed' */
+	L.LGetMetaTable(LUA_FILEHANDLE)
+	L.SetMetaTable(-2)
+	return pf
+}
+
+/*
+** this function has a separated environment, which defines the
+** correct __close for 'popen' files
+ */
+// 对应C函数：`static int io_pclose (lua_State *L)'
+func ioPClose(L *LuaState) int {
+	var p = toFileP(L)
+	var err = p.Value().(*golua.FILE).Fclose()
+	p.SetValue(nil)
+	return pushResult(L, err, "")
+}
+
+// 对应C函数：`static int io_noclose (lua_State *L)'
+func ioNoClose(L *LuaState) int {
+	L.PushNil()
+	L.PushLiteral("cannot close standard file")
+	return 2
+}
+
+// 对应C函数：`static int io_fclose (lua_State *L)'
+func ioFClose(L *LuaState) int {
+	var p = toFileP(L)
+	var err = p.Value().(*golua.FILE).Fclose()
+	p.SetValue(nil)
+	return pushResult(L, err, "")
+}
+
+// 对应C函数：`static int aux_close (lua_State *L)'
+func auxClose(L *LuaState) int {
+	L.GetFEnv(1)
+	L.GetField(-1, "__close")
+	return L.ToCFunction(-1)(L)
+}
+
+// 对应C函数：`static int io_close (lua_State *L)'
+func ioClose(L *LuaState) int {
+	if L.IsNone(1) {
+		L.RawGetI(golua.LUA_ENVIRONINDEX, IO_OUTPUT)
+	}
+	toFile(L) /* make sure argument is a file */
+	return auxClose(L)
+}
+
+// 对应C函数：`static int io_gc (lua_State *L)'
+func ioGC(L *LuaState) int {
+	/* ignore closed files */
+	if toFileP(L).Value() != nil {
+		auxClose(L)
+	}
+	return 0
+}
+
+// 对应C函数：`static int io_tostring (lua_State *L)'
+func ioToString(L *LuaState) int {
+	if f := toFileP(L).Value(); f == nil {
+		L.PushLiteral("file (closed)")
+	} else {
+		L.PushFString("file (%p)", f)
+	}
+	return 1
+}
+
+// 对应C函数：`static int io_open (lua_State *L)'
+func ioOpen(L *LuaState) int {
+	var filename = L.LCheckString(1)
+	var mode = L.LOptString(2, "r")
+	var pf = newFile(L)
+	var f, err = golua.Fopen(filename, mode)
+	if err != nil {
+		return pushResult(L, err, filename)
+	}
+	pf.SetValue(f)
+	return 1
+}
+
+// 对应C函数：`static int io_popen (lua_State *L)'
+func ioPopen(L *LuaState) int {
+	var filename = L.LCheckString(1)
+	var mode = L.LOptString(2, "r")
+	var pf = newFile(L)
+	var f, err = L.OSPolicy().Popen(filename, mode)
+	if err != nil {
+		return pushResult(L, err, filename)
+	}
+	pf.SetValue(f)
+	return 1
+}
+
+// 对应C函数：`static int io_tmpfile (lua_State *L)'
+func ioTmpfile(L *LuaState) int {
+	var pf = newFile(L)
+	var f, err = golua.Tmpfile()
+	if err != nil {
+		return pushResult(L, err, "")
+	}
+	pf.SetValue(f)
+	return 1
+}
+
+// 对应C函数：`static FILE *getiofile (lua_State *L, int findex)'
+func getIOFile(L *LuaState, findex int) *golua.FILE {
+	L.RawGetI(golua.LUA_ENVIRONINDEX, findex)
+	var f, _ = L.ToUserData(-1).(*golua.Udata).Value().(*golua.FILE)
+	if f == nil {
+		L.LError("standard %s file is closed", fNames[findex-1])
+	}
+	return f
+}
+
+// 对应C函数：`static int g_iofile (lua_State *L, int f, const char *mode)'
+func gIOFile(L *LuaState, f int, mode string) int {
+	if !L.IsNoneOrNil(1) {
+		if L.IsString(1) {
+			var filename = L.ToString(1)
+			var pf = newFile(L)
+			var fp, err = golua.Fopen(filename, mode)
+			if err != nil {
+				fileError(L, 1, filename, err)
+			}
+			pf.SetValue(fp)
+		} else {
+			toFile(L) /* check that it's a valid file handle */
+			L.PushValue(1)
+		}
+		L.RawSetI(golua.LUA_ENVIRONINDEX, f)
+	}
+	/* return current value */
+	L.RawGetI(golua.LUA_ENVIRONINDEX, f)
+	return 1
+}
+
+// 对应C函数：`static int io_input (lua_State *L)'
+func ioInput(L *LuaState) int {
+	return gIOFile(L, IO_INPUT, "r")
+}
+
+// 对应C函数：`static int io_output (lua_State *L)'
+func ioOutput(L *LuaState) int {
+	return gIOFile(L, IO_OUTPUT, "w")
+}
+
+// 对应C函数：`static void aux_lines (lua_State *L, int idx, int toclose)'
+func auxLines(L *LuaState, idx int, toClose bool) {
+	L.PushValue(idx)
+	L.PushBoolean(toClose) /* close/not close file when finished */
+	L.PushCClosure(ioReadLine, 2)
+}
+
+// 对应C函数：`static int f_lines (lua_State *L)'
+func fLines(L *LuaState) int {
+	toFile(L) /* check that it's a valid file handle */
+	auxLines(L, 1, false)
+	return 1
+}
+
+// 对应C函数：`static int io_lines (lua_State *L)'
+func ioLines(L *LuaState) int {
+	if L.IsNoneOrNil(1) { /* no arguments? */
+		/* will iterate over default input */
+		L.RawGetI(golua.LUA_ENVIRONINDEX, IO_INPUT)
+		return fLines(L)
+	} else {
+		var filename = L.LCheckString(1)
+		var pf = newFile(L)
+		var f, err = golua.Fopen(filename, "r")
+		if err != nil {
+			fileError(L, 1, filename, err)
+		}
+		pf.SetValue(f)
+		auxLines(L, L.GetTop(), true)
+		return 1
+	}
+}
+
+/*
+** {======================================================
+** READ
+** =======================================================
+ */
+
+// 对应C函数：`isspace(c)'
+func isSpace(c byte) bool {
+	return c == ' ' || ('\t' <= c && c <= '\r')
+}
+
+// 对应C函数：`static int read_number (lua_State *L, FILE *f)'
+// C中使用fscanf读取，这里跳过空白后读取最长的十进制数字前缀
+func readNumber(L *LuaState, f *golua.FILE) bool {
+	var c, ok = f.Getc()
+	for ok && isSpace(c) {
+		c, ok = f.Getc()
+	}
+	var buff []byte
+	var accept = func(set string) bool {
+		if ok && strings.IndexByte(set, c) >= 0 {
+			buff = append(buff, c)
+			c, ok = f.Getc()
+			return true
+		}
+		return false
+	}
+	var digits = func() int {
+		var n = 0
+		for accept("0123456789") {
+			n++
+		}
+		return n
+	}
+	accept("+-")
+	var n = digits()
+	if accept(".") {
+		n += digits()
+	}
+	if n > 0 && accept("eE") {
+		accept("+-")
+		digits()
+	}
+	if ok {
+		f.Ungetc(c)
+	}
+	var d, err = strconv.ParseFloat(string(buff), 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		L.PushNil()  /* "result" to be removed */
+		return false /* read fails */
+	}
+	L.PushNumber(d)
+	return true
+}
+
+// 对应C函数：`static int test_eof (lua_State *L, FILE *f)'
+func testEOF(L *LuaState, f *golua.FILE) bool {
+	var c, ok = f.Getc()
+	if ok {
+		f.Ungetc(c)
+	}
+	L.PushLString(nil)
+	return ok
+}
+
+// 对应C函数：`static int read_line (lua_State *L, FILE *f)'
+func readLine(L *LuaState, f *golua.FILE) bool {
+	var b golua.LBuffer
+	L.LBuffInit(&b)
+	for {
+		var c, ok = f.Getc()
+		if !ok { /* eof? */
+			b.PushResult()          /* close buffer */
+			return L.ObjLen(-1) > 0 /* check whether read something */
+		}
+		if c == '\n' {
+			b.PushResult() /* close buffer */
+			return true    /* read at least an `eol' */
+		}
+		b.AddChar(c)
+	}
+}
+
+// 对应C函数：`static int read_chars (lua_State *L, FILE *f, size_t n)'
+func readChars(L *LuaState, f *golua.FILE, n int) bool {
+	var b golua.LBuffer
+	var p [golua.LUAL_BUFFERSIZE]byte
+	var rlen = golua.LUAL_BUFFERSIZE /* try to read that much each time */
+	var nr int                       /* number of chars actually read */
+	L.LBuffInit(&b)
+	for {
+		if rlen > n {
+			rlen = n /* cannot read more than asked */
+		}
+		nr = f.Fread(p[:rlen])
+		b.AddLString(p[:nr])
+		n -= nr                     /* still have to read `n' chars */
+		if !(n > 0 && nr == rlen) { /* until end of count or eof */
+			break
+		}
+	}
+	b.PushResult() /* close buffer */
+	return n == 0 || L.ObjLen(-1) > 0
+}
+
+// 对应C函数：`static int g_read (lua_State *L, FILE *f, int first)'
+func gRead(L *LuaState, f *golua.FILE, first int) int {
+	var nArgs = L.GetTop() - 1
+	var success bool
+	var n int
+	f.Clearerr()
+	if nArgs == 0 { /* no arguments? */
+		success = readLine(L, f)
+		n = first + 1 /* to return 1 result */
+	} else { /* ensure stack space for all results and for auxlib's buffer */
+		L.LCheckStack(nArgs+golua.LUA_MINSTACK, "too many arguments")
+		success = true
+		for n = first; nArgs > 0 && success; n++ {
+			nArgs--
+			if L.Type(n) == golua.LUA_TNUMBER {
+				var l = L.ToInteger(n)
+				if l < 0 { /* a size_t in C */
+					l = math.MaxInt
+				}
+				if l == 0 {
+					success = testEOF(L, f)
+				} else {
+					success = readChars(L, f, l)
+				}
+			} else {
+				var p = L.ToString(n)
+				L.LArgCheck(len(p) > 0 && p[0] == '*', n, "invalid option")
+				var opt byte
+				if len(p) > 1 {
+					opt = p[1]
+				}
+				switch opt {
+				case 'n': /* number */
+					success = readNumber(L, f)
+				case 'l': /* line */
+					success = readLine(L, f)
+				case 'a': /* file */
+					readChars(L, f, math.MaxInt) /* read MAX_SIZE_T chars */
+					success = true               /* always success */
+				default:
+					return L.LArgError(n, "invalid format")
+				}
+			}
+		}
+	}
+	if err := f.Ferror(); err != nil {
+		return pushResult(L, err, "")
+	}
+	if !success {
+		L.Pop(1)    /* remove last result */
+		L.PushNil() /* push nil instead */
+	}
+	return n - first
+}
+
+// 对应C函数：`static int io_read (lua_State *L)'
+func ioRead(L *LuaState) int {
+	return gRead(L, getIOFile(L, IO_INPUT), 1)
+}
+
+// 对应C函数：`static int f_read (lua_State *L)'
+func fRead(L *LuaState) int {
+	return gRead(L, toFile(L), 2)
+}
+
+// 对应C函数：`static int io_readline (lua_State *L)'
+func ioReadLine(L *LuaState) int {
+	var f, _ = L.ToUserData(golua.LuaUpValueIndex(1)).(*golua.Udata).Value().(*golua.FILE)
+	if f == nil { /* file is already closed? */
+		L.LError("file is already closed")
+	}
+	var success = readLine(L, f)
+	if err := f.Ferror(); err != nil {
+		var msg, _ = strError(err)
+		return L.LError("%s", msg)
+	}
+	if success {
+		return 1
+	} else { /* EOF */
+		if L.ToBoolean(golua.LuaUpValueIndex(2)) { /* generator created file? */
+			L.SetTop(0)
+			L.PushValue(golua.LuaUpValueIndex(1))
+			auxClose(L) /* close it */
+		}
+		return 0
+	}
+}
+
+/* }====================================================== */
+
+// 对应C函数：`static int g_write (lua_State *L, FILE *f, int arg)'
+func gWrite(L *LuaState, f *golua.FILE, arg int) int {
+	var nArgs = L.GetTop() - 1
+	var status = true
+	for ; nArgs > 0; arg++ {
+		nArgs--
+		if L.Type(arg) == golua.LUA_TNUMBER {
+			/* optimization: could be done exactly as for strings */
+			status = status && f.Fwrite([]byte(golua.NumberToStr(L.ToNumber(arg)))) > 0
+		} else {
+			var s, l = L.LCheckLString(arg)
+			status = status && f.Fwrite(s) == l
+		}
+	}
+	if !status {
+		return pushResult(L, f.Ferror(), "")
+	}
+	return pushResult(L, nil, "")
+}
+
+// 对应C函数：`static int io_write (lua_State *L)'
+func ioWrite(L *LuaState) int {
+	return gWrite(L, getIOFile(L, IO_OUTPUT), 1)
+}
+
+// 对应C函数：`static int f_write (lua_State *L)'
+func fWrite(L *LuaState) int {
+	return gWrite(L, toFile(L), 2)
+}
+
+// 对应C函数：`static int f_seek (lua_State *L)'
+func fSeek(L *LuaState) int {
+	var mode = []int{io.SeekStart, io.SeekCurrent, io.SeekEnd}
+	var modeNames = []string{"set", "cur", "end"}
+	var f = toFile(L)
+	var op = L.LCheckOption(2, "cur", modeNames)
+	var offset = L.LOptInteger(3, 0)
+	var pos, err = f.Fseek(int64(offset), mode[op])
+	if err != nil {
+		return pushResult(L, err, "") /* error */
+	}
+	L.PushInteger(golua.LuaInteger(pos))
+	return 1
+}
+
+// 对应C函数：`static int f_setvbuf (lua_State *L)'
+func fSetvbuf(L *LuaState) int {
+	var mode = []int{golua.IONBF, golua.IOFBF, golua.IOLBF}
+	var modeNames = []string{"no", "full", "line"}
+	var f = toFile(L)
+	var op = L.LCheckOption(2, "", modeNames)
+	var sz = L.LOptInteger(3, golua.LUAL_BUFFERSIZE)
+	return pushResult(L, f.Setvbuf(mode[op], sz), "")
+}
+
+// 对应C函数：`static int io_flush (lua_State *L)'
+func ioFlush(L *LuaState) int {
+	return pushResult(L, getIOFile(L, IO_OUTPUT).Fflush(), "")
+}
+
+// 对应C函数：`static int f_flush (lua_State *L)'
+func fFlush(L *LuaState) int {
+	return pushResult(L, toFile(L).Fflush(), "")
+}
+
+var ioLib = []golua.LReg{
+	{Name: "close", Func: ioClose},
+	{Name: "flush", Func: ioFlush},
+	{Name: "input", Func: ioInput},
+	{Name: "lines", Func: ioLines},
+	{Name: "open", Func: ioOpen},
+	{Name: "output", Func: ioOutput},
+	{Name: "popen", Func: ioPopen},
+	{Name: "read", Func: ioRead},
+	{Name: "tmpfile", Func: ioTmpfile},
+	{Name: "type", Func: ioType},
+	{Name: "write", Func: ioWrite},
+}
+
+var fLib = []golua.LReg{
+	{Name: "close", Func: ioClose},
+	{Name: "flush", Func: fFlush},
+	{Name: "lines", Func: fLines},
+	{Name: "read", Func: fRead},
+	{Name: "seek", Func: fSeek},
+	{Name: "setvbuf", Func: fSetvbuf},
+	{Name: "write", Func: fWrite},
+	{Name: "__gc", Func: ioGC},
+	{Name: "__tostring", Func: ioToString},
+}
+
+// 对应C函数：`static void createmeta (lua_State *L)'
+func createMeta(L *LuaState) {
+	L.LNewMetaTable(LUA_FILEHANDLE) /* create metatable for file handles */
+	L.PushValue(-1)                 /* push metatable */
+	L.SetField(-2, "__index")       /* metatable.__index = metatable */
+	L.LRegister("", fLib)           /* file methods */
+}
+
+// 对应C函数：`static void createstdfile (lua_State *L, FILE *f, int k, const char *fname)'
+func createStdFile(L *LuaState, f *golua.FILE, k int, fname string) {
+	newFile(L).SetValue(f)
+	if k > 0 {
+		L.PushValue(-1)
+		L.RawSetI(golua.LUA_ENVIRONINDEX, k)
+	}
+	L.PushValue(-2) /* copy environment */
+	L.SetFEnv(-2)   /* set it */
+	L.SetField(-3, fname)
+}
+
+// 对应C函数：`static void newfenv (lua_State *L, lua_CFunction cls)'
+func newFEnv(L *LuaState, cls LuaCFunction) {
+	L.CreateTable(0, 1)
+	L.PushCFunction(cls)
+	L.SetField(-2, "__close")
+}
+
+// LuaOpenIO
+// 对应C函数：`LUALIB_API int luaopen_io (lua_State *L)'
+func LuaOpenIO(L *LuaState) int {
+	createMeta(L)
+	/* create (private) environment (with fields IO_INPUT, IO_OUTPUT, __close) */
+	newFEnv(L, ioFClose)
+	L.Replace(golua.LUA_ENVIRONINDEX)
+	/* open library */
+	L.LRegister(LUA_IOLIBNAME, ioLib)
+	/* create (and set) default files */
+	newFEnv(L, ioNoClose) /* close function for default files */
+	createStdFile(L, golua.STDIN, IO_INPUT, "stdin")
+	createStdFile(L, golua.STDOUT, IO_OUTPUT, "stdout")
+	createStdFile(L, golua.STDERR, 0, "stderr")
+	L.Pop(1) /* pop environment for default files */
+	L.GetField(-1, "popen")
+	newFEnv(L, ioPClose) /* create environment for 'popen' */
+	L.SetFEnv(-2)        /* set fenv for 'popen' */
+	L.Pop(1)             /* pop 'popen' */
+	return 1
+}
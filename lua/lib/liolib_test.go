@@ -0,0 +1,124 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withFile 在chunk之前定义保存临时文件名的局部变量name
+func withFile(t *testing.T, chunk string) string {
+	return fmt.Sprintf("local name = %q\n", filepath.Join(t.TempDir(), "io.txt")) + chunk
+}
+
+func TestIOReadWrite(t *testing.T) {
+	err := doString(t, withFile(t, `
+	local f = assert(io.open(name, "w"))
+	assert(io.type(f) == "file" and tostring(f):find("^file %("))
+	assert(f:write("line1\n", 12.5, " -3e2 abc\n", "last") == true)
+	assert(f:close() == true)
+	assert(io.type(f) == "closed file" and tostring(f) == "file (closed)")
+	assert(io.type(42) == nil and io.type(io.stdout) == "file")
+	local ok, msg = pcall(f.read, f)
+	assert(not ok and msg:find("attempt to use a closed file", 1, true), msg)
+
+	f = assert(io.open(name))
+	assert(f:read() == "line1")
+	local a, b, c = f:read("*n", "*n", "*n")
+	assert(a == 12.5 and b == -300 and c == nil)
+	assert(f:read("*l") == "abc")
+	assert(f:read(2) == "la" and f:read(0) == "" and f:read("*a") == "st")
+	assert(f:read(0) == nil and f:read("*a") == "" and f:read() == nil)
+	assert(f:seek("set", 2) == 2 and f:read(3) == "ne1" and f:seek() == 5)
+	assert(f:seek("end") == 24)
+	assert(not pcall(f.read, f, "*x"))
+	assert(not pcall(f.seek, f, "middle"))
+	f:close()
+
+	local lines = {}
+	for l in io.lines(name) do lines[#lines+1] = l end
+	assert(table.concat(lines, "|") == "line1|12.5 -3e2 abc|last")
+	f = assert(io.open(name))
+	lines = {}
+	for l in f:lines() do lines[#lines+1] = l end
+	assert(#lines == 3 and io.type(f) == "file")
+	f:close()
+
+	f = assert(io.open(name, "r+"))
+	assert(f:setvbuf("no") and f:write("LINE") and f:seek("set") == 0)
+	assert(f:read() == "LINE1")
+	f:close()
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIODefaultFiles(t *testing.T) {
+	err := doString(t, withFile(t, `
+	local stdout = io.output()
+	assert(io.type(io.output(name)) == "file")
+	io.write("via ", "output\n")
+	io.close()
+	local ok, msg = pcall(io.write, "x")
+	assert(not ok and msg:find("standard output file is closed", 1, true), msg)
+	io.output(stdout)
+
+	io.input(name)
+	assert(io.read() == "via output" and io.read() == nil)
+	io.input():close()
+	ok, msg = pcall(io.read)
+	assert(not ok and msg:find("standard input file is closed", 1, true), msg)
+
+	local r, msg = io.close(io.stdout)
+	assert(r == nil and msg == "cannot close standard file")
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIOErrors(t *testing.T) {
+	err := doString(t, `
+	local f, msg, code = io.open("/nonexistent/file")
+	assert(f == nil and msg == "/nonexistent/file: no such file or directory" and code == 2, msg)
+	local ok, msg = pcall(io.lines, "/nonexistent/file")
+	assert(not ok and msg:find("/nonexistent/file: no such file or directory", 1, true), msg)
+	assert(not pcall(io.input, "/nonexistent/file"))
+	ok, msg = pcall(io.stdout.write, {})
+	assert(not ok and msg:find("FILE* expected, got table", 1, true), msg)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIOTmpfilePopen(t *testing.T) {
+	err := doString(t, `
+	local f = assert(io.tmpfile())
+	f:write("abc", 1, "\n")
+	f:seek("set")
+	assert(f:read("*a") == "abc1\n")
+	assert(f:close())
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no shell for io.popen")
+	}
+	err = doString(t, `
+	local p = assert(io.popen("echo hi; echo there"))
+	assert(p:read("*a") == "hi\nthere\n")
+	assert(p:close() == true)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doString(t, `assert(io.popen("true", "x"))`); err == nil || !strings.Contains(err.Error(), "invalid argument") {
+		t.Errorf("popen with a bad mode error = %v", err)
+	}
+}
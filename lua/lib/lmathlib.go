@@ -0,0 +1,263 @@
+package lib
+
+import (
+	"math"
+
+	golua "luar/lua"
+)
+
+const (
+	pi               = math.Pi
+	radiansPerDegree = pi / 180.0
+)
+
+// 对应C函数：`static int math_abs (lua_State *L)'
+func mathAbs(L *LuaState) int {
+	L.PushNumber(math.Abs(L.LCheckNumber(1)))
+	return 1
+}
+
+// 对应C函数：`static int math_sin (lua_State *L)'
+func mathSin(L *LuaState) int {
+	L.PushNumber(math.Sin(L.LCheckNumber(1)))
+	return 1
+}
+
+// 对应C函数：`static int math_sinh (lua_State *L)'
+func mathSinh(L *LuaState) int {
+	L.PushNumber(math.Sinh(L.LCheckNumber(1)))
+	return 1
+}
+
+// 对应C函数：`static int math_cos (lua_State *L)'
+func mathCos(L *LuaState) int {
+	L.PushNumber(math.Cos(L.LCheckNumber(1)))
+	return 1
+}
+
+// 对应C函数：`static int math_cosh (lua_State *L)'
+func mathCosh(L *LuaState) int {
+	L.PushNumber(math.Cosh(L.LCheckNumber(1)))
+	return 1
+}
+
+// 对应C函数：`static int math_tan (lua_State *L)'
+func mathTan(L *LuaState) int {
+	L.PushNumber(math.Tan(L.LCheckNumber(1)))
+	return 1
+}
+
+// 对应C函数：`static int math_tanh (lua_State *L)'
+func mathTanh(L *LuaState) int {
+	L.PushNumber(math.Tanh(L.LCheckNumber(1)))
+	return 1
+}
+
+// 对应C函数：`static int math_asin (lua_State *L)'
+func mathAsin(L *LuaState) int {
+	L.PushNumber(math.Asin(L.LCheckNumber(1)))
+	return 1
+}
+
+// 对应C函数：`static int math_acos (lua_State *L)'
+func mathAcos(L *LuaState) int {
+	L.PushNumber(math.Acos(L.LCheckNumber(1)))
+	return 1
+}
+
+// 对应C函数：`static int math_atan (lua_State *L)'
+func mathAtan(L *LuaState) int {
+	L.PushNumber(math.Atan(L.LCheckNumber(1)))
+	return 1
+}
+
+// 对应C函数：`static int math_atan2 (lua_State *L)'
+func mathAtan2(L *LuaState) int {
+	L.PushNumber(math.Atan2(L.LCheckNumber(1), L.LCheckNumber(2)))
+	return 1
+}
+
+// 对应C函数：`static int math_ceil (lua_State *L)'
+func mathCeil(L *LuaState) int {
+	L.PushNumber(math.Ceil(L.LCheckNumber(1)))
+	return 1
+}
+
+// 对应C函数：`static int math_floor (lua_State *L)'
+func mathFloor(L *LuaState) int {
+	L.PushNumber(math.Floor(L.LCheckNumber(1)))
+	return 1
+}
+
+// 对应C函数：`static int math_fmod (lua_State *L)'
+func mathFmod(L *LuaState) int {
+	L.PushNumber(math.Mod(L.LCheckNumber(1), L.LCheckNumber(2)))
+	return 1
+}
+
+// 对应C函数：`static int math_modf (lua_State *L)'
+func mathModf(L *LuaState) int {
+	var ip, fp = math.Modf(L.LCheckNumber(1))
+	if math.IsInf(ip, 0) {
+		fp = 0 /* C's modf gives a zero fraction for infinities; Go gives NaN */
+	}
+	L.PushNumber(ip)
+	L.PushNumber(fp)
+	return 2
+}
+
+// 对应C函数：`static int math_sqrt (lua_State *L)'
+func mathSqrt(L *LuaState) int {
+	L.PushNumber(math.Sqrt(L.LCheckNumber(1)))
+	return 1
+}
+
+// 对应C函数：`static int math_pow (lua_State *L)'
+func mathPow(L *LuaState) int {
+	L.PushNumber(math.Pow(L.LCheckNumber(1), L.LCheckNumber(2)))
+	return 1
+}
+
+// 对应C函数：`static int math_log (lua_State *L)'
+func mathLog(L *LuaState) int {
+	L.PushNumber(math.Log(L.LCheckNumber(1)))
+	return 1
+}
+
+// 对应C函数：`static int math_log10 (lua_State *L)'
+func mathLog10(L *LuaState) int {
+	L.PushNumber(math.Log10(L.LCheckNumber(1)))
+	return 1
+}
+
+// 对应C函数：`static int math_exp (lua_State *L)'
+func mathExp(L *LuaState) int {
+	L.PushNumber(math.Exp(L.LCheckNumber(1)))
+	return 1
+}
+
+// 对应C函数：`static int math_deg (lua_State *L)'
+func mathDeg(L *LuaState) int {
+	L.PushNumber(L.LCheckNumber(1) / radiansPerDegree)
+	return 1
+}
+
+// 对应C函数：`static int math_rad (lua_State *L)'
+func mathRad(L *LuaState) int {
+	L.PushNumber(L.LCheckNumber(1) * radiansPerDegree)
+	return 1
+}
+
+// 对应C函数：`static int math_frexp (lua_State *L)'
+func mathFrexp(L *LuaState) int {
+	var m, e = math.Frexp(L.LCheckNumber(1))
+	L.PushNumber(m)
+	L.PushInteger(e)
+	return 2
+}
+
+// 对应C函数：`static int math_ldexp (lua_State *L)'
+func mathLdexp(L *LuaState) int {
+	L.PushNumber(math.Ldexp(L.LCheckNumber(1), L.LCheckInt(2)))
+	return 1
+}
+
+// 对应C函数：`static int math_min (lua_State *L)'
+func mathMin(L *LuaState) int {
+	var n = L.GetTop() /* number of arguments */
+	var dmin = L.LCheckNumber(1)
+	for i := 2; i <= n; i++ {
+		if d := L.LCheckNumber(i); d < dmin {
+			dmin = d
+		}
+	}
+	L.PushNumber(dmin)
+	return 1
+}
+
+// 对应C函数：`static int math_max (lua_State *L)'
+func mathMax(L *LuaState) int {
+	var n = L.GetTop() /* number of arguments */
+	var dmax = L.LCheckNumber(1)
+	for i := 2; i <= n; i++ {
+		if d := L.LCheckNumber(i); d > dmax {
+			dmax = d
+		}
+	}
+	L.PushNumber(dmax)
+	return 1
+}
+
+// 对应C函数：`static int math_random (lua_State *L)'
+// C中使用rand()，这里使用状态机的RandSource，取其53位得到[0,1)之间的数
+func mathRandom(L *LuaState) int {
+	var r = golua.LuaNumber(L.RandSource().Int63()>>10) / (1 << 53)
+	switch L.GetTop() { /* check number of arguments */
+	case 0: /* no arguments */
+		L.PushNumber(r) /* Number between 0 and 1 */
+	case 1: /* only upper limit */
+		var u = L.LCheckInt(1)
+		L.LArgCheck(1 <= u, 1, "interval is empty")
+		L.PushNumber(math.Floor(r*golua.LuaNumber(u)) + 1) /* int between 1 and `u' */
+	case 2: /* lower and upper limits */
+		var l = L.LCheckInt(1)
+		var u = L.LCheckInt(2)
+		L.LArgCheck(l <= u, 2, "interval is empty")
+		L.PushNumber(math.Floor(r*golua.LuaNumber(u-l+1)) + golua.LuaNumber(l)) /* int between `l' and `u' */
+	default:
+		return L.LError("wrong number of arguments")
+	}
+	return 1
+}
+
+// 对应C函数：`static int math_randomseed (lua_State *L)'
+func mathRandomSeed(L *LuaState) int {
+	L.RandSource().Seed(int64(L.LCheckInt(1)))
+	return 0
+}
+
+var mathLib = []golua.LReg{
+	{Name: "abs", Func: mathAbs},
+	{Name: "acos", Func: mathAcos},
+	{Name: "asin", Func: mathAsin},
+	{Name: "atan2", Func: mathAtan2},
+	{Name: "atan", Func: mathAtan},
+	{Name: "ceil", Func: mathCeil},
+	{Name: "cosh", Func: mathCosh},
+	{Name: "cos", Func: mathCos},
+	{Name: "deg", Func: mathDeg},
+	{Name: "exp", Func: mathExp},
+	{Name: "floor", Func: mathFloor},
+	{Name: "fmod", Func: mathFmod},
+	{Name: "frexp", Func: mathFrexp},
+	{Name: "ldexp", Func: mathLdexp},
+	{Name: "log10", Func: mathLog10},
+	{Name: "log", Func: mathLog},
+	{Name: "max", Func: mathMax},
+	{Name: "min", Func: mathMin},
+	{Name: "modf", Func: mathModf},
+	{Name: "pow", Func: mathPow},
+	{Name: "rad", Func: mathRad},
+	{Name: "random", Func: mathRandom},
+	{Name: "randomseed", Func: mathRandomSeed},
+	{Name: "sinh", Func: mathSinh},
+	{Name: "sin", Func: mathSin},
+	{Name: "sqrt", Func: mathSqrt},
+	{Name: "tanh", Func: mathTanh},
+	{Name: "tan", Func: mathTan},
+}
+
+// LuaOpenMath
+// 对应C函数：`LUALIB_API int luaopen_math (lua_State *L)'
+func LuaOpenMath(L *LuaState) int {
+	L.LRegister(LUA_MATHLIBNAME, mathLib)
+	L.PushNumber(pi)
+	L.SetField(-2, "pi")
+	L.PushNumber(math.Inf(1))
+	L.SetField(-2, "huge")
+	if golua.LUA_COMPAT_MOD {
+		L.GetField(-1, "fmod")
+		L.SetField(-2, "mod")
+	}
+	return 1
+}
@@ -0,0 +1,141 @@
+package lib
+
+import (
+	"math/rand"
+	"testing"
+
+	golua "luar/lua"
+)
+
+func TestMathFunctions(t *testing.T) {
+	err := doString(t, `
+	assert(math.floor(3.7) == 3 and math.floor(-3.7) == -4)
+	assert(math.ceil(3.2) == 4 and math.ceil(-3.2) == -3)
+	assert(math.fmod(7, 3) == 1 and math.fmod(-7, 3) == -1 and math.mod(-7, 3) == -1)
+	local ip, fp = math.modf(3.75)
+	assert(ip == 3 and fp == 0.75)
+	ip, fp = math.modf(-math.huge)
+	assert(ip == -math.huge and fp == 0)
+	local m, e = math.frexp(12)
+	assert(m == 0.75 and e == 4 and math.ldexp(m, e) == 12)
+	assert(math.pow(2, 10) == 1024 and math.sqrt(81) == 9 and math.abs(-2) == 2)
+	assert(math.max(3, 9, -1) == 9 and math.min(3, 9, -1) == -1)
+	assert(math.huge > 1e308 and -math.huge < -1e308)
+	assert(math.abs(math.pi - 3.141592653589793) < 1e-15)
+	assert(math.deg(math.pi) == 180 and math.rad(180) == math.pi)
+	assert(math.pow(2, 0.5) == 2^0.5 and math.rad(90) == 90 * (math.pi / 180))
+	assert(math.log10(1000) == 3 and math.exp(0) == 1 and math.log(1) == 0)
+	assert(math.sin(0) == 0 and math.cos(0) == 1 and math.atan2(0, 1) == 0)
+	assert(not pcall(math.floor, "x"))
+	assert(not pcall(math.max))
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// randoms 在新状态机中执行chunk并收集它返回的所有数字
+func randoms(t *testing.T, setup func(L *LuaState), chunk string) []golua.LuaNumber {
+	t.Helper()
+	var L = golua.LuaOpen()
+	defer L.Close()
+	OpenLibs(L)
+	if setup != nil {
+		setup(L)
+	}
+	if err := L.LDoStringErr(chunk); err != nil {
+		t.Fatal(err)
+	}
+	var ret []golua.LuaNumber
+	for i := 1; i <= L.GetTop(); i++ {
+		ret = append(ret, L.ToNumber(i))
+	}
+	return ret
+}
+
+const randomChunk = `
+	local r = {}
+	for i = 1, 20 do
+		r[#r+1] = math.random()
+		r[#r+1] = math.random(6)
+		r[#r+1] = math.random(-3, 3)
+	end
+	return unpack(r)`
+
+func TestMathRandomReplay(t *testing.T) {
+	var equal = func(a, b []golua.LuaNumber) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	/* the same seed replays the same sequence in every state */
+	var a = randoms(t, nil, "math.randomseed(42)"+randomChunk)
+	var b = randoms(t, nil, "math.randomseed(42)"+randomChunk)
+	if !equal(a, b) {
+		t.Errorf("same seed gave different sequences:\n%v\n%v", a, b)
+	}
+	for i := 0; i < len(a); i += 3 {
+		if a[i] < 0 || a[i] >= 1 || a[i+1] < 1 || a[i+1] > 6 || a[i+2] < -3 || a[i+2] > 3 {
+			t.Fatalf("value out of range: %v", a[i:i+3])
+		}
+	}
+	if c := randoms(t, nil, "math.randomseed(43)"+randomChunk); equal(a, c) {
+		t.Errorf("different seeds gave the same sequence")
+	}
+
+	/* without randomseed the sequence is still deterministic */
+	if !equal(randoms(t, nil, randomChunk), randoms(t, nil, randomChunk)) {
+		t.Errorf("default sequence is not deterministic")
+	}
+
+	/* a golden sequence guards against changes to the conversion from the source */
+	var golden = randoms(t, nil, "math.randomseed(7) return math.random(100), math.random(100), math.random(100), math.random(100)")
+	if want := []golua.LuaNumber{92, 24, 25, 92}; !equal(golden, want) {
+		t.Errorf("golden sequence = %v, want %v", golden, want)
+	}
+}
+
+// constSource 总是返回同一个值的随机数发生器
+type constSource int64
+
+func (s constSource) Int63() int64    { return int64(s) }
+func (s constSource) Seed(seed int64) {}
+
+func TestMathRandomSource(t *testing.T) {
+	/* the host can install its own generator */
+	var got = randoms(t, func(L *LuaState) {
+		L.SetRandSource(constSource(1 << 62))
+	}, "return math.random(), math.random(10), math.random(3, 4)")
+	if want := []golua.LuaNumber{0.5, 6, 4}; len(got) != 3 || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("constant source gave %v, want %v", got, want)
+	}
+
+	/* the host's source sees randomseed */
+	var src = rand.NewSource(0)
+	var seeded = randoms(t, func(L *LuaState) {
+		L.SetRandSource(src)
+	}, "math.randomseed(99) return math.random(1000)")
+	src.Seed(99)
+	if want := golua.LuaNumber(int64(float64(src.Int63()>>10)/(1<<53)*1000) + 1); seeded[0] != want {
+		t.Errorf("seeded host source gave %v, want %v", seeded[0], want)
+	}
+
+	/* each state has its own generator */
+	var L1, L2 = golua.LuaOpen(), golua.LuaOpen()
+	defer L1.Close()
+	defer L2.Close()
+	if L1.RandSource() == L2.RandSource() {
+		t.Errorf("states share a random source")
+	}
+	var co = L1.NewThread()
+	if co.RandSource() != L1.RandSource() {
+		t.Errorf("threads of one state do not share the random source")
+	}
+}
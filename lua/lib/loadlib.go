@@ -0,0 +1,449 @@
+package lib
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	golua "luar/lua"
+)
+
+/* prefix for open functions in C libraries */
+const LUA_POF = "luaopen_"
+
+/* separator for open functions in C libraries */
+const LUA_OFSEP = "_"
+
+/* error codes for ll_loadfunc */
+const (
+	ERRLIB  = 1
+	ERRFUNC = 2
+)
+
+/*
+** Go cannot load C libraries, so the loaders that search package.cpath
+** behave like a C Lua built without dynamic library support. Native
+** modules written in Go are registered with Preload instead.
+ */
+const DLMSG = "dynamic libraries not enabled; check your Lua installation"
+
+// 对应C函数：`static int ll_loadfunc (lua_State *L, const char *path, const char *sym)'
+func llLoadFunc(L *LuaState, path string, sym string) int {
+	L.PushLiteral(DLMSG)
+	return ERRLIB /* unable to load library */
+}
+
+// 对应C函数：`static int ll_loadlib (lua_State *L)'
+func llLoadLib(L *LuaState) int {
+	var path = L.LCheckString(1)
+	var init = L.LCheckString(2)
+	var stat = llLoadFunc(L, path, init)
+	if stat == 0 { /* no errors? */
+		return 1 /* return the loaded function */
+	} else { /* error; error message is on stack top */
+		L.PushNil()
+		L.Insert(-2)
+		if stat == ERRLIB {
+			L.PushString("open")
+		} else {
+			L.PushString("init")
+		}
+		return 3 /* return nil, error message, and where */
+	}
+}
+
+/*
+** {======================================================
+** 'require' function
+** =======================================================
+ */
+
+// fsName 把文件名转换为fs.FS中的名字：去掉开头的"./"并清理路径；在fs.FS中无效的名字返回false
+func fsName(filename string) (string, bool) {
+	var name = path.Clean(filepath.ToSlash(filename))
+	return name, fs.ValidPath(name)
+}
+
+// 对应C函数：`static int readable (const char *filename)'
+func readable(L *LuaState, filename string) bool {
+	var fsys = L.PackageFS()
+	if fsys == nil {
+		f, err := os.Open(filename) /* try to open file */
+		if err != nil {
+			return false /* open failed */
+		}
+		f.Close()
+		return true
+	}
+	var name, ok = fsName(filename)
+	if !ok {
+		return false
+	}
+	f, err := fsys.Open(name)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
+
+// 对应C函数：`static const char *pushnexttemplate (lua_State *L, const char *path)'
+func pushNextTemplate(L *LuaState, path string) (string, bool) {
+	path = strings.TrimLeft(path, golua.LUA_PATHSEP) /* skip separators */
+	if path == "" {
+		return "", false /* no more templates */
+	}
+	var l = strings.Index(path, golua.LUA_PATHSEP) /* find next separator */
+	if l < 0 {
+		l = len(path)
+	}
+	L.PushString(path[:l]) /* template */
+	return path[l:], true
+}
+
+// 对应C函数：`static const char *findfile (lua_State *L, const char *name, const char *pname)'
+func findFile(L *LuaState, name string, pname string) (string, bool) {
+	name = L.LGSub(name, ".", golua.LUA_DIRSEP)
+	L.GetField(golua.LUA_ENVIRONINDEX, pname)
+	if !L.IsString(-1) {
+		L.LError("'package.%s' must be a string", pname)
+	}
+	var path = L.ToString(-1)
+	L.PushLiteral("") /* error accumulator */
+	for {
+		var ok bool
+		if path, ok = pushNextTemplate(L, path); !ok {
+			break
+		}
+		var filename = L.LGSub(L.ToString(-1), golua.LUA_PATH_MARK, name)
+		L.Remove(-2)               /* remove path template */
+		if readable(L, filename) { /* does file exist and is readable? */
+			return filename, true /* return that file name */
+		}
+		L.PushFString("\n\tno file "+golua.LUA_QS, filename)
+		L.Remove(-2) /* remove file name */
+		L.Concat(2)  /* add entry to possible error message */
+	}
+	return "", false /* not found */
+}
+
+// 对应C函数：`static void loaderror (lua_State *L, const char *filename)'
+func loadError(L *LuaState, filename string) {
+	L.LError("error loading module "+golua.LUA_QS+" from file "+golua.LUA_QS+":\n\t%s",
+		L.ToString(1), filename, L.ToString(-1))
+}
+
+// 对应C函数：`static int loader_Lua (lua_State *L)'
+// 设置了PackageFS时从其中加载文件
+func loaderLua(L *LuaState) int {
+	var name = L.LCheckString(1)
+	var filename, ok = findFile(L, name, "path")
+	if !ok {
+		return 1 /* library not found in this path */
+	}
+	var status int
+	if fsys := L.PackageFS(); fsys == nil {
+		status = L.LLoadFile([]byte(filename))
+	} else {
+		var name, _ = fsName(filename)
+		status = L.LLoadFileFS(fsys, name)
+	}
+	if status != 0 {
+		loadError(L, filename)
+	}
+	return 1 /* library loaded successfully */
+}
+
+// 对应C函数：`static const char *mkfuncname (lua_State *L, const char *modname)'
+func mkFuncName(L *LuaState, modName string) string {
+	if mark := strings.Index(modName, golua.LUA_IGMARK); mark >= 0 {
+		modName = modName[mark+1:]
+	}
+	var funcName = L.LGSub(modName, ".", LUA_OFSEP)
+	funcName = string(L.PushFString(LUA_POF+"%s", funcName))
+	L.Remove(-2) /* remove 'gsub' result */
+	return funcName
+}
+
+// 对应C函数：`static int loader_C (lua_State *L)'
+func loaderC(L *LuaState) int {
+	var name = L.LCheckString(1)
+	var filename, ok = findFile(L, name, "cpath")
+	if !ok {
+		return 1 /* library not found in this path */
+	}
+	var funcName = mkFuncName(L, name)
+	if llLoadFunc(L, filename, funcName) != 0 {
+		loadError(L, filename)
+	}
+	return 1 /* library loaded successfully */
+}
+
+// 对应C函数：`static int loader_Croot (lua_State *L)'
+func loaderCRoot(L *LuaState) int {
+	var name = L.LCheckString(1)
+	var p = strings.IndexByte(name, '.')
+	if p < 0 {
+		return 0 /* is root */
+	}
+	L.PushString(name[:p])
+	var filename, ok = findFile(L, L.ToString(-1), "cpath")
+	if !ok {
+		return 1 /* root not found */
+	}
+	var funcName = mkFuncName(L, name)
+	if stat := llLoadFunc(L, filename, funcName); stat != 0 {
+		if stat != ERRFUNC {
+			loadError(L, filename) /* real error */
+		}
+		L.PushFString("\n\tno module "+golua.LUA_QS+" in file "+golua.LUA_QS, name, filename)
+		return 1 /* function not found */
+	}
+	return 1
+}
+
+// 对应C函数：`static int loader_preload (lua_State *L)'
+func loaderPreload(L *LuaState) int {
+	var name = L.LCheckString(1)
+	L.GetField(golua.LUA_ENVIRONINDEX, "preload")
+	if !L.IsTable(-1) {
+		L.LError("'package.preload' must be a table")
+	}
+	L.GetField(-1, name)
+	if L.IsNil(-1) { /* not found? */
+		L.PushFString("\n\tno field package.preload['%s']", name)
+	}
+	return 1
+}
+
+/* marks a module that is being loaded, to detect loops */
+var sentinel = new(int)
+
+// 对应C函数：`static int ll_require (lua_State *L)'
+func llRequire(L *LuaState) int {
+	var name = L.LCheckString(1)
+	L.SetTop(1) /* _LOADED table will be at index 2 */
+	L.GetField(golua.LUA_REGISTRYINDEX, "_LOADED")
+	L.GetField(2, name)
+	if L.ToBoolean(-1) { /* is it there? */
+		if L.ToUserData(-1) == sentinel { /* check loops */
+			L.LError("loop or previous error loading module "+golua.LUA_QS, name)
+		}
+		return 1 /* package is already loaded */
+	}
+	/* else must load it; iterate over available loaders */
+	L.GetField(golua.LUA_ENVIRONINDEX, "loaders")
+	if !L.IsTable(-1) {
+		L.LError("'package.loaders' must be a table")
+	}
+	L.PushLiteral("") /* error message accumulator */
+	for i := 1; ; i++ {
+		L.RawGetI(-2, i) /* get a loader */
+		if L.IsNil(-1) {
+			L.LError("module "+golua.LUA_QS+" not found:%s", name, L.ToString(-2))
+		}
+		L.PushString(name)
+		L.Call(1, 1)          /* call it */
+		if L.IsFunction(-1) { /* did it find module? */
+			break /* module loaded successfully */
+		} else if L.IsString(-1) { /* loader returned error message? */
+			L.Concat(2) /* accumulate it */
+		} else {
+			L.Pop(1)
+		}
+	}
+	L.PushLightUserData(sentinel)
+	L.SetField(2, name) /* _LOADED[name] = sentinel */
+	L.PushString(name)  /* pass name as argument to module */
+	L.Call(1, 1)        /* run loaded module */
+	if !L.IsNil(-1) {   /* non-nil return? */
+		L.SetField(2, name) /* _LOADED[name] = returned value */
+	}
+	L.GetField(2, name)
+	if L.ToUserData(-1) == sentinel { /* module did not set a value? */
+		L.PushBoolean(true) /* use true as result */
+		L.PushValue(-1)     /* extra copy to be returned */
+		L.SetField(2, name) /* _LOADED[name] = true */
+	}
+	return 1
+}
+
+/* }====================================================== */
+
+/*
+** {======================================================
+** 'module' function
+** =======================================================
+ */
+
+// 对应C函数：`static void setfenv (lua_State *L)'
+func setFEnvOfCaller(L *LuaState) {
+	var ar golua.LuaDebug
+	if !L.GetStack(1, &ar) ||
+		!L.GetInfo("f", &ar) || /* get calling function */
+		L.IsCFunction(-1) {
+		L.LError("'module' not called from a Lua function")
+	}
+	L.PushValue(-2)
+	L.SetFEnv(-2)
+	L.Pop(1)
+}
+
+// 对应C函数：`static void dooptions (lua_State *L, int n)'
+func doOptions(L *LuaState, n int) {
+	for i := 2; i <= n; i++ {
+		L.PushValue(i)  /* get option (a function) */
+		L.PushValue(-2) /* module */
+		L.Call(1, 0)
+	}
+}
+
+// 对应C函数：`static void modinit (lua_State *L, const char *modname)'
+func modInit(L *LuaState, modName string) {
+	L.PushValue(-1)
+	L.SetField(-2, "_M") /* module._M = module */
+	L.PushString(modName)
+	L.SetField(-2, "_NAME")
+	var dot = strings.LastIndexByte(modName, '.') + 1 /* look for last dot in module name */
+	/* set _PACKAGE as package name (full module name minus last part) */
+	L.PushString(modName[:dot])
+	L.SetField(-2, "_PACKAGE")
+}
+
+// 对应C函数：`static int ll_module (lua_State *L)'
+func llModule(L *LuaState) int {
+	var modName = L.LCheckString(1)
+	var loaded = L.GetTop() + 1 /* index of _LOADED table */
+	L.GetField(golua.LUA_REGISTRYINDEX, "_LOADED")
+	L.GetField(loaded, modName) /* get _LOADED[modname] */
+	if !L.IsTable(-1) {         /* not found? */
+		L.Pop(1) /* remove previous result */
+		/* try global variable (and create one if it does not exist) */
+		if L.LFindTable(golua.LUA_GLOBALSINDEX, modName, 1) != nil {
+			return L.LError("name conflict for module "+golua.LUA_QS, modName)
+		}
+		L.PushValue(-1)
+		L.SetField(loaded, modName) /* _LOADED[modname] = new table */
+	}
+	/* check whether table already has a _NAME field */
+	L.GetField(-1, "_NAME")
+	if !L.IsNil(-1) { /* is table an initialized module? */
+		L.Pop(1)
+	} else { /* no; initialize it */
+		L.Pop(1)
+		modInit(L, modName)
+	}
+	L.PushValue(-1)
+	setFEnvOfCaller(L)
+	doOptions(L, loaded-1)
+	return 0
+}
+
+// 对应C函数：`static int ll_seeall (lua_State *L)'
+func llSeeAll(L *LuaState) int {
+	L.LCheckType(1, golua.LUA_TTABLE)
+	if L.GetMetaTable(1) == 0 {
+		L.CreateTable(0, 1) /* create new metatable */
+		L.PushValue(-1)
+		L.SetMetaTable(1)
+	}
+	L.PushValue(golua.LUA_GLOBALSINDEX)
+	L.SetField(-2, "__index") /* mt.__index = _G */
+	return 0
+}
+
+/* }====================================================== */
+
+/* auxiliary mark (for internal use) */
+const AUXMARK = "\x01"
+
+// 对应C函数：`static void setpath (lua_State *L, const char *fieldname, const char *envname, const char *def)'
+// 环境变量通过OSPolicy读取
+func setPath(L *LuaState, fieldName string, envName string, def string) {
+	if path, ok := L.OSPolicy().Getenv(envName); !ok { /* no environment variable? */
+		L.PushString(def) /* use default */
+	} else {
+		/* replace ";;" by ";AUXMARK;" and then AUXMARK by default path */
+		path = L.LGSub(path, golua.LUA_PATHSEP+golua.LUA_PATHSEP, golua.LUA_PATHSEP+AUXMARK+golua.LUA_PATHSEP)
+		L.LGSub(path, AUXMARK, def)
+		L.Remove(-2)
+	}
+	L.SetField(-2, fieldName)
+}
+
+var pkFuncs = []golua.LReg{
+	{Name: "loadlib", Func: llLoadLib},
+	{Name: "seeall", Func: llSeeAll},
+}
+
+var llFuncs = []golua.LReg{
+	{Name: "module", Func: llModule},
+	{Name: "require", Func: llRequire},
+}
+
+var loaders = []LuaCFunction{loaderPreload, loaderLua, loaderC, loaderCRoot}
+
+// LuaOpenPackage
+// 对应C函数：`LUALIB_API int luaopen_package (lua_State *L)'
+func LuaOpenPackage(L *LuaState) int {
+	/* create `package' table */
+	L.LRegister(LUA_LOADLIBNAME, pkFuncs)
+	if golua.LUA_COMPAT_LOADLIB {
+		L.GetField(-1, "loadlib")
+		L.SetField(golua.LUA_GLOBALSINDEX, "loadlib")
+	}
+	L.PushValue(-1)
+	L.Replace(golua.LUA_ENVIRONINDEX)
+	/* create `loaders' table */
+	L.CreateTable(len(loaders), 0)
+	/* fill it with pre-defined loaders */
+	for i, loader := range loaders {
+		L.PushCFunction(loader)
+		L.RawSetI(-2, i+1)
+	}
+	L.SetField(-2, "loaders")                                     /* put it in field `loaders' */
+	setPath(L, "path", golua.LUA_PATH, golua.LUA_PATH_DEFAULT)    /* set field `path' */
+	setPath(L, "cpath", golua.LUA_CPATH, golua.LUA_CPATH_DEFAULT) /* set field `cpath' */
+	/* store config information */
+	L.PushLiteral(golua.LUA_DIRSEP + "\n" + golua.LUA_PATHSEP + "\n" + golua.LUA_PATH_MARK + "\n" +
+		golua.LUA_EXECDIR + "\n" + golua.LUA_IGMARK)
+	L.SetField(-2, "config")
+	/* set field `loaded' */
+	L.LFindTable(golua.LUA_REGISTRYINDEX, "_LOADED", 2)
+	L.SetField(-2, "loaded")
+	/* set field `preload' */
+	L.NewTable()
+	L.SetField(-2, "preload")
+	L.PushValue(golua.LUA_GLOBALSINDEX)
+	L.LRegister("", llFuncs) /* open lib into global table */
+	L.Pop(1)
+	return 1 /* return 'package' table */
+}
+
+// ErrNoPackageLib 在package库打开之前调用Preload时返回
+var ErrNoPackageLib = errors.New("package library is not open")
+
+// Preload
+// 把用Go实现的模块注册到package.preload中，require(name)时以模块名为参数调用open，
+// 它的返回值成为模块的值。必须在打开package库（LuaOpenPackage或OpenLibs）之后调用，
+// 否则什么也不做并返回ErrNoPackageLib。
+func Preload(L *LuaState, name string, open LuaCFunction) error {
+	L.GetField(golua.LUA_REGISTRYINDEX, "_LOADED")
+	if !L.IsTable(-1) {
+		L.Pop(1)
+		return ErrNoPackageLib
+	}
+	L.GetField(-1, LUA_LOADLIBNAME)
+	if !L.IsTable(-1) {
+		L.Pop(2)
+		return ErrNoPackageLib
+	}
+	L.GetField(-1, "preload")
+	L.PushCFunction(open)
+	L.SetField(-2, name)
+	L.Pop(3)
+	return nil
+}
@@ -0,0 +1,142 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	golua "luar/lua"
+)
+
+var modules = fstest.MapFS{
+	"a.lua":          {Data: []byte("#!/usr/bin/env lua\nlocal M = {name = ...}\nfunction M.fail() error('boom') end\nreturn M\n")},
+	"pkg/b.lua":      {Data: []byte("module(..., package.seeall)\nvalue = 42\nfunction info() return _NAME, _PACKAGE, type(print) end\n")},
+	"pkg/c/init.lua": {Data: []byte("return {dir = true}\n")},
+	"noreturn.lua":   {Data: []byte("loaded_noreturn = (loaded_noreturn or 0) + 1\n")},
+	"loop.lua":       {Data: []byte("require 'loop'\n")},
+	"bad.lua":        {Data: []byte("x = = 1\n")},
+}
+
+// doStringFS 在从fsys加载模块的新状态机中执行chunk
+func doStringFS(t *testing.T, fsys *fstest.MapFS, chunk string) error {
+	t.Helper()
+	var L = golua.LuaOpen()
+	defer L.Close()
+	OpenLibs(L)
+	if fsys != nil {
+		L.SetPackageFS(fsys)
+	}
+	return L.LDoStringErr(chunk)
+}
+
+func TestRequireFS(t *testing.T) {
+	err := doStringFS(t, &modules, `
+	package.path = "./?.lua;?/init.lua;/abs/?.lua"
+	local a = require "a"
+	assert(a.name == "a" and require("a") == a and package.loaded.a == a)
+	local ok, msg = pcall(a.fail)
+	assert(msg == "a.lua:3: boom", msg)                   -- the #! line still counts
+	local b = require "pkg.b"
+	assert(b == pkg.b and b.value == 42)
+	local name, pkgname, tp = b.info()
+	assert(name == "pkg.b" and pkgname == "pkg." and tp == "function")
+	assert(require("pkg.c").dir == true)
+	assert(require("noreturn") == true and require("noreturn") == true and loaded_noreturn == 1)
+
+	ok, msg = pcall(require, "loop")
+	assert(not ok and msg:find("loop or previous error loading module 'loop'", 1, true), msg)
+	ok, msg = pcall(require, "bad")
+	assert(not ok and msg:find("error loading module 'bad' from file './bad.lua':\n\tbad.lua:1:", 1, true), msg)
+	ok, msg = pcall(require, "nope")
+	assert(not ok and msg:find("module 'nope' not found:\n\tno field package.preload['nope']\n" ..
+		"\tno file './nope.lua'\n\tno file 'nope/init.lua'\n\tno file '/abs/nope.lua'\n", 1, true), msg)
+
+	package.path = {}
+	ok, msg = pcall(require, "other")
+	assert(not ok and msg:find("'package.path' must be a string", 1, true), msg)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequireOSFileSystem(t *testing.T) {
+	var dir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "m.lua"), []byte("return {from = 'disk'}"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	err := doStringFS(t, nil, `
+	package.path = `+strings.ReplaceAll(quoteLua(dir+"/?.lua"), "\\", "\\\\")+`
+	assert(require("m").from == "disk")
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// quoteLua 把s写成Lua的字符串字面量
+func quoteLua(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "\\'") + "'"
+}
+
+func TestPreload(t *testing.T) {
+	var L = golua.LuaOpen()
+	defer L.Close()
+	var silent = func(L *LuaState) int { return 0 }
+	if err := Preload(L, "silent", silent); err != ErrNoPackageLib {
+		t.Fatalf("Preload before opening package: %v", err)
+	}
+	if L.GetTop() != 0 {
+		t.Fatalf("Preload left %d values on the stack", L.GetTop())
+	}
+	OpenLibs(L)
+	var calls int
+	if err := Preload(L, "native", func(L *LuaState) int {
+		calls++
+		L.NewTable()
+		L.PushValue(1)
+		L.SetField(-2, "name")
+		return 1
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Preload(L, "silent", silent); err != nil {
+		t.Fatal(err)
+	}
+	err := L.LDoStringErr(`
+	local n = require "native"
+	assert(n.name == "native" and require("native") == n and package.loaded.native == n)
+	assert(type(package.preload.native) == "function")
+	assert(require("silent") == true and package.loaded.silent == true)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("native module opened %d times, want 1", calls)
+	}
+}
+
+func TestPackagePathFromEnv(t *testing.T) {
+	var L = golua.LuaOpen()
+	defer L.Close()
+	L.SetOSPolicy(golua.OSPolicy{Getenv: func(name string) (string, bool) {
+		if name == "LUA_PATH" {
+			return "lib/?.lua;;", true
+		}
+		return "", false
+	}})
+	OpenLibs(L)
+	err := L.LDoStringErr(`
+	assert(package.path == "lib/?.lua;" .. "` + golua.LUA_PATH_DEFAULT + `;", package.path)
+	assert(package.cpath == "` + golua.LUA_CPATH_DEFAULT + `")
+	assert(package.config == "/\n;\n?\n!\n-")
+	local f, msg, where = package.loadlib("x.so", "luaopen_x")
+	assert(f == nil and where == "open" and loadlib == package.loadlib, msg)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,308 @@
+package lib
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	golua "luar/lua"
+)
+
+// 对应C函数：`static int os_execute (lua_State *L)'
+// C中system无法执行命令时返回-1，这里与os.remove一样返回nil和错误信息，宿主禁止执行命令时也是如此
+func osExecute(L *LuaState) int {
+	var command = L.LOptString(1, "")
+	var status, err = L.OSPolicy().Execute(command)
+	if err != nil {
+		return pushResult(L, err, command)
+	}
+	L.PushInteger(status)
+	return 1
+}
+
+// 对应C函数：`static int os_remove (lua_State *L)'
+func osRemove(L *LuaState) int {
+	var filename = L.LCheckString(1)
+	return pushResult(L, L.OSPolicy().Remove(filename), filename)
+}
+
+// 对应C函数：`static int os_rename (lua_State *L)'
+func osRename(L *LuaState) int {
+	var fromName = L.LCheckString(1)
+	var toName = L.LCheckString(2)
+	return pushResult(L, L.OSPolicy().Rename(fromName, toName), fromName)
+}
+
+// 对应C函数：`static int os_tmpname (lua_State *L)'
+func osTmpName(L *LuaState) int {
+	var name, err = L.OSPolicy().TmpName()
+	if err != nil {
+		return L.LError("unable to generate a unique filename")
+	}
+	L.PushString(name)
+	return 1
+}
+
+// 对应C函数：`static int os_getenv (lua_State *L)'
+func osGetenv(L *LuaState) int {
+	if v, ok := L.OSPolicy().Getenv(L.LCheckString(1)); ok {
+		L.PushString(v)
+	} else {
+		L.PushNil()
+	}
+	return 1
+}
+
+// 对应C函数：`static int os_clock (lua_State *L)'
+func osClock(L *LuaState) int {
+	L.PushNumber(L.OSPolicy().Clock())
+	return 1
+}
+
+/*
+** {======================================================
+** Time/Date operations
+** { year=%Y, month=%m, day=%d, hour=%H, min=%M, sec=%S,
+**   wday=%w+1, yday=%j, isdst=? }
+** =======================================================
+ */
+
+// 对应C函数：`static void setfield (lua_State *L, const char *key, int value)'
+func setField(L *LuaState, key string, value int) {
+	L.PushInteger(value)
+	L.SetField(-2, key)
+}
+
+// 对应C函数：`static void setboolfield (lua_State *L, const char *key, int value)'
+func setBoolField(L *LuaState, key string, value bool) {
+	L.PushBoolean(value)
+	L.SetField(-2, key)
+}
+
+// 对应C函数：`static int getfield (lua_State *L, const char *key, int d)'
+func getField(L *LuaState, key string, d int) int {
+	var res int
+	L.GetField(-1, key)
+	if L.IsNumber(-1) {
+		res = L.ToInteger(-1)
+	} else {
+		if d < 0 {
+			return L.LError("field "+golua.LUA_QS+" missing in date table", key)
+		}
+		res = d
+	}
+	L.Pop(1)
+	return res
+}
+
+var (
+	weekDays = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+	months   = []string{"January", "February", "March", "April", "May", "June",
+		"July", "August", "September", "October", "November", "December"}
+)
+
+// strftime 按C语言区域设置格式化单个转换说明符c，不认识的说明符原样输出
+// 对应C函数：`size_t strftime (char *s, size_t max, const char *format, const struct tm *tm)'
+func strftime(b []byte, c byte, t time.Time) []byte {
+	var num = func(v, width int) []byte {
+		return append(b, fmt.Sprintf("%0*d", width, v)...)
+	}
+	var yday = t.YearDay() - 1
+	var wday = int(t.Weekday())
+	switch c {
+	case 'a':
+		return append(b, weekDays[wday][:3]...)
+	case 'A':
+		return append(b, weekDays[wday]...)
+	case 'b', 'h':
+		return append(b, months[t.Month()-1][:3]...)
+	case 'B':
+		return append(b, months[t.Month()-1]...)
+	case 'c':
+		return append(b, t.Format("Mon Jan _2 15:04:05 2006")...)
+	case 'C':
+		return num(t.Year()/100, 2)
+	case 'd':
+		return num(t.Day(), 2)
+	case 'D', 'x':
+		return append(b, t.Format("01/02/06")...)
+	case 'e':
+		return append(b, fmt.Sprintf("%2d", t.Day())...)
+	case 'F':
+		return append(b, t.Format("2006-01-02")...)
+	case 'g':
+		var year, _ = t.ISOWeek()
+		return num(year%100, 2)
+	case 'G':
+		var year, _ = t.ISOWeek()
+		return num(year, 4)
+	case 'H':
+		return num(t.Hour(), 2)
+	case 'I':
+		return num((t.Hour()+11)%12+1, 2)
+	case 'j':
+		return num(yday+1, 3)
+	case 'm':
+		return num(int(t.Month()), 2)
+	case 'M':
+		return num(t.Minute(), 2)
+	case 'n':
+		return append(b, '\n')
+	case 'p':
+		return append(b, t.Format("PM")...)
+	case 'r':
+		return append(b, t.Format("03:04:05 PM")...)
+	case 'R':
+		return append(b, t.Format("15:04")...)
+	case 's':
+		return strconv.AppendInt(b, t.Unix(), 10)
+	case 'S':
+		return num(t.Second(), 2)
+	case 't':
+		return append(b, '\t')
+	case 'T', 'X':
+		return append(b, t.Format("15:04:05")...)
+	case 'u':
+		return num((wday+6)%7+1, 1)
+	case 'U':
+		return num((yday+7-wday)/7, 2)
+	case 'V':
+		var _, week = t.ISOWeek()
+		return num(week, 2)
+	case 'w':
+		return num(wday, 1)
+	case 'W':
+		return num((yday+7-(wday+6)%7)/7, 2)
+	case 'y':
+		return num(t.Year()%100, 2)
+	case 'Y':
+		return num(t.Year(), 1)
+	case 'z':
+		return append(b, t.Format("-0700")...)
+	case 'Z':
+		var name, _ = t.Zone()
+		return append(b, name...)
+	case '%':
+		return append(b, '%')
+	default:
+		return append(b, '%', c)
+	}
+}
+
+// 对应C函数：`static int os_date (lua_State *L)'
+func osDate(L *LuaState) int {
+	var s = L.LOptString(1, "%c")
+	var policy = L.OSPolicy()
+	var t time.Time
+	if L.IsNoneOrNil(2) {
+		t = policy.Now()
+	} else {
+		t = time.Unix(int64(L.LCheckNumber(2)), 0)
+	}
+	if len(s) > 0 && s[0] == '!' { /* UTC? */
+		t = t.UTC()
+		s = s[1:] /* skip `!' */
+	} else {
+		t = t.In(policy.Location)
+	}
+	if s == "*t" {
+		L.CreateTable(0, 9) /* 9 = number of fields */
+		setField(L, "sec", t.Second())
+		setField(L, "min", t.Minute())
+		setField(L, "hour", t.Hour())
+		setField(L, "day", t.Day())
+		setField(L, "month", int(t.Month()))
+		setField(L, "year", t.Year())
+		setField(L, "wday", int(t.Weekday())+1)
+		setField(L, "yday", t.YearDay())
+		setBoolField(L, "isdst", t.IsDST())
+	} else {
+		var b golua.LBuffer
+		L.LBuffInit(&b)
+		var buff []byte
+		for i := 0; i < len(s); i++ {
+			if s[i] != '%' || i+1 == len(s) { /* no conversion specifier? */
+				b.AddChar(s[i])
+			} else {
+				i++
+				buff = strftime(buff[:0], s[i], t)
+				b.AddLString(buff)
+			}
+		}
+		b.PushResult()
+	}
+	return 1
+}
+
+// 对应C函数：`static int os_time (lua_State *L)'
+// mktime的规范化由time.Date完成；isdst字段被忽略，夏令时由时区决定
+func osTime(L *LuaState) int {
+	var policy = L.OSPolicy()
+	var t time.Time
+	if L.IsNoneOrNil(1) { /* called without args? */
+		t = policy.Now() /* get current time */
+	} else {
+		L.LCheckType(1, golua.LUA_TTABLE)
+		L.SetTop(1) /* make sure table is at the top */
+		var sec = getField(L, "sec", 0)
+		var min = getField(L, "min", 0)
+		var hour = getField(L, "hour", 12)
+		var day = getField(L, "day", -1)
+		var month = getField(L, "month", -1)
+		var year = getField(L, "year", -1)
+		t = time.Date(year, time.Month(month), day, hour, min, sec, 0, policy.Location)
+	}
+	L.PushNumber(golua.LuaNumber(t.Unix()))
+	return 1
+}
+
+// 对应C函数：`static int os_difftime (lua_State *L)'
+func osDifftime(L *LuaState) int {
+	L.PushNumber(golua.LuaNumber(int64(L.LCheckNumber(1)) - int64(L.LOptNumber(2, 0))))
+	return 1
+}
+
+/* }====================================================== */
+
+// 对应C函数：`static int os_setlocale (lua_State *L)'
+// 只支持C语言区域设置
+func osSetLocale(L *LuaState) int {
+	var catNames = []string{"all", "collate", "ctype", "monetary", "numeric", "time"}
+	var l = L.LOptString(1, "")
+	L.LCheckOption(2, "all", catNames)
+	if L.IsNoneOrNil(1) || l == "" || l == "C" || l == "POSIX" {
+		L.PushLiteral("C")
+	} else {
+		L.PushNil()
+	}
+	return 1
+}
+
+// 对应C函数：`static int os_exit (lua_State *L)'
+// 宿主的Exit返回时（例如在沙箱中拒绝退出），以错误结束脚本
+func osExit(L *LuaState) int {
+	var code = L.LOptInt(1, 0)
+	L.OSPolicy().Exit(code)
+	return L.LError("exit with status %d", code)
+}
+
+var sysLib = []golua.LReg{
+	{Name: "clock", Func: osClock},
+	{Name: "date", Func: osDate},
+	{Name: "difftime", Func: osDifftime},
+	{Name: "execute", Func: osExecute},
+	{Name: "exit", Func: osExit},
+	{Name: "getenv", Func: osGetenv},
+	{Name: "remove", Func: osRemove},
+	{Name: "rename", Func: osRename},
+	{Name: "setlocale", Func: osSetLocale},
+	{Name: "time", Func: osTime},
+	{Name: "tmpname", Func: osTmpName},
+}
+
+// LuaOpenOS
+// 对应C函数：`LUALIB_API int luaopen_os (lua_State *L)'
+func LuaOpenOS(L *LuaState) int {
+	L.LRegister(LUA_OSLIBNAME, sysLib)
+	return 1
+}
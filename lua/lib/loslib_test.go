@@ -0,0 +1,142 @@
+package lib
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	golua "luar/lua"
+)
+
+// doStringPolicy 在使用策略p的新状态机中执行chunk
+func doStringPolicy(t *testing.T, p golua.OSPolicy, chunk string) error {
+	t.Helper()
+	var L = golua.LuaOpen()
+	defer L.Close()
+	OpenLibs(L)
+	L.SetOSPolicy(p)
+	return L.LDoStringErr(chunk)
+}
+
+/* Wednesday 2009-12-30 23:04:05 UTC, observed from a zone five hours east of UTC */
+var frozen = golua.OSPolicy{
+	Now:      func() time.Time { return time.Unix(1262214245, 0) },
+	Location: time.FixedZone("XST", 5*3600),
+	Clock:    func() float64 { return 1.5 },
+}
+
+func TestOSFrozenClock(t *testing.T) {
+	err := doStringPolicy(t, frozen, `
+	assert(os.time() == 1262214245 and os.clock() == 1.5)
+	assert(os.date("%Y-%m-%d %H:%M:%S %Z") == "2009-12-31 04:04:05 XST", os.date())
+	assert(os.date("!%Y-%m-%d %H:%M:%S") == "2009-12-30 23:04:05")
+	assert(os.date() == "Thu Dec 31 04:04:05 2009", os.date())
+	assert(os.date("!%c", 0) == "Thu Jan  1 00:00:00 1970")
+	local t = os.date("*t")
+	assert(t.year == 2009 and t.month == 12 and t.day == 31 and t.hour == 4)
+	assert(t.min == 4 and t.sec == 5 and t.wday == 5 and t.yday == 365 and t.isdst == false)
+	assert(os.time(t) == os.time())
+	assert(os.time{year=2010, month=1, day=1, hour=0} == 1262286000)
+	assert(os.time{year=2009, month=13, day=1, hour=0} == 1262286000) -- normalized like mktime
+	local ok, msg = pcall(os.time, {year=2009, month=1})
+	assert(not ok and msg:find("field 'day' missing in date table", 1, true), msg)
+	assert(os.difftime(os.time(), 1262214240) == 5)
+	assert(os.setlocale() == "C" and os.setlocale("fr_FR") == nil)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStrftime(t *testing.T) {
+	var tm = time.Date(2021, time.January, 3, 15, 7, 9, 0, time.UTC) /* a Sunday in ISO week 53 of 2020 */
+	tests := map[byte]string{
+		'a': "Sun", 'A': "Sunday", 'b': "Jan", 'B': "January", 'C': "20",
+		'd': "03", 'D': "01/03/21", 'e': " 3", 'F': "2021-01-03", 'g': "20",
+		'G': "2020", 'H': "15", 'I': "03", 'j': "003", 'm': "01", 'M': "07",
+		'p': "PM", 'r': "03:07:09 PM", 'R': "15:07", 's': "1609686429", 'S': "09",
+		'T': "15:07:09", 'u': "7", 'U': "01", 'V': "53", 'w': "0", 'W': "00",
+		'x': "01/03/21", 'X': "15:07:09", 'y': "21", 'Y': "2021", 'z': "+0000",
+		'Z': "UTC", '%': "%", 'q': "%q",
+	}
+	for c, want := range tests {
+		if got := string(strftime(nil, c, tm)); got != want {
+			t.Errorf("%%%c = %q, want %q", c, got, want)
+		}
+	}
+}
+
+func TestOSPolicyDenied(t *testing.T) {
+	var exitCode = -1
+	var env = map[string]string{"APP_MODE": "test"}
+	var p = golua.OSPolicy{
+		Getenv:  func(name string) (string, bool) { v, ok := env[name]; return v, ok },
+		Remove:  func(string) error { return os.ErrPermission },
+		Rename:  func(string, string) error { return os.ErrPermission },
+		TmpName: func() (string, error) { return "", os.ErrPermission },
+		Execute: func(string) (int, error) { return 0, os.ErrPermission },
+		Popen:   func(string, string) (*golua.FILE, error) { return nil, os.ErrPermission },
+		Exit:    func(code int) { exitCode = code },
+	}
+	err := doStringPolicy(t, p, `
+	assert(os.getenv("APP_MODE") == "test" and os.getenv("HOME") == nil)
+	local r, msg = os.execute("rm -rf /")
+	assert(r == nil and msg == "rm -rf /: permission denied", msg)
+	r, msg = io.popen("rm -rf /")
+	assert(r == nil and msg == "rm -rf /: permission denied", msg)
+	r, msg = os.remove("x")
+	assert(r == nil and msg == "x: permission denied", msg)
+	r, msg = os.rename("x", "y")
+	assert(r == nil and msg == "x: permission denied", msg)
+	local ok, msg = pcall(os.tmpname)
+	assert(not ok and msg:find("unable to generate a unique filename", 1, true), msg)
+	os.exit(3)
+	error("not reached")
+	`)
+	if exitCode != 3 {
+		t.Errorf("exit code = %d, want 3", exitCode)
+	}
+	if err == nil || !strings.Contains(err.Error(), "exit with status 3") {
+		t.Errorf("os.exit error = %v", err)
+	}
+}
+
+func TestOSFileSystem(t *testing.T) {
+	var dir = t.TempDir()
+	var p = golua.OSPolicy{
+		TmpName: func() (string, error) {
+			f, err := os.CreateTemp(dir, "lua_")
+			if err != nil {
+				return "", err
+			}
+			return f.Name(), f.Close()
+		},
+	}
+	err := doStringPolicy(t, p, `
+	local name = os.tmpname()
+	assert(io.open(name)):close()            -- tmpname creates the file
+	assert(os.rename(name, name .. ".x") == true)
+	assert(os.remove(name .. ".x") == true)
+	local r, msg, code = os.remove(name)
+	assert(r == nil and msg == name .. ": no such file or directory" and code == 2, msg)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("left files behind in %s: %v", dir, entries)
+	}
+
+	var ran []string
+	p.Execute = func(command string) (int, error) {
+		ran = append(ran, command)
+		return 7, nil
+	}
+	if err := doStringPolicy(t, p, `assert(os.execute("make") == 7 and os.execute() == 7)`); err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 2 || ran[0] != "make" || ran[1] != "" {
+		t.Errorf("os.execute ran %q", ran)
+	}
+}
@@ -0,0 +1,560 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	golua "luar/lua"
+	"luar/lua/pattern"
+)
+
+// 对应C函数：`static int str_len (lua_State *L)'
+func strLen(L *LuaState) int {
+	var _, l = L.LCheckLString(1)
+	L.PushInteger(l)
+	return 1
+}
+
+// 对应C函数：`static ptrdiff_t posrelat (ptrdiff_t pos, size_t len)'
+func posRelat(pos int, len int) int {
+	/* relative string position: negative means back from end */
+	if pos < 0 {
+		pos += len + 1
+	}
+	if pos >= 0 {
+		return pos
+	}
+	return 0
+}
+
+// 对应C函数：`static int str_sub (lua_State *L)'
+func strSub(L *LuaState) int {
+	var s, l = L.LCheckLString(1)
+	var start = posRelat(L.LCheckInteger(2), l)
+	var end = posRelat(L.LOptInteger(3, -1), l)
+	if start < 1 {
+		start = 1
+	}
+	if end > l {
+		end = l
+	}
+	if start <= end {
+		L.PushLString(s[start-1 : end])
+	} else {
+		L.PushLiteral("")
+	}
+	return 1
+}
+
+// 对应C函数：`static int str_reverse (lua_State *L)'
+func strReverse(L *LuaState) int {
+	var b golua.LBuffer
+	var s, l = L.LCheckLString(1)
+	L.LBuffInit(&b)
+	for l > 0 {
+		l--
+		b.AddChar(s[l])
+	}
+	b.PushResult()
+	return 1
+}
+
+// 对应C函数：`static int str_lower (lua_State *L)'
+func strLower(L *LuaState) int {
+	var b golua.LBuffer
+	var s, l = L.LCheckLString(1)
+	L.LBuffInit(&b)
+	for i := 0; i < l; i++ {
+		b.AddChar(toLower(s[i]))
+	}
+	b.PushResult()
+	return 1
+}
+
+// 对应C函数：`static int str_upper (lua_State *L)'
+func strUpper(L *LuaState) int {
+	var b golua.LBuffer
+	var s, l = L.LCheckLString(1)
+	L.LBuffInit(&b)
+	for i := 0; i < l; i++ {
+		b.AddChar(toUpper(s[i]))
+	}
+	b.PushResult()
+	return 1
+}
+
+// 对应C函数：`static int str_rep (lua_State *L)'
+func strRep(L *LuaState) int {
+	var b golua.LBuffer
+	var s, _ = L.LCheckLString(1)
+	var n = L.LCheckInteger(2)
+	L.LBuffInit(&b)
+	for ; n > 0; n-- {
+		b.AddLString(s)
+	}
+	b.PushResult()
+	return 1
+}
+
+// 对应C函数：`static int str_byte (lua_State *L)'
+func strByte(L *LuaState) int {
+	var s, l = L.LCheckLString(1)
+	var posi = posRelat(L.LOptInteger(2, 1), l)
+	var pose = posRelat(L.LOptInteger(3, posi), l)
+	if posi <= 0 {
+		posi = 1
+	}
+	if pose > l {
+		pose = l
+	}
+	if posi > pose {
+		return 0 /* empty interval; return no values */
+	}
+	var n = pose - posi + 1
+	if posi+n <= pose { /* overflow? */
+		L.LError("string slice too long")
+	}
+	L.LCheckStack(n, "string slice too long")
+	for i := 0; i < n; i++ {
+		L.PushInteger(int(s[posi+i-1]))
+	}
+	return n
+}
+
+// 对应C函数：`static int str_char (lua_State *L)'
+func strChar(L *LuaState) int {
+	var n = L.GetTop() /* number of arguments */
+	var b golua.LBuffer
+	L.LBuffInit(&b)
+	for i := 1; i <= n; i++ {
+		var c = L.LCheckInt(i)
+		L.LArgCheck(int(byte(c)) == c, i, "invalid value")
+		b.AddChar(byte(c))
+	}
+	b.PushResult()
+	return 1
+}
+
+// 对应C函数：`static int str_dump (lua_State *L)'
+// 与Lua 5.3一样接受可选的第二个参数strip，为true时不保存调试信息
+func strDump(L *LuaState) int {
+	var b golua.LBuffer
+	var strip = L.ToBoolean(2)
+	L.LCheckType(1, golua.LUA_TFUNCTION)
+	L.SetTop(1)
+	L.LBuffInit(&b)
+	var writer = func(L *LuaState, p []byte, sz int, ud interface{}) int {
+		ud.(*golua.LBuffer).AddLString(p[:sz])
+		return 0
+	} /* 对应C函数：`static int writer (lua_State *L, const void* b, size_t size, void* B)' */
+	if L.Dump(writer, &b, strip) != 0 {
+		L.LError("unable to dump given function")
+	}
+	b.PushResult()
+	return 1
+}
+
+/*
+** {======================================================
+** PATTERN MATCHING
+** 匹配引擎位于luar/lua/pattern包中
+** =======================================================
+ */
+
+// 对应C函数：`static void push_onecapture (MatchState *ms, int i, const char *s, const char *e)'
+func pushOneCapture(L *LuaState, r *pattern.Result, i int) {
+	var c, err = r.Capture(i)
+	if err != nil {
+		L.LError("%s", err.Error())
+	}
+	if c.Position {
+		L.PushInteger(c.Start + 1)
+	} else {
+		L.PushLString(c.Value)
+	}
+}
+
+// 对应C函数：`static int push_captures (MatchState *ms, const char *s, const char *e)'
+// whole为false时相当于C中s传入NULL，此时没有捕获也不压入整个匹配
+func pushCaptures(L *LuaState, r *pattern.Result, whole bool) int {
+	var nLevels = r.NumCaptures()
+	if nLevels == 0 && whole {
+		nLevels = 1
+	}
+	L.LCheckStack(nLevels, "too many captures")
+	for i := 0; i < nLevels; i++ {
+		pushOneCapture(L, r, i)
+	}
+	return nLevels /* number of strings pushed */
+}
+
+// 对应C函数：`static int str_find_aux (lua_State *L, int find)'
+func strFindAux(L *LuaState, find bool) int {
+	var s, l1 = L.LCheckLString(1)
+	var p, l2 = L.LCheckLString(2)
+	var init = posRelat(L.LOptInteger(3, 1), l1) - 1
+	if init < 0 {
+		init = 0
+	} else if init > l1 {
+		init = l1
+	}
+	if find && (L.ToBoolean(4) || /* explicit request? */
+		!bytes.ContainsAny(p, pattern.Specials)) { /* or no special characters? */
+		/* do a plain search */
+		if i := bytes.Index(s[init:], p); i >= 0 {
+			L.PushInteger(init + i + 1)
+			L.PushInteger(init + i + l2)
+			return 2
+		}
+	} else {
+		var r, err = pattern.Find(s, p, init)
+		if err != nil {
+			return L.LError("%s", err.Error())
+		}
+		if r != nil {
+			if find {
+				L.PushInteger(r.Start + 1) /* start */
+				L.PushInteger(r.End)       /* end */
+				return pushCaptures(L, r, false) + 2
+			}
+			return pushCaptures(L, r, true)
+		}
+	}
+	L.PushNil() /* not found */
+	return 1
+}
+
+// 对应C函数：`static int str_find (lua_State *L)'
+func strFind(L *LuaState) int {
+	return strFindAux(L, true)
+}
+
+// 对应C函数：`static int str_match (lua_State *L)'
+func strMatch(L *LuaState) int {
+	return strFindAux(L, false)
+}
+
+// 对应C函数：`static int gmatch_aux (lua_State *L)'
+func gmatchAux(L *LuaState) int {
+	var s, _ = L.ToLString(golua.LuaUpValueIndex(1))
+	var p, _ = L.ToLString(golua.LuaUpValueIndex(2))
+	var it = pattern.GMatch(s, p, L.ToInteger(golua.LuaUpValueIndex(3)))
+	var r, err = it.Next()
+	if err != nil {
+		return L.LError("%s", err.Error())
+	}
+	if r == nil {
+		return 0 /* not found */
+	}
+	L.PushInteger(it.Pos())
+	L.Replace(golua.LuaUpValueIndex(3))
+	return pushCaptures(L, r, true)
+}
+
+// 对应C函数：`static int gmatch (lua_State *L)'
+func gmatch(L *LuaState) int {
+	L.LCheckString(1)
+	L.LCheckString(2)
+	L.SetTop(2)
+	L.PushInteger(0)
+	L.PushCClosure(gmatchAux, 3)
+	return 1
+}
+
+// 对应C函数：`static void add_value (MatchState *ms, luaL_Buffer *b, const char *s, const char *e)'
+func addValue(L *LuaState, r *pattern.Result) ([]byte, bool, error) {
+	switch L.Type(3) {
+	case golua.LUA_TNUMBER, golua.LUA_TSTRING:
+		var news, _ = L.ToLString(3)
+		var repl, err = r.Expand(nil, news)
+		return repl, true, err
+	case golua.LUA_TFUNCTION:
+		L.PushValue(3)
+		var n = pushCaptures(L, r, true)
+		L.Call(n, 1)
+	case golua.LUA_TTABLE:
+		pushOneCapture(L, r, 0)
+		L.GetTable(3)
+	}
+	if !L.ToBoolean(-1) { /* nil or false? */
+		L.Pop(1)
+		return nil, false, nil /* keep original text */
+	} else if !L.IsString(-1) {
+		L.LError("invalid replacement value (a %s)", L.LTypeName(-1))
+	}
+	var repl, _ = L.ToLString(-1)
+	L.Pop(1)
+	return repl, true, nil
+}
+
+// 对应C函数：`static int str_gsub (lua_State *L)'
+func strGsub(L *LuaState) int {
+	var src, srcl = L.LCheckLString(1)
+	var p, _ = L.LCheckLString(2)
+	var tr = L.Type(3)
+	var maxS = L.LOptInt(4, srcl+1)
+	if maxS < 0 {
+		maxS = 0 /* a negative limit means no substitutions, as in C */
+	}
+	L.LArgCheck(tr == golua.LUA_TNUMBER || tr == golua.LUA_TSTRING ||
+		tr == golua.LUA_TFUNCTION || tr == golua.LUA_TTABLE, 3,
+		"string/function/table expected")
+	var out, n, err = pattern.GSub(src, p, maxS, func(r *pattern.Result) ([]byte, bool, error) {
+		return addValue(L, r)
+	})
+	if err != nil {
+		return L.LError("%s", err.Error())
+	}
+	L.PushLString(out)
+	L.PushInteger(n) /* number of substitutions */
+	return 2
+}
+
+/* }====================================================== */
+
+// FLAGS valid flags in a format specification
+const FLAGS = "-+ #0"
+
+// 对应C函数：`static void addquoted (lua_State *L, luaL_Buffer *b, int arg)'
+func addQuoted(L *LuaState, b *golua.LBuffer, arg int) {
+	var s, l = L.LCheckLString(arg)
+	b.AddChar('"')
+	for i := 0; i < l; i++ {
+		switch s[i] {
+		case '"', '\\', '\n':
+			b.AddChar('\\')
+			b.AddChar(s[i])
+		case '\r':
+			b.AddString("\\r")
+		case 0:
+			b.AddString("\\000")
+		default:
+			b.AddChar(s[i])
+		}
+	}
+	b.AddChar('"')
+}
+
+// 对应C函数：`static const char *scanformat (lua_State *L, const char *strfrmt, char *form)'
+// 返回包含转换字符的格式说明（如"%-5.2f"）以及转换字符所在的位置
+func scanFormat(L *LuaState, strfrmt []byte, p int) (string, int) {
+	var at = func(i int) byte {
+		if i < len(strfrmt) {
+			return strfrmt[i]
+		}
+		return 0
+	}
+	var p0 = p
+	for at(p) != 0 && strings.IndexByte(FLAGS, at(p)) >= 0 {
+		p++ /* skip flags */
+	}
+	if p-p0 > len(FLAGS) {
+		L.LError("invalid format (repeated flags)")
+	}
+	if isDigit(at(p)) {
+		p++ /* skip width */
+	}
+	if isDigit(at(p)) {
+		p++ /* (2 digits at most) */
+	}
+	if at(p) == '.' {
+		p++
+		if isDigit(at(p)) {
+			p++ /* skip precision */
+		}
+		if isDigit(at(p)) {
+			p++ /* (2 digits at most) */
+		}
+	}
+	if isDigit(at(p)) {
+		L.LError("invalid format (width or precision too long)")
+	}
+	return "%" + string(strfrmt[p0:p]) + string([]byte{at(p)}), p
+}
+
+// formatSpec 解析scanFormat得到的格式说明中的标志、宽度和精度，精度缺省时为-1
+func formatSpec(form string) (flags string, width int, prec int) {
+	var spec = strings.TrimLeft(form[1:len(form)-1], FLAGS)
+	flags = form[1 : len(form)-len(spec)-1]
+	prec = -1
+	if i := strings.IndexByte(spec, '.'); i >= 0 {
+		prec, _ = strconv.Atoi(spec[i+1:]) /* "%.s" means precision 0 */
+		spec = spec[:i]
+	}
+	width, _ = strconv.Atoi(spec)
+	return
+}
+
+// padBytes 按C中printf的规则用空格把s填充到指定宽度，宽度按字节计算
+func padBytes(s []byte, flags string, width int) []byte {
+	if len(s) >= width {
+		return s
+	}
+	var pad = bytes.Repeat([]byte{' '}, width-len(s))
+	if strings.IndexByte(flags, '-') >= 0 {
+		return append(append([]byte{}, s...), pad...)
+	}
+	return append(pad, s...)
+}
+
+// formatFloat 按C中printf的规则格式化浮点数，inf和nan的写法与C一致
+func formatFloat(form string, n golua.LuaNumber) string {
+	var conv = form[len(form)-1]
+	if math.IsInf(n, 0) || math.IsNaN(n) {
+		var flags, width, _ = formatSpec(form)
+		var s = "inf"
+		if math.IsNaN(n) {
+			s = "nan"
+		}
+		if n < 0 {
+			s = "-" + s
+		} else if strings.IndexByte(flags, '+') >= 0 {
+			s = "+" + s
+		} else if strings.IndexByte(flags, ' ') >= 0 {
+			s = " " + s
+		}
+		if conv == 'E' || conv == 'G' {
+			s = strings.ToUpper(s)
+		}
+		return string(padBytes([]byte(s), flags, width))
+	}
+	if (conv == 'g' || conv == 'G') && strings.IndexByte(form, '.') < 0 {
+		form = form[:len(form)-1] + ".6" + string(conv) /* Go defaults to the shortest representation */
+	}
+	return fmt.Sprintf(form, n)
+}
+
+// 对应C函数：`static int str_format (lua_State *L)'
+func strFormat(L *LuaState) int {
+	var top = L.GetTop()
+	var arg = 1
+	var strfrmt, sfl = L.LCheckLString(arg)
+	var b golua.LBuffer
+	L.LBuffInit(&b)
+	for i := 0; i < sfl; {
+		if strfrmt[i] != '%' {
+			b.AddChar(strfrmt[i])
+			i++
+			continue
+		}
+		i++
+		if i < sfl && strfrmt[i] == '%' {
+			b.AddChar('%') /* %% */
+			i++
+			continue
+		}
+		/* format item */
+		arg++
+		if arg > top {
+			L.LArgError(arg, "no value")
+		}
+		var form string
+		form, i = scanFormat(L, strfrmt, i)
+		var conv = form[len(form)-1]
+		i++
+		switch conv {
+		case 'c':
+			var flags, width, _ = formatSpec(form)
+			b.AddLString(padBytes([]byte{byte(L.LCheckNumber(arg))}, flags, width))
+		case 'd', 'i':
+			form = form[:len(form)-1] + "d"
+			b.AddString(fmt.Sprintf(form, int64(L.LCheckNumber(arg))))
+		case 'o', 'u', 'x', 'X':
+			var n = uint64(int64(L.LCheckNumber(arg)))
+			if conv == 'u' {
+				form = form[:len(form)-1] + "d"
+			} else if n == 0 && conv != 'o' {
+				form = strings.Replace(form, "#", "", -1) /* C prints no prefix for zero */
+			}
+			b.AddString(fmt.Sprintf(form, n))
+		case 'e', 'E', 'f', 'g', 'G':
+			b.AddString(formatFloat(form, L.LCheckNumber(arg)))
+		case 'q':
+			addQuoted(L, &b, arg)
+			continue /* skip the 'addsize' at the end */
+		case 's':
+			var s, l = L.LCheckLString(arg)
+			if strings.IndexByte(form, '.') < 0 && l >= 100 {
+				/* no precision and string is too long to be formatted;
+				   keep original string */
+				L.PushValue(arg)
+				b.AddValue()
+				continue /* skip the `addsize' at the end */
+			}
+			if z := bytes.IndexByte(s, 0); z >= 0 {
+				s = s[:z] /* C's sprintf stops at the first '\0' */
+			}
+			var flags, width, prec = formatSpec(form)
+			if prec >= 0 && len(s) > prec {
+				s = s[:prec]
+			}
+			b.AddLString(padBytes(s, flags, width))
+		default: /* also treat cases `pnLlh' */
+			return L.LError("invalid option '%%%c' to 'format'", conv)
+		}
+	}
+	b.PushResult()
+	return 1
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func toLower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + ('a' - 'A')
+	}
+	return c
+}
+
+func toUpper(c byte) byte {
+	if 'a' <= c && c <= 'z' {
+		return c - ('a' - 'A')
+	}
+	return c
+}
+
+var strLib = []golua.LReg{
+	{Name: "byte", Func: strByte},
+	{Name: "char", Func: strChar},
+	{Name: "dump", Func: strDump},
+	{Name: "find", Func: strFind},
+	{Name: "format", Func: strFormat},
+	{Name: "gmatch", Func: gmatch},
+	{Name: "gsub", Func: strGsub},
+	{Name: "len", Func: strLen},
+	{Name: "lower", Func: strLower},
+	{Name: "match", Func: strMatch},
+	{Name: "rep", Func: strRep},
+	{Name: "reverse", Func: strReverse},
+	{Name: "sub", Func: strSub},
+	{Name: "upper", Func: strUpper},
+}
+
+// 对应C函数：`static void createmetatable (lua_State *L)'
+func createMetaTable(L *LuaState) {
+	L.CreateTable(0, 1) /* create metatable for strings */
+	L.PushLiteral("")   /* dummy string */
+	L.PushValue(-2)
+	L.SetMetaTable(-2)        /* set string metatable */
+	L.Pop(1)                  /* pop dummy string */
+	L.PushValue(-2)           /* string library... */
+	L.SetField(-2, "__index") /* ...is the __index metamethod */
+	L.Pop(1)                  /* pop metatable */
+}
+
+// LuaOpenString
+// 对应C函数：`LUALIB_API int luaopen_string (lua_State *L)'
+func LuaOpenString(L *LuaState) int {
+	L.LRegister(LUA_STRLIBNAME, strLib)
+	if golua.LUA_COMPAT_GFIND {
+		L.GetField(-1, "gmatch")
+		L.SetField(-2, "gfind")
+	}
+	createMetaTable(L)
+	return 1
+}
@@ -0,0 +1,188 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+
+	golua "luar/lua"
+)
+
+// results 在同一个状态机中依次求值每个表达式，所有返回值经tostring后用逗号连接；
+// 出错时返回"error: "加上错误消息
+func results(t *testing.T, exprs []string) []string {
+	t.Helper()
+	var L = golua.LuaOpen()
+	defer L.Close()
+	OpenLibs(L)
+	var out []string
+	for _, e := range exprs {
+		err := L.LDoStringErr(`return (function(...)
+			local t = {}
+			for i = 1, select("#", ...) do t[i] = tostring((select(i, ...))) end
+			return table.concat(t, ",")
+		end)(` + e + `)`)
+		if err != nil {
+			out = append(out, "error: "+err.Error())
+		} else {
+			out = append(out, L.ToString(-1))
+			L.Pop(1)
+		}
+	}
+	return out
+}
+
+// checkResults 对比每个表达式的结果，want以"error: "开头时只要求错误消息包含其后的内容
+func checkResults(t *testing.T, cases [][2]string) {
+	t.Helper()
+	var exprs []string
+	for _, c := range cases {
+		exprs = append(exprs, c[0])
+	}
+	for i, got := range results(t, exprs) {
+		var want = cases[i][1]
+		if msg, ok := strings.CutPrefix(want, "error: "); ok {
+			if !strings.HasPrefix(got, "error: ") || !strings.Contains(got, msg) {
+				t.Errorf("%s: got %q, want an error containing %q", cases[i][0], got, msg)
+			}
+		} else if got != want {
+			t.Errorf("%s: got %q, want %q", cases[i][0], got, want)
+		}
+	}
+}
+
+func TestStringFormat(t *testing.T) {
+	checkResults(t, [][2]string{
+		{`string.format("%d|%5d|%-5d|%05d", 42, 42, 42, -42)`, "42|   42|42   |-0042"},
+		{`string.format("%+d|% d|%.3d|%i", 3, 3, 7, -3.9)`, "+3| 3|007|-3"},
+		{`string.format("%d", 3.9)`, "3"},
+		{`string.format("%x|%X|%#x|%#x|%08x", 255, 255, 255, 0, 255)`, "ff|FF|0xff|0|000000ff"},
+		{`string.format("%o|%#o|%u", 8, 8, 3)`, "10|010|3"},
+		{`string.format("%e|%.2E|%10.3e", 12345.678, 0.000123, 1.5)`, "1.234568e+04|1.23E-04| 1.500e+00"},
+		{`string.format("%g|%g|%g|%g|%.3g", 100000, 1e20, 0.0001, 1e-5, 3.14159)`, "100000|1e+20|0.0001|1e-05|3.14"},
+		{`string.format("%.2f|%6.1f|%f|%5.1f", 3.14159, -2.75, math.huge, -math.huge)`, "3.14|  -2.8|inf| -inf"},
+		{`string.format("%q", 'a "b"\n\0c\r')`, "\"a \\\"b\\\"\\\n\\000c\\r\""},
+		{`string.format("%5s|%-5s|%.2s|%s", "ab", "ab", "abc", 12)`, "   ab|ab   |ab|12"},
+		{`string.format("%s", string.rep("x", 120)) == string.rep("x", 120)`, "true"},
+		{`string.format("%c%c|100%%", 72, 105)`, "Hi|100%"},
+		{`string.format("%y", 1)`, "error: invalid option '%y' to 'format'"},
+		{`string.format("%d")`, "error: bad argument #2 to 'format' (no value)"},
+		{`string.format("%d", "x")`, "error: bad argument #2 to 'format' (number expected, got string)"},
+	})
+}
+
+func TestStringGsub(t *testing.T) {
+	checkResults(t, [][2]string{
+		{`string.gsub("hello world", "o", "0")`, "hell0 w0rld,2"},
+		{`string.gsub("hello world", "o", "0", 1)`, "hell0 world,1"},
+		{`string.gsub("hello world", "o", "0", 0)`, "hello world,0"},
+		{`string.gsub("abc", "%w", "%0%0")`, "aabbcc,3"},
+		{`string.gsub("hello world", "(%w+)", "<%1>")`, "<hello> <world>,2"},
+		{`string.gsub("a.b", "%.", "%%")`, "a%b,1"},
+		{`string.gsub("$name is $age", "%$(%w+)", {name = "Lua", age = 15})`, "Lua is 15,2"},
+		{`string.gsub("$x $y", "%$(%w+)", {x = "1"})`, "1 $y,2"},
+		{`string.gsub("abc", "%w", function(c) return c:upper() .. "." end)`, "A.B.C.,3"},
+		{`string.gsub("abc", "b", function() return false end)`, "abc,1"},
+		{`string.gsub("abc", "%w", function() end, 2)`, "abc,2"},
+		{`string.gsub("aaa", "^a", "b")`, "baa,1"},
+		{`string.gsub("abc", "", "-")`, "-a-b-c-,4"},
+		{`string.gsub("abc", "(a)", "%2")`, "error: invalid capture index"},
+		{`string.gsub("abc", "a", true)`, "error: bad argument #3 to 'gsub' (string/function/table expected)"},
+		{`string.gsub("abc", "a", {a = {}})`, "error: invalid replacement value (a table)"},
+	})
+}
+
+func TestStringFind(t *testing.T) {
+	checkResults(t, [][2]string{
+		{`string.find("a.b", ".")`, "1,1"},
+		{`string.find("a.b", ".", 1, true)`, "2,2"},
+		{`string.find("a+b", "+", 1, true)`, "2,2"},
+		{`string.find("hello", "l", -2)`, "4,4"},
+		{`string.find("hello", "l", -10)`, "3,3"},
+		{`string.find("hello", "l", 10)`, "nil"},
+		{`string.find("hello", "(l)(l)")`, "3,4,l,l"},
+		{`string.find("hello", "")`, "1,0"},
+		{`string.find("", "")`, "1,0"},
+		{`string.find("hello", "xyz")`, "nil"},
+		{`string.match("key=val", "(%w+)=(%w+)")`, "key,val"},
+		{`string.match("  x", "()x")`, "3"},
+		{`string.match("hello", ".", -1)`, "o"},
+	})
+}
+
+func TestStringBasics(t *testing.T) {
+	checkResults(t, [][2]string{
+		{`string.byte("ABC", 1, -1)`, "65,66,67"},
+		{`string.byte("A")`, "65"},
+		{`string.byte("ABC", 10)`, ""},
+		{`string.char(72, 105)`, "Hi"},
+		{`string.char()`, ""},
+		{`string.char(256)`, "error: invalid value"},
+		{`string.rep("ab", 3)`, "ababab"},
+		{`string.rep("ab", 0)`, ""},
+		{`string.rep("ab", -1)`, ""},
+		{`string.sub("hello", 2, -2)`, "ell"},
+		{`string.upper("abc"), string.lower("ABC"), string.len("\0a")`, "ABC,abc,2"},
+		{`string.reverse("abc")`, "cba"},
+	})
+}
+
+func TestStringMetatable(t *testing.T) {
+	checkResults(t, [][2]string{
+		{`("x"):rep(3)`, "xxx"},
+		{`("%d-%s"):format(5, "a")`, "5-a"},
+		{`getmetatable("").__index == string`, "true"},
+		{`("abc"):upper():lower():len()`, "3"},
+		{`("x").nosuch`, "nil"},
+	})
+}
+
+func TestStringGmatch(t *testing.T) {
+	err := doString(t, `
+	local words = {}
+	for w in string.gmatch("one two  three", "%a+") do words[#words + 1] = w end
+	assert(#words == 3 and words[3] == "three")
+	local t = {}
+	for k, v in string.gmatch("a=1, b=2", "(%w+)=(%w+)") do t[k] = tonumber(v) end
+	assert(t.a == 1 and t.b == 2)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStringDump(t *testing.T) {
+	err := doString(t, `
+	local function counter(start)
+		local n = start
+		return function(step)
+			n = n + (step or 1)
+			return n, "count", true, nil, 0.5
+		end
+	end
+	local f = loadstring(string.dump(counter))
+	local c = f(10)
+	assert(c() == 11 and c(4) == 15)
+	local n, s, b, z, h = c()
+	assert(n == 16 and s == "count" and b == true and z == nil and h == 0.5)
+
+	-- the dumped chunk keeps the debug information unless stripped
+	local function fail() error("boom") end
+	local _, msg = pcall(loadstring(string.dump(fail)))
+	assert(string.find(msg, ":%d+: boom$"), msg)
+	local stripped = string.dump(fail, true)
+	assert(#stripped < #string.dump(fail))
+	_, msg = pcall(loadstring(stripped))
+	assert(msg == "boom", msg) -- no line information to add
+
+	-- a dump of a loaded dump is identical
+	local d = string.dump(counter)
+	assert(string.dump(loadstring(d)) == d)
+	assert(string.sub(d, 1, 5) == "\27LuaQ")
+
+	assert(not pcall(string.dump, print))
+	assert(not pcall(string.dump, {}))
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,295 @@
+package lib
+
+import golua "luar/lua"
+
+// 对应C函数：`aux_getn(L,n)'
+func auxGetN(L *LuaState, n int) int {
+	L.LCheckType(n, golua.LUA_TTABLE)
+	return L.LGetN(n)
+}
+
+// 对应C函数：`static int foreachi (lua_State *L)'
+func foreachi(L *LuaState) int {
+	var n = auxGetN(L, 1)
+	L.LCheckType(2, golua.LUA_TFUNCTION)
+	for i := 1; i <= n; i++ {
+		L.PushValue(2)   /* function */
+		L.PushInteger(i) /* 1st argument */
+		L.RawGetI(1, i)  /* 2nd argument */
+		L.Call(2, 1)
+		if !L.IsNil(-1) {
+			return 1
+		}
+		L.Pop(1) /* remove nil result */
+	}
+	return 0
+}
+
+// 对应C函数：`static int foreach (lua_State *L)'
+func foreach(L *LuaState) int {
+	L.LCheckType(1, golua.LUA_TTABLE)
+	L.LCheckType(2, golua.LUA_TFUNCTION)
+	L.PushNil() /* first key */
+	for L.LuaNext(1) {
+		L.PushValue(2)  /* function */
+		L.PushValue(-3) /* key */
+		L.PushValue(-3) /* value */
+		L.Call(2, 1)
+		if !L.IsNil(-1) {
+			return 1
+		}
+		L.Pop(2) /* remove value and result */
+	}
+	return 0
+}
+
+// 对应C函数：`static int maxn (lua_State *L)'
+func maxn(L *LuaState) int {
+	var max golua.LuaNumber = 0
+	L.LCheckType(1, golua.LUA_TTABLE)
+	L.PushNil() /* first key */
+	for L.LuaNext(1) {
+		L.Pop(1) /* remove value */
+		if L.Type(-1) == golua.LUA_TNUMBER {
+			if v := L.ToNumber(-1); v > max {
+				max = v
+			}
+		}
+	}
+	L.PushNumber(max)
+	return 1
+}
+
+// 对应C函数：`static int getn (lua_State *L)'
+func getn(L *LuaState) int {
+	L.PushInteger(auxGetN(L, 1))
+	return 1
+}
+
+// 对应C函数：`static int setn (lua_State *L)'
+func setn(L *LuaState) int {
+	L.LCheckType(1, golua.LUA_TTABLE)
+	L.LError("'setn' is obsolete") /* luaL_setn is defined as a no-op */
+	L.PushValue(1)
+	return 1
+}
+
+// 对应C函数：`static int tinsert (lua_State *L)'
+func tinsert(L *LuaState) int {
+	var e = auxGetN(L, 1) + 1 /* first empty element */
+	var pos int               /* where to insert new element */
+	switch L.GetTop() {
+	case 2: /* called with only 2 arguments */
+		pos = e /* insert new element at the end */
+	case 3:
+		pos = L.LCheckInt(2) /* 2nd argument is the position */
+		if pos > e {
+			e = pos /* `grow' array if necessary */
+		}
+		for i := e; i > pos; i-- { /* move up elements */
+			L.RawGetI(1, i-1)
+			L.RawSetI(1, i) /* t[i] = t[i-1] */
+		}
+	default:
+		return L.LError("wrong number of arguments to 'insert'")
+	}
+	L.RawSetI(1, pos) /* t[pos] = v */
+	return 0
+}
+
+// 对应C函数：`static int tremove (lua_State *L)'
+func tremove(L *LuaState) int {
+	var e = auxGetN(L, 1)
+	var pos = L.LOptInt(2, e)
+	if !(1 <= pos && pos <= e) { /* position is outside bounds? */
+		return 0 /* nothing to remove */
+	}
+	L.RawGetI(1, pos) /* result = t[pos] */
+	for ; pos < e; pos++ {
+		L.RawGetI(1, pos+1)
+		L.RawSetI(1, pos) /* t[pos] = t[pos+1] */
+	}
+	L.PushNil()
+	L.RawSetI(1, e) /* t[e] = nil */
+	return 1
+}
+
+// 对应C函数：`static void addfield (lua_State *L, luaL_Buffer *b, int i)'
+func addField(L *LuaState, b *golua.LBuffer, i int) {
+	L.RawGetI(1, i)
+	if !L.IsString(-1) {
+		L.LError("invalid value (at index %d) in table for 'concat'", i)
+	}
+	b.AddValue()
+}
+
+// 对应C函数：`static int tconcat (lua_State *L)'
+func tconcat(L *LuaState) int {
+	var b golua.LBuffer
+	var sep, _ = L.LOptLString(2, "")
+	L.LCheckType(1, golua.LUA_TTABLE)
+	var i = L.LOptInt(3, 1)
+	var last int
+	if L.IsNoneOrNil(4) {
+		last = L.LGetN(1)
+	} else {
+		last = L.LCheckInt(4)
+	}
+	L.LBuffInit(&b)
+	for ; i < last; i++ {
+		addField(L, &b, i)
+		b.AddLString(sep)
+	}
+	if i == last { /* add last value (if interval was not empty) */
+		addField(L, &b, i)
+	}
+	b.PushResult()
+	return 1
+}
+
+/*
+** {======================================================
+** Quicksort
+** (based on `Algorithms in MODULA-3', Robert Sedgewick;
+**  Addison-Wesley, 1993.)
+ */
+
+// 对应C函数：`static void set2 (lua_State *L, int i, int j)'
+func set2(L *LuaState, i int, j int) {
+	L.RawSetI(1, i)
+	L.RawSetI(1, j)
+}
+
+// 对应C函数：`static int sort_comp (lua_State *L, int a, int b)'
+func sortComp(L *LuaState, a int, b int) bool {
+	if !L.IsNil(2) { /* function? */
+		L.PushValue(2)
+		L.PushValue(a - 1) /* -1 to compensate function */
+		L.PushValue(b - 2) /* -2 to compensate function and `a' */
+		L.Call(2, 1)
+		var res = L.ToBoolean(-1)
+		L.Pop(1)
+		return res
+	}
+	return L.LessThan(a, b) /* a < b? */
+}
+
+// 对应C函数：`static void auxsort (lua_State *L, int l, int u)'
+func auxSort(L *LuaState, l int, u int) {
+	for l < u { /* for tail recursion */
+		/* sort elements a[l], a[(l+u)/2] and a[u] */
+		L.RawGetI(1, l)
+		L.RawGetI(1, u)
+		if sortComp(L, -1, -2) { /* a[u] < a[l]? */
+			set2(L, l, u) /* swap a[l] - a[u] */
+		} else {
+			L.Pop(2)
+		}
+		if u-l == 1 {
+			break /* only 2 elements */
+		}
+		var i = (l + u) / 2
+		L.RawGetI(1, i)
+		L.RawGetI(1, l)
+		if sortComp(L, -2, -1) { /* a[i]<a[l]? */
+			set2(L, i, l)
+		} else {
+			L.Pop(1) /* remove a[l] */
+			L.RawGetI(1, u)
+			if sortComp(L, -1, -2) { /* a[u]<a[i]? */
+				set2(L, i, u)
+			} else {
+				L.Pop(2)
+			}
+		}
+		if u-l == 2 {
+			break /* only 3 elements */
+		}
+		L.RawGetI(1, i) /* Pivot */
+		L.PushValue(-1)
+		L.RawGetI(1, u-1)
+		set2(L, i, u-1)
+		/* a[l] <= P == a[u-1] <= a[u], only need to sort from l+1 to u-2 */
+		i = l
+		var j = u - 1
+		for { /* invariant: a[l..i] <= P <= a[j..u] */
+			/* repeat ++i until a[i] >= P */
+			for {
+				i++
+				L.RawGetI(1, i)
+				if !sortComp(L, -1, -2) {
+					break
+				}
+				if i > u {
+					L.LError("invalid order function for sorting")
+				}
+				L.Pop(1) /* remove a[i] */
+			}
+			/* repeat --j until a[j] <= P */
+			for {
+				j--
+				L.RawGetI(1, j)
+				if !sortComp(L, -3, -1) {
+					break
+				}
+				if j < l {
+					L.LError("invalid order function for sorting")
+				}
+				L.Pop(1) /* remove a[j] */
+			}
+			if j < i {
+				L.Pop(3) /* pop pivot, a[i], a[j] */
+				break
+			}
+			set2(L, i, j)
+		}
+		L.RawGetI(1, u-1)
+		L.RawGetI(1, i)
+		set2(L, u-1, i) /* swap pivot (a[u-1]) with a[i] */
+		/* a[l..i-1] <= a[i] == P <= a[i+1..u] */
+		/* adjust so that smaller half is in [j..i] and larger one in [l..u] */
+		if i-l < u-i {
+			j = l
+			i = i - 1
+			l = i + 2
+		} else {
+			j = i + 1
+			i = u
+			u = j - 2
+		}
+		auxSort(L, j, i) /* call recursively the smaller one */
+	} /* repeat the routine for the larger one */
+}
+
+// 对应C函数：`static int sort (lua_State *L)'
+func sort(L *LuaState) int {
+	var n = auxGetN(L, 1)
+	L.LCheckStack(40, "")  /* assume array is smaller than 2^40 */
+	if !L.IsNoneOrNil(2) { /* is there a 2nd argument? */
+		L.LCheckType(2, golua.LUA_TFUNCTION)
+	}
+	L.SetTop(2) /* make sure there is two arguments */
+	auxSort(L, 1, n)
+	return 0
+}
+
+/* }====================================================== */
+
+var tabFuncs = []golua.LReg{
+	{Name: "concat", Func: tconcat},
+	{Name: "foreach", Func: foreach},
+	{Name: "foreachi", Func: foreachi},
+	{Name: "getn", Func: getn},
+	{Name: "maxn", Func: maxn},
+	{Name: "insert", Func: tinsert},
+	{Name: "remove", Func: tremove},
+	{Name: "setn", Func: setn},
+	{Name: "sort", Func: sort},
+}
+
+// LuaOpenTable
+// 对应C函数：`LUALIB_API int luaopen_table (lua_State *L)'
+func LuaOpenTable(L *LuaState) int {
+	L.LRegister(LUA_TABLIBNAME, tabFuncs)
+	return 1
+}
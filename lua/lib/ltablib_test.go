@@ -0,0 +1,153 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+
+	golua "luar/lua"
+)
+
+// doString 在打开了标准库的新状态机中执行chunk
+func doString(t *testing.T, chunk string) error {
+	t.Helper()
+	var L = golua.LuaOpen()
+	defer L.Close()
+	OpenLibs(L)
+	return L.LDoStringErr(chunk)
+}
+
+// tables 生成元素相同但分别位于数组部分和哈希部分的表，供各个用例共享
+const tables = `
+local function variants(...)
+	local n = select('#', ...)
+	local array = {...}                 -- constructor: array part
+	local hash = {}                     -- filled backwards: starts in the hash part
+	for i = n, 1, -1 do hash[i] = (select(i, ...)) end
+	local src = "return {"              -- explicit keys in a constructor: hash part
+	for i = 1, n do
+		local v = select(i, ...)
+		if type(v) == "string" then v = string.format("%q", v) end
+		src = src .. "[" .. i .. "]=" .. v .. ","
+	end
+	local keyed = loadstring(src .. "}")()
+	local mixed = {}                    -- array part followed by hash entries
+	for i = 1, (n < 2 and n or 2) do mixed[i] = (select(i, ...)) end
+	for i = n, 3, -1 do mixed[i] = (select(i, ...)) end
+	return {array = array, hash = hash, keyed = keyed, mixed = mixed}
+end
+function each(...)
+	return pairs(variants(...))
+end
+`
+
+func TestTableInsertRemove(t *testing.T) {
+	err := doString(t, tables+`
+	for kind, t in each("a", "b", "c") do
+		assert(#t == 3 and table.getn(t) == 3, kind)
+		table.insert(t, "d")
+		assert(#t == 4 and t[4] == "d", kind)
+		table.insert(t, 1, "z")
+		assert(table.concat(t, ",") == "z,a,b,c,d", kind)
+		table.insert(t, 8, "x")                       -- grows past the border
+		assert(t[8] == "x" and t[7] == nil and t[6] == nil, kind)
+		t[8] = nil
+		assert(table.remove(t, 1) == "z", kind)
+		assert(table.remove(t) == "d", kind)
+		assert(table.concat(t, ",") == "a,b,c", kind)
+		assert(table.remove(t, 7) == nil and #t == 3, kind)
+	end
+	local t = {}
+	assert(table.remove(t) == nil and #t == 0)
+	assert(not pcall(table.insert, {}, 1, 2, 3))
+	assert(not pcall(table.setn, {}, 1))
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTableConcat(t *testing.T) {
+	err := doString(t, tables+`
+	for kind, t in each("a", "b", "c", "d") do
+		assert(table.concat(t) == "abcd", kind)
+		assert(table.concat(t, ", ") == "a, b, c, d", kind)
+		assert(table.concat(t, "-", 2) == "b-c-d", kind)
+		assert(table.concat(t, "-", 2, 3) == "b-c", kind)
+		assert(table.concat(t, "-", 3, 2) == "", kind)
+	end
+	assert(table.concat({1, 2.5, "x"}, " ") == "1 2.5 x")
+	local ok, msg = pcall(table.concat, {1, {}, 3})
+	assert(not ok and string.find(msg, "invalid value (at index 2) in table for 'concat'", 1, true), msg)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTableSort(t *testing.T) {
+	err := doString(t, tables+`
+	local function sorted(t, n, lt)
+		for i = 2, n do
+			if lt(t[i], t[i-1]) then return false end
+		end
+		return true
+	end
+	local less = function(a, b) return a < b end
+	local greater = function(a, b) return a > b end
+	for kind, t in each(5, 3, 9, 1, 7, 2, 8, 6, 4, 0) do
+		table.sort(t)
+		assert(sorted(t, 10, less), kind)
+		table.sort(t, greater)
+		assert(sorted(t, 10, greater), kind)
+	end
+	for kind, t in each("pear", "apple", "fig") do
+		table.sort(t)
+		assert(table.concat(t, " ") == "apple fig pear", kind)
+	end
+	local big = {}
+	for i = 1, 1000 do big[i] = (i * 7919) % 1009 end
+	table.sort(big)
+	assert(sorted(big, 1000, less))
+	table.sort({})
+	table.sort({1})
+
+	local t = {}
+	for i = 1, 100 do t[i] = i end
+	local ok, msg = pcall(table.sort, t, function(a, b) return true end)
+	assert(not ok and string.find(msg, "invalid order function for sorting", 1, true), msg)
+	ok, msg = pcall(table.sort, {1, "x", 2})
+	assert(not ok and string.find(msg, "attempt to compare"), msg)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTableMaxnForeach(t *testing.T) {
+	err := doString(t, tables+`
+	for kind, t in each(10, 20, 30) do
+		assert(table.maxn(t) == 3, kind)
+		t[100.5] = true
+		t.x = 1
+		assert(table.maxn(t) == 100.5, kind)
+		local sum = 0
+		assert(table.foreachi(t, function(i, v) sum = sum + v end) == nil, kind)
+		assert(sum == 60, kind)
+		assert(table.foreachi(t, function(i, v) if v == 20 then return i end end) == 2, kind)
+		local n = 0
+		table.foreach(t, function() n = n + 1 end)
+		assert(n == 5, kind)
+	end
+	assert(table.maxn({}) == 0)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTableArgErrors(t *testing.T) {
+	err := doString(t, `table.insert(nil, 1)`)
+	if err == nil || !strings.Contains(err.Error(), "table expected, got nil") {
+		t.Errorf("table.insert(nil, 1) error = %v", err)
+	}
+}
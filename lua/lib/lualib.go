@@ -1,5 +1,15 @@
 package lib
 
+/* Key to file-handle type */
+const LUA_FILEHANDLE = "FILE*"
+
 const (
-	LUA_COLIBNAME = "coroutine"
+	LUA_COLIBNAME   = "coroutine"
+	LUA_TABLIBNAME  = "table"
+	LUA_IOLIBNAME   = "io"
+	LUA_OSLIBNAME   = "os"
+	LUA_STRLIBNAME  = "string"
+	LUA_MATHLIBNAME = "math"
+	LUA_DBLIBNAME   = "debug"
+	LUA_LOADLIBNAME = "package"
 )
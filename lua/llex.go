@@ -113,8 +113,8 @@ func xSetInput(L *LuaState, ls *LexState, z *ZIO, source *TString) {
 	ls.lineNumber = 1
 	ls.lastLine = 1
 	ls.source = source
-	ls.buff.Resize(LUA_MINBUFFER) /* initialize buffer */
-	ls.next()                     /* read first char */
+	ls.buff.Resize(ls.L, LUA_MINBUFFER) /* initialize buffer */
+	ls.next()                           /* read first char */
 }
 
 // 对应C函数：`next(ls)'
@@ -463,12 +463,12 @@ func (ls *LexState) xSyntaxError(msg string) {
 // 对应C函数：`void luaX_lexerror (LexState *ls, const char *msg, int token)'
 func (ls *LexState) xLexError(msg string, token tk) {
 	const MAXSRC = 80
-	var buff = make([]byte, 80)
-	oChunkId(buff, string(ls.source.Bytes), MAXSRC)
+	var buff = oChunkId(string(ls.source.Bytes), MAXSRC)
 	msg2 := ls.L.oPushFString("%s:%d: %s", buff, ls.lineNumber, msg)
 	if token != 0 {
 		ls.L.oPushFString("%s near "+LUA_QS, msg2, ls.txtToken(token))
 	}
+	ls.L.errInfo = errorInfo{chunkName: buff, line: ls.lineNumber, fresh: true}
 	ls.L.dThrow(LUA_ERRSYNTAX)
 }
 
@@ -506,7 +506,7 @@ func (ls *LexState) save(c tk) {
 			ls.xLexError("lexical element too long", 0)
 		}
 		var newSize = b.size * 2
-		b.Resize(newSize)
+		b.Resize(ls.L, newSize)
 	}
 	b.buffer[b.n] = byte(c)
 	b.n++
@@ -14,6 +14,7 @@ type lu_byte = uint8
 const (
 	MAX_SIZET = (^uint32(0)) - 2
 	MAX_INT   = math.MaxInt32 - 2
+	MAX_LUMEM = math.MaxInt
 )
 
 const (
@@ -0,0 +1,36 @@
+package golua
+
+// MRealloc 登记一块内存从osize字节变为nsize字节。
+// 内存由Go分配和回收，这里只记录使用量并通知宿主的LuaAlloc；
+// 调用者在真正分配之前调用它，超过SetMemLimit设置的上限时抛出LUA_ERRMEM，不会返回。
+// 对应C函数：`void *luaM_realloc_ (lua_State *L, void *block, size_t osize, size_t nsize)'
+func (L *LuaState) MRealloc(osize int, nsize int) {
+	var g = L.G()
+	if nsize > osize && g.memLimit > 0 && g.totalBytes-osize+nsize > g.memLimit {
+		L.dThrow(LUA_ERRMEM)
+	}
+	if g.freeAlloc != nil {
+		g.freeAlloc(g.ud, nil, osize, nsize)
+	}
+	g.totalBytes += nsize - osize
+	if DEBUG {
+		LuaAssert(g.totalBytes >= 0)
+	}
+}
+
+// 登记缓冲区（MBuffer、LBuffer）内存的变化。缓冲区不是回收对象，
+// atomic用estimate重置totalBytes时要把bufBytes加回去
+func (L *LuaState) mBufRealloc(osize int, nsize int) {
+	L.MRealloc(osize, nsize)
+	L.G().bufBytes += nsize - osize
+}
+
+// 释放出错时随Go栈帧一起丢弃的LBuffer所登记的内存，
+// 它们是在lBufBytes为old之后登记的
+func (L *LuaState) mFreeLBuffers(old lu_mem) {
+	var g = L.G()
+	if lost := g.lBufBytes - old; lost > 0 {
+		L.mBufRealloc(lost, 0)
+		g.lBufBytes = old
+	}
+}
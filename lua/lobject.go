@@ -2,6 +2,7 @@ package golua
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -106,60 +107,74 @@ func pushStr(L *LuaState, str []byte) {
 func (L *LuaState) oPushVfString(format []byte, argv []interface{}) []byte {
 	var argi = 0
 	var n = 1
+	var nextArg = func() interface{} { /* 相当于C中的`va_arg' */
+		if argi >= len(argv) {
+			return nil
+		}
+		argi++
+		return argv[argi-1]
+	}
 	pushStr(L, []byte(""))
 	for {
 		e := bytes.IndexByte(format, '%')
-		if e == -1 {
+		if e == -1 || e+1 >= len(format) {
 			break
 		}
 		L.Top().SetString(L, L.sNewStr(format[:e]))
 		L.IncTop()
-		arg := argv[argi]
-		argi++
 		switch format[e+1] {
 		case 's':
 			var s []byte
-			switch arg.(type) {
+			switch arg := nextArg().(type) {
 			case []byte:
-				s = arg.([]byte)
+				s = arg
 			case string:
-				s = []byte(arg.(string))
+				s = []byte(arg)
 			default:
 				s = []byte("(null)")
 			}
 			pushStr(L, s)
 		case 'c':
-			var buff [2]byte
-			switch arg.(type) {
+			var buff [1]byte
+			switch arg := nextArg().(type) {
 			case int32:
-				buff[0] = byte(arg.(int32))
+				buff[0] = byte(arg)
 			case byte:
-				buff[0] = arg.(byte)
+				buff[0] = arg
+			case int:
+				buff[0] = byte(arg)
 			default:
 				buff[0] = '?'
 			}
-			buff[1] = 0
 			pushStr(L, buff[:])
 		case 'd':
-			v := arg.(int)
-			L.Top().SetNumber(LuaNumber(v))
+			var v LuaNumber
+			switch arg := nextArg().(type) {
+			case int:
+				v = LuaNumber(arg)
+			case int32:
+				v = LuaNumber(arg)
+			case int64:
+				v = LuaNumber(arg)
+			case tk:
+				v = LuaNumber(arg)
+			}
+			L.Top().SetNumber(v)
 			L.IncTop()
 		case 'f':
-			v := arg.(LuaNumber)
+			v, _ := nextArg().(LuaNumber)
 			L.Top().SetNumber(v)
 			L.IncTop()
 		case 'p':
-			buff := fmt.Sprintf("%p", arg)
+			buff := fmt.Sprintf("%p", nextArg())
 			pushStr(L, []byte(buff))
 		case '%':
 			pushStr(L, []byte("%"))
-			argi--
 		default:
-			var buff = [3]byte{
-				'%', format[e+1], 0,
+			var buff = [2]byte{
+				'%', format[e+1],
 			}
 			pushStr(L, buff[:])
-			argi--
 		}
 		n += 2
 		format = format[e+2:]
@@ -176,19 +191,32 @@ func (L *LuaState) oPushFString(format string, args ...interface{}) []byte {
 }
 
 // 对应C函数：`int luaO_str2d (const char *s, lua_Number *result)'
+// 与strtod一样接受前后的空白、十六进制整数（"0x1A"）以及inf和nan
 func oStr2d(s string, result *LuaNumber) (ok bool) {
-	s = strings.TrimRight(s, " \t\n\r")
-	if s[len(s)-1] == 'x' || s[len(s)-1] == 'X' {
-		i, err := strconv.ParseInt(s[:len(s)-1], 16, 64)
-		if err != nil {
+	s = strings.Trim(s, " \f\n\r\t\v")
+	if s == "" {
+		return false /* conversion failed */
+	}
+	var digits = s
+	if digits[0] == '-' || digits[0] == '+' {
+		digits = digits[1:]
+	}
+	if len(digits) > 2 && digits[0] == '0' && (digits[1] == 'x' || digits[1] == 'X') { /* hexadecimal constant? */
+		n, err := strconv.ParseUint(digits[2:], 16, 64)
+		if err != nil && !errors.Is(err, strconv.ErrRange) {
 			return false
 		}
-		*result = LuaNumber(i)
+		*result = LuaNumber(n)
+		if s[0] == '-' {
+			*result = -*result
+		}
 		return true
 	}
-
+	if strings.ContainsAny(s, "_xXpP") { /* not accepted by strtod */
+		return false
+	}
 	v, err := strconv.ParseFloat(s, 64)
-	if err != nil {
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
 		return false
 	}
 	*result = v
@@ -196,39 +224,34 @@ func oStr2d(s string, result *LuaNumber) (ok bool) {
 }
 
 // 对应C函数：`void luaO_chunkid (char *out, const char *source, size_t bufflen)'
-func oChunkId(out []byte, source string, bufflen int) {
-	if source[0] == '=' {
-		copy(out, source[1:]) /* remove first char*/
-		// out[len(out)-1] = 0   /* ensures null termination */
+func oChunkId(source string, bufflen int) string {
+	if len(source) > 0 && source[0] == '=' {
+		source = source[1:] /* remove first char */
+		if len(source) > bufflen-1 {
+			source = source[:bufflen-1]
+		}
+		return source
 	} else { /* out = "source", or "...source" */
-		if source[0] == '@' {
+		if len(source) > 0 && source[0] == '@' {
 			source = source[1:] /* skip the `@' */
-			bufflen -= len(" '...' ")
-			var l = len(source)
-			if l > bufflen {
-				source = source[l-bufflen:] /* get last part of file name */
-				copy(out, "...")
-				out = out[3:]
+			bufflen -= len(" '...' ") + 1
+			if l := len(source); l > bufflen {
+				return "..." + source[l-bufflen:] /* get last part of file name */
 			}
-			copy(out, source)
+			return source
 		} else { /* out = [string "string"] */
 			var l = strings.IndexAny(source, "\n\r") /* stop at first newline */
 			if l < 0 {
 				l = len(source)
 			}
-			bufflen -= len(" [string \"...\"] ")
-			out = out[:0]
-			out = append(out, []byte("[string \"")...)
+			bufflen -= len(" [string \"...\"] ") + 1
 			if l > bufflen {
 				l = bufflen
 			}
 			if l != len(source) { /* must truncate? */
-				out = append(out, source[:l]...)
-				out = append(out, "..."...)
-			} else {
-				out = append(out, source...)
+				return "[string \"" + source[:l] + "...\"]"
 			}
-			out = append(out, "\"]"...)
+			return "[string \"" + source + "\"]"
 		}
 	}
 }
@@ -16,7 +16,7 @@ func TestCommonHeader(t *testing.T) {
 		tt: LUA_TNUMBER,
 	}
 	v2 := &TValue{}
-	SetObj(nil, v2, v1)
+	SetObj(LuaOpen(), v2, v1)
 	t.Log(*v2)
 }
 
@@ -67,7 +67,7 @@ func Test_oPushVfString1(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.args.L.oPushVfString(tt.args.format, tt.args.argv...); !reflect.DeepEqual(got, tt.want) {
+			if got := tt.args.L.oPushVfString(tt.args.format, tt.args.argv); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("oPushVfString() = %v, want %v", got, tt.want)
 			}
 		})
@@ -75,6 +75,27 @@ func Test_oPushVfString1(t *testing.T) {
 }
 
 func Test_oStr2d(t *testing.T) {
-	var num LuaNumber
-	t.Log(oStr2d("123  \r   \n", &num))
+	tests := []struct {
+		s    string
+		want LuaNumber
+		ok   bool
+	}{
+		{"123  \r   \n", 123, true},
+		{"  -1.5e2", -150, true},
+		{"0x1A", 26, true},
+		{" -0X10 ", -16, true},
+		{".5", 0.5, true},
+		{"", 0, false},
+		{"   ", 0, false},
+		{"0x", 0, false},
+		{"1x", 0, false},
+		{"12a", 0, false},
+		{"1_000", 0, false},
+	}
+	for _, tt := range tests {
+		var num LuaNumber
+		if ok := oStr2d(tt.s, &num); ok != tt.ok || (ok && num != tt.want) {
+			t.Errorf("oStr2d(%q) = %v, %v; want %v, %v", tt.s, num, ok, tt.want, tt.ok)
+		}
+	}
 }
@@ -122,7 +122,10 @@ func (ls *LexState) closeFunc() {
 	var f = fs.f
 	ls.removeVars(0)
 	fs.kRet(0, 0) /* final return */
-	f.code.ReAlloc(fs.pc, L)
+	/* 执行完最后一条指令后savedPc指向code末尾之后，多留一个元素使这个指针
+	   仍然落在code的内存块中，否则它可能指向一个已经被Go回收的对象 */
+	f.code.ReAlloc(fs.pc+1, L)
+	f.code = f.code[:fs.pc]
 	f.lineInfo.ReAlloc(fs.pc, L)
 	f.k.ReAlloc(fs.nk, L)
 	f.p.ReAlloc(fs.np, L)
@@ -717,6 +720,7 @@ func (ls *LexState) parList() {
 				if LUA_COMPAT_VARARG {
 					/* use `arg' as default name */
 					ls.newLocalVarLiteral("arg", nParams)
+					nParams++
 					f.isVarArg = VARARG_HASARG | VARARG_NEEDSARG
 				}
 				f.isVarArg |= VARARG_ISVARARG
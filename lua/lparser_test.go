@@ -0,0 +1,27 @@
+package golua
+
+import "testing"
+
+/* parList must count the implicit `arg' parameter of vararg functions (LUA_COMPAT_VARARG) */
+func TestLexState_parListVararg(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	if L.LDoString(`
+		local f = function(a, ...) return ... end
+		local g = function(...) return arg.n, arg[1] end
+		local n, x = g("x")
+		return n, x, f(1, 2, 3)`) != 0 {
+		t.Fatal(L.ToString(-1))
+	}
+	if L.GetTop() != 4 || L.ToInteger(1) != 1 || L.ToString(2) != "x" ||
+		L.ToInteger(3) != 2 || L.ToInteger(4) != 3 {
+		t.Errorf("unexpected results %v %v %v %v", L.ToString(1), L.ToString(2), L.ToString(3), L.ToString(4))
+	}
+	if L.LLoadString("return function(a, b, ...) end") != 0 {
+		t.Fatal(L.ToString(-1))
+	}
+	var p = L.AtTop(-1).LFuncValue().p.p[0]
+	if p.numParams != 2 || p.isVarArg&VARARG_ISVARARG == 0 || string(p.locVars[2].varName.GetStr()) != "arg" {
+		t.Errorf("numParams %d, isVarArg %d", p.numParams, p.isVarArg)
+	}
+}
@@ -1,7 +1,9 @@
 package golua
 
 import (
+	"io/fs"
 	"luar/lua/mem"
+	"math/rand"
 	"unsafe"
 )
 
@@ -17,6 +19,7 @@ const (
 type GCObject interface {
 	gcType() ttype
 	setType(t ttype)
+	gch() *GCHeader
 	GetNext() GCObject
 	SetNext(obj GCObject)
 	SetMarked(m lu_byte)
@@ -37,25 +40,31 @@ type GlobalState struct {
 	currentWhite lu_byte          /* */
 	gcState      lu_byte          /* state of garbage collector */
 	sweepStrGC   int              /* position of sweep in `strt' */
-	rootGC       GCObject         /* list of all collectable objects */
+	rootGC       GCObject         /* list of threads, followed by the main thread and userdata */
 	sweepGc      *GCObject        /* position of sweep in `rootgc' */
-	gray         []GCObject       /* list of gray objects */
-	grayAgain    []GCObject       /* list of objects to be traversed atomically */
+	gray         []GCObject       /* list of marked objects still to be traversed */
 	weak         []GCObject       /* list of weak tables (to be cleared) */
 	tmUData      GCObject         /* last element of list of userdata to be GC */
 	buff         MBuffer          /* temporary buffer for string concatentation */
 	GCThreshold  lu_mem           /* */
 	totalBytes   lu_mem           /* number of bytes currently allocated */
 	estimate     lu_mem           /* an estimate of number of bytes actually in use */
+	memLimit     lu_mem           /* 内存上限，0表示不限制 */
+	bufBytes     lu_mem           /* 缓冲区占用的内存，它们不是回收对象，不在estimate中 */
+	lBufBytes    lu_mem           /* bufBytes中属于LBuffer的部分 */
 	gcDept       lu_mem           /* how much GC is `behind schedule' */
 	gcPause      int              /* size of pause between successive GCs */
 	gcStepMul    int              /* GC `granularity' */
 	panic        LuaCFunction     /* to be called in unprotected errors */
 	lRegistry    TValue           /* */
 	mainThread   *LuaState        /* */
-	uvHead       UpVal            /* head of double-linked list of all open upvalues */
 	mt           [NUM_TAGS]*Table /* metatables for basic types */
 	tmName       [TM_N]*TString   /* array with tag-method names */
+
+	rethrowGoPanics bool        /* don't turn Go panics in LuaCFunctions into Lua errors */
+	randSource      rand.Source /* generator behind math.random; nil until first used */
+	osPolicy        OSPolicy    /* system capabilities granted to the os library */
+	packageFS       fs.FS       /* file system searched by require; nil means the OS one */
 }
 
 // 对应C函数：`luaC_white(g)'
@@ -90,6 +99,9 @@ type LuaState struct {
 	gcList        GCObject     /* */
 	errorJmp      *LuaLongJmp  /* current error recover point */
 	errFunc       int          /* current error handling function (stack index) */
+	errInfo       errorInfo    /* where the last error was raised (see LuaError) */
+	errTrace      bool         /* inside PCallErr and friends? */
+	errTraceJmp   *LuaLongJmp  /* recover point outside of that call */
 }
 
 // LG
@@ -112,15 +124,13 @@ func NewState(f LuaAlloc, ud interface{}) *LuaState {
 	g := &l.g
 	L.next = nil
 	L.tt = LUA_TTHREAD
-	g.currentWhite = 1<<WHITEBITS | 1<<FIXEDBIT
+	g.currentWhite = 1<<WHITE0BIT | 1<<FIXEDBIT
 	L.marked = g.cWhite()
 	L.marked |= 1<<FIXEDBIT | 1<<SFIXEDBIT
 	preinit_state(L, g)
 	g.freeAlloc = f
 	g.ud = ud
 	g.mainThread = L
-	g.uvHead.lPrev = &g.uvHead
-	g.uvHead.lNext = &g.uvHead
 	g.GCThreshold = 0 /* mark it as unfinished state */
 	g.StrT.Size = 0
 	g.StrT.NrUse = 0
@@ -133,13 +143,11 @@ func NewState(f LuaAlloc, ud interface{}) *LuaState {
 	g.sweepStrGC = 0
 	g.sweepGc = &g.rootGC
 	g.gray = nil
-	g.grayAgain = nil
 	g.weak = nil
 	g.tmUData = nil
 	g.totalBytes = int(unsafe.Sizeof(LG{}))
 	g.gcPause = LUAI_GCPAUSE
 	g.gcStepMul = LUAI_GCMUL
-	g.gcStepMul = 0
 	for i := 0; i < int(NUM_TAGS); i++ {
 		g.mt[i] = nil
 	}
@@ -162,7 +170,7 @@ func (L *LuaState) closeState() {
 	// todo: LuaAssert(g.rootGC == L) /* collect all objects */
 	// todo: LuaAssert(g.StrT.NUse == 0)
 	L.G().StrT.Hash = nil
-	g.buff.Free()
+	g.buff.Free(L)
 	freestack(L, L)
 	// todo: LuaAssert(g.totalBytes == int(unsafe.Sizeof(LG{})))
 	g.ud = nil
@@ -171,11 +179,13 @@ func (L *LuaState) closeState() {
 // 对应C函数：`static void stack_init (lua_State *L1, lua_State *L)'
 func stack_init(L1 *LuaState, L *LuaState) {
 	/* initialize CallInfo array*/
+	L.MRealloc(0, BASIC_CI_SIZE*int(unsafe.Sizeof(CallInfo{})))
 	L1.baseCi = make([]CallInfo, BASIC_CI_SIZE)
 	L1.ci = 0
 	L1.sizeCi = BASIC_CI_SIZE
 	L1.endCi = L1.sizeCi - 1
 	/* initialize stack array */
+	L.MRealloc(0, (BASIC_STACK_SIZE+EXTRA_STACK)*int(unsafe.Sizeof(TValue{})))
 	L1.stack = make([]TValue, BASIC_STACK_SIZE+EXTRA_STACK)
 	L1.stackSize = BASIC_STACK_SIZE + EXTRA_STACK
 	L1.top = 0
@@ -191,6 +201,8 @@ func stack_init(L1 *LuaState, L *LuaState) {
 
 // 对应C函数：static void freestack (lua_State *L, lua_State *L1)
 func freestack(L *LuaState, L1 *LuaState) {
+	L.MRealloc(len(L1.baseCi)*int(unsafe.Sizeof(CallInfo{})), 0)
+	L.MRealloc(len(L1.stack)*int(unsafe.Sizeof(TValue{})), 0)
 	L1.baseCi = nil
 	L1.stack = nil
 }
@@ -233,10 +245,79 @@ func preinit_state(L *LuaState, g *GlobalState) {
 	L.GlobalTable().SetNil()
 }
 
+// 对应C函数：`lua_State *luaE_newthread (lua_State *L)'
+func (L *LuaState) eNewThread() *LuaState {
+	var L1 = &LuaState{}
+	L.cLink(L1, LUA_TTHREAD)
+	preinit_state(L1, L.G())
+	stack_init(L1, L)                           /* init stack */
+	L1.GlobalTable().SetObj(L, L.GlobalTable()) /* share table of globals */
+	L1.hookMask = L.hookMask
+	L1.baseHootCount = L.baseHootCount
+	L1.hook = L.hook
+	ResetHookCount(L1)
+	LuaAssert(L1.IsWhite())
+	return L1
+}
+
+// 对应C函数：`void luaE_freethread (lua_State *L, lua_State *L1)'
+func (L *LuaState) eFreeThread(L1 *LuaState) {
+	L1.fClose(&L1.stack[0]) /* close all upvalues for this thread */
+	LuaAssert(L1.openUpval == nil)
+	LUAIUserStateFree(L1)
+	/* 死线程的栈算在objSize里，atomic重置totalBytes时已经扣除，不再登记 */
+	L1.baseCi = nil
+	L1.stack = nil
+}
+
 func (L *LuaState) G() *GlobalState {
 	return L.lG
 }
 
+// SetRandSource
+// 替换math.random和math.randomseed使用的随机数发生器，宿主可以借此控制随机序列，
+// 使回放在不同的运行和机器之间完全一致。该设置由同一个全局状态下的所有线程共享。
+func (L *LuaState) SetRandSource(src rand.Source) {
+	L.G().randSource = src
+}
+
+// RandSource
+// 返回当前的随机数发生器。未设置时使用以1为种子的rand.NewSource，
+// 与C中没有调用srand时rand()的行为一致，因此默认序列也是确定的。
+func (L *LuaState) RandSource() rand.Source {
+	var g = L.G()
+	if g.randSource == nil {
+		g.randSource = rand.NewSource(1)
+	}
+	return g.randSource
+}
+
+// SetOSPolicy
+// 设置os库使用的时钟、环境变量以及文件系统和进程的访问策略，为nil的字段使用真实的系统实现。
+// 该设置由同一个全局状态下的所有线程共享。
+func (L *LuaState) SetOSPolicy(p OSPolicy) {
+	L.G().osPolicy = p
+}
+
+// OSPolicy
+// 返回os库使用的策略，其中未设置的字段已经用真实的系统实现补全。
+func (L *LuaState) OSPolicy() OSPolicy {
+	return L.G().osPolicy.withDefaults()
+}
+
+// SetPackageFS
+// 设置require按package.path和package.cpath查找模块时使用的文件系统（例如embed.FS）。
+// 路径模板展开后的文件名会去掉开头的"./"并按fs.FS的规则清理，绝对路径等无效的名字被跳过。
+// fsys为nil时使用操作系统的文件系统。该设置由同一个全局状态下的所有线程共享。
+func (L *LuaState) SetPackageFS(fsys fs.FS) {
+	L.G().packageFS = fsys
+}
+
+// PackageFS 返回require使用的文件系统，nil表示操作系统的文件系统
+func (L *LuaState) PackageFS() fs.FS {
+	return L.G().packageFS
+}
+
 // Lock 什么也不做
 // 对应C：lua_lock(L)
 func (L *LuaState) Lock() {
@@ -340,6 +421,7 @@ func (L *LuaState) dReAllocStack(newSize int) {
 	oldStack := L.stack
 	realSize := newSize + 1 + EXTRA_STACK
 	LuaAssert(L.stackLast == L.stackSize-EXTRA_STACK-1)
+	L.MRealloc(len(oldStack)*int(unsafe.Sizeof(TValue{})), realSize*int(unsafe.Sizeof(TValue{})))
 	newStack := make([]TValue, realSize)
 	copy(newStack, oldStack)
 	L.stack = newStack
@@ -365,7 +447,7 @@ func correctstack(L *LuaState, oldStack []TValue) {
 		uv.v = correct(uv.v)
 	}
 	for i := 0; i <= L.ci; i++ {
-		ci := L.baseCi[i]
+		ci := &L.baseCi[i]
 		ci.fn = correct(ci.fn)
 		// ci.base和ci.top不需要处理
 	}
@@ -435,9 +517,9 @@ func (ci *CallInfo) IsLua() bool {
 func (L *LuaState) Close() {
 	L = L.G().mainThread /* only the main thread can be closed */
 	L.Lock()
-	L.fClose(&L.stack[0]) /* close all upvalues for this thread */
-	// todo:  L.cSepareateUdata(1)  /* separate udata that have GC metamethods */
-	L.errFunc = 0 /* no error function during GC metamethods */
+	L.fClose(&L.stack[0])  /* close all upvalues for this thread */
+	L.cSeparateUData(true) /* separate udata that have GC metamethods */
+	L.errFunc = 0          /* no error function during GC metamethods */
 
 	for { /* repeat until no more errors */
 		L.ci = 0
@@ -456,5 +538,5 @@ func (L *LuaState) Close() {
 
 // 对应C函数：`static void callallgcTM (lua_State *L, void *ud)'
 func callAllGcTM(L *LuaState, ud interface{}) {
-	// todo: callAllGcTM
+	L.cCallGCTM() /* call GC metamethods for all udata */
 }
@@ -15,6 +15,7 @@ func (L *LuaState) sNewUData(sz int, e *Table) *Udata {
 	if sz > int(MAX_SIZET)-int(unsafe.Sizeof(Udata{})) {
 		mem.ErrTooBig(L)
 	}
+	L.MRealloc(0, int(unsafe.Sizeof(Udata{}))+sz)
 	var u = &Udata{}
 	u.marked = L.G().cWhite() /* is not finalized */
 	u.tt = LUA_TUSERDATA
@@ -358,8 +358,9 @@ func (t *Table) resize(L *LuaState, nasize int, nhsize int) {
 		}
 	}
 
-	/* gc回收oldNodes的内存，不用作其他处理 */
-	// oldNodes.Free(L)
+	if &oldNodes[0] != DummyNode {
+		oldNodes.Free(L) /* 只是登记释放，内存由Go回收 */
+	}
 }
 
 // ResizeArray 重新分配数组部分的大小
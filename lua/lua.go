@@ -28,6 +28,18 @@ const (
 	LUA_ERRERR    = 5
 )
 
+/* garbage-collection options */
+const (
+	LUA_GCSTOP       = 0
+	LUA_GCRESTART    = 1
+	LUA_GCCOLLECT    = 2
+	LUA_GCCOUNT      = 3
+	LUA_GCCOUNTB     = 4
+	LUA_GCSTEP       = 5
+	LUA_GCSETPAUSE   = 6
+	LUA_GCSETSTEPMUL = 7
+)
+
 // LuaUpValueIndex
 // 对应C函数：`lua_upvalueindex(i)'
 func LuaUpValueIndex(i int) int {
@@ -46,10 +58,12 @@ type LuaReadFunc func(L *LuaState, ud interface{}) (buf []byte, size int)
 
 // LuaWriteFunc
 // 对应C：`typedef int (*lua_Writer) (lua_State *L, const void* p, size_t sz, void* ud)'
-type LuaWriteFunc func(L *LuaState, p []byte, sz int, ud interface{})
+// 返回非0值表示写入出错，dump随即停止并返回这个值
+type LuaWriteFunc func(L *LuaState, p []byte, sz int, ud interface{}) int
 
 // LuaAlloc
 // prototype for memory-allocation functions
+// 内存由Go分配和回收，这个函数只在每次分配（osize < nsize）和释放时收到通知，ptr总是nil
 // 对应C：`typedef void * (*lua_Alloc) (void *ud, void *ptr, size_t osize, size_t nsize)'
 type LuaAlloc func(ud interface{}, ptr interface{}, osize int, nsize int)
 
@@ -97,15 +111,16 @@ type LuaHook func(L *LuaState, ar *LuaDebug)
 // LuaDebug
 // 对应C结构体：`struct lua_Debug'
 type LuaDebug struct {
-	Event       int
-	Name        string /* (n) */
-	NameWhat    string /* (n) `global', `local', `field', `method' */
-	What        string /* (S) `Lua', `C', `main', `tail' */
-	Source      string /* (S) */
-	CurrentLine int    /* (l) */
-	NUps        int    /* (u) number of upvalues */
-	LineDefined int    /* (S) */
-	ShortSrc    []byte /* (S) */
+	Event           int
+	Name            string /* (n) */
+	NameWhat        string /* (n) `global', `local', `field', `method' */
+	What            string /* (S) `Lua', `C', `main', `tail' */
+	Source          string /* (S) */
+	CurrentLine     int    /* (l) */
+	NUps            int    /* (u) number of upvalues */
+	LineDefined     int    /* (S) */
+	LastLineDefined int    /* (S) */
+	ShortSrc        []byte /* (S) */
 	/* private part */
 	iCI int /* active function */
 }
@@ -129,6 +144,18 @@ func (L *LuaState) PushCFunction(f LuaCFunction) {
 	L.PushCClosure(f, 0)
 }
 
+// GetGlobal
+// 对应C函数：`lua_getglobal(L,s)'
+func (L *LuaState) GetGlobal(k string) {
+	L.GetField(LUA_GLOBALSINDEX, k)
+}
+
+// GetGCCount
+// 对应C函数：`lua_getgccount(L)'
+func (L *LuaState) GetGCCount() int {
+	return L.GC(LUA_GCCOUNT, 0)
+}
+
 // SetGlobal
 // 对应C函数：`lua_setglobal(L,s)'
 func (L *LuaState) SetGlobal(k string) {
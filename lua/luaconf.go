@@ -22,6 +22,11 @@ const LUA_NUMBER_FMT = "%.14g"
 
 const LUA_QS = "'%s'"
 
+// LUA_IDSIZE gives the maximum size for the description of the source
+// of a function in debug information.
+// CHANGE it if you want a different size.
+const LUA_IDSIZE = 60
+
 // // NumberToStr
 // // 对应C函数：`lua_number2str(s,n)'
 // func NumberToStr(n LuaNumber) string {
@@ -71,10 +76,23 @@ func lua_number2integer(d LuaNumber) LuaInteger {
 	return LuaInteger(d)
 }
 
+// LUAI_MAXCALLS limits the number of nested calls.
+// CHANGE it if you need really deep recursive calls. This limit is
+// arbitrary; its only purpose is to stop infinite recursion before
+// exhausting memory.
+const LUAI_MAXCALLS = 20000
+
 // LUAI_MAXCCALLS is the maximum depth for nested C calls (short) and
 // syntactical nested non-terminals in a program.
 const LUAI_MAXCCALLS = 200
 
+// LUAI_MAXCSTACK limits the number of Lua stack slots that a C function
+// can use.
+// CHANGE it if you need lots of (Lua) stack space for your C
+// functions. This limit is arbitrary; its only purpose is to stop C
+// functions to consume unlimited stack space.
+const LUAI_MAXCSTACK = 8000
+
 // LUAI_MAXVARS is the maximum number of local variables per function
 // (must be smaller than 250).
 const LUAI_MAXVARS = 200
@@ -94,6 +112,61 @@ const LUA_COMPAT_VARARG = true
 // off the advisory error when nesting [[...]].
 const LUA_COMPAT_LSTR = 1
 
+// LUA_COMPAT_MOD controls compatibility with old 'math.mod' function.
+// CHANGE it to false as soon as your programs use 'math.fmod' or the
+// new '%' operator instead of 'math.mod'.
+const LUA_COMPAT_MOD = true
+
+// LUA_COMPAT_GFIND controls compatibility with old 'string.gfind' name.
+// CHANGE it to false as soon as you rename 'string.gfind' to
+// 'string.gmatch'.
+const LUA_COMPAT_GFIND = true
+
+// LUA_COMPAT_LOADLIB controls compatibility about global loadlib.
+// CHANGE it to false as soon as you rename 'loadlib' to
+// 'package.loadlib'.
+const LUA_COMPAT_LOADLIB = true
+
+// LUA_PATH and LUA_CPATH are the names of the environment variables that
+// Lua check to set its paths.
+const (
+	LUA_PATH  = "LUA_PATH"
+	LUA_CPATH = "LUA_CPATH"
+)
+
+// LUA_PATH_DEFAULT is the default path that Lua uses to look for
+// Lua libraries.
+// LUA_CPATH_DEFAULT is the default path that Lua uses to look for
+// C libraries.
+// CHANGE them if your machine has a non-conventional directory
+// hierarchy or if you want to install your libraries in
+// non-conventional directories.
+const (
+	LUA_ROOT          = "/usr/local/"
+	LUA_LDIR          = LUA_ROOT + "share/lua/5.1/"
+	LUA_CDIR          = LUA_ROOT + "lib/lua/5.1/"
+	LUA_PATH_DEFAULT  = "./?.lua;" + LUA_LDIR + "?.lua;" + LUA_LDIR + "?/init.lua;" + LUA_CDIR + "?.lua;" + LUA_CDIR + "?/init.lua"
+	LUA_CPATH_DEFAULT = "./?.so;" + LUA_CDIR + "?.so;" + LUA_CDIR + "loadall.so"
+)
+
+// LUA_DIRSEP is the directory separator (for submodules).
+// Go accepts '/' on every system, so there is no Windows variant.
+const LUA_DIRSEP = "/"
+
+// LUA_PATHSEP is the character that separates templates in a path.
+// LUA_PATH_MARK is the string that marks the substitution points in a
+// template.
+// LUA_EXECDIR in a Windows path is replaced by the executable's
+// directory.
+// LUA_IGMARK is a mark to ignore all before it when bulding the
+// luaopen_ function name.
+const (
+	LUA_PATHSEP   = ";"
+	LUA_PATH_MARK = "?"
+	LUA_EXECDIR   = "!"
+	LUA_IGMARK    = "-"
+)
+
 type (
 	LUAI_UINT32 = uint32
 	LUAI_INT32  = int32
@@ -130,4 +203,6 @@ var (
 
 const SHRT_MAX = math.MaxInt16
 
-const DEBUG = true /* 设置为true将会打印字节码的反汇编信息等其它调试信息 */
+const DEBUG = true /* 设置为true将会进行额外的一致性检查等调试工作 */
+
+const TRACE_VM = false /* 设置为true将会打印执行的每一条字节码的反汇编信息 */
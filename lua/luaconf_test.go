@@ -1,6 +1,7 @@
 package golua
 
 import (
+	"math"
 	"strconv"
 	"testing"
 )
@@ -8,4 +9,18 @@ import (
 func TestNumberToStr(t *testing.T) {
 	t.Log(NumberToStr(12311))
 	t.Log(strconv.FormatFloat(-1.5, 'g', 14, 64))
+	tests := map[LuaNumber]string{
+		12:           "12",
+		1.5:          "1.5",
+		1e100:        "1e+100",
+		1 << 53:      "9.007199254741e+15",
+		math.Inf(1):  "inf",
+		math.Inf(-1): "-inf",
+		math.NaN():   "nan",
+	}
+	for n, want := range tests {
+		if got := NumberToStr(n); got != want {
+			t.Errorf("NumberToStr(%v) = %q, want %q", n, got, want)
+		}
+	}
 }
@@ -2,6 +2,7 @@ package golua
 
 import (
 	"bytes"
+	"math"
 	"reflect"
 	"unsafe"
 )
@@ -13,11 +14,17 @@ const (
 )
 
 // 对应C结构体：`struct LoadState'
+// 后面几个字段记录块头部声明的格式，读入的值按照它们转换成本机的值
 type loadState struct {
-	L    *LuaState
-	Z    *ZIO
-	b    *MBuffer
-	name []byte
+	L          *LuaState
+	Z          *ZIO
+	b          *MBuffer
+	name       []byte
+	bigEndian  bool /* 块中的值是否按大端序存放 */
+	sizeInt    int  /* sizeof(int) */
+	sizeSizeT  int  /* sizeof(size_t) */
+	sizeNumber int  /* sizeof(lua_Number) */
+	integral   bool /* lua_Number是否为整数类型 */
 }
 
 // IF 对应C函数：`IF(c,s)'
@@ -58,16 +65,30 @@ func (S *loadState) LoadVar(x interface{}) {
 	}
 }
 
-// LoadVector
-// 对应C函数：`LoadVector(S,b,n,size)'
-func (S *loadState) LoadVector(b interface{}, n int, size int) {
-	totalBytes := n * size
-	buf := make([]byte, totalBytes)
-	S.LoadMem(buf, n, size)
-	var p = reflect.ValueOf(b).Index(0).Addr().Pointer()
-	for i := 0; i < totalBytes; i++ {
-		*(*byte)(unsafe.Pointer(p + uintptr(i))) = buf[i]
+// loadUint 从块中读入一个size字节的无符号整数，并按块的字节序转换
+func (S *loadState) loadUint(size int) uint64 {
+	var buf [8]byte
+	S.LoadBlock(buf[:size])
+	return S.decodeUint(buf[:size])
+}
+
+// decodeUint 按块的字节序解码b中的无符号整数
+func (S *loadState) decodeUint(b []byte) uint64 {
+	var x uint64
+	for i := range b {
+		if S.bigEndian {
+			x = x<<8 | uint64(b[i])
+		} else {
+			x |= uint64(b[i]) << (8 * i)
+		}
 	}
+	return x
+}
+
+// loadSigned 读入一个size字节的有符号整数
+func (S *loadState) loadSigned(size int) int64 {
+	var shift = 64 - 8*size
+	return int64(S.loadUint(size)<<shift) >> shift /* sign extension */
 }
 
 // LoadBlock
@@ -88,29 +109,39 @@ func (S *loadState) LoadChar() int {
 // LoadInt
 // 对应C函数：`static int LoadInt(LoadState* S)'
 func (S *loadState) LoadInt() int {
-	var x int
-	S.LoadVar(&x)
-	S.IF(x < 0, "bad integer")
-	return x
+	var x = S.loadSigned(S.sizeInt)
+	S.IF(x < 0 || x > math.MaxInt32, "bad integer")
+	return int(x)
+}
+
+// LoadSize 读入一个size_t
+func (S *loadState) LoadSize() int {
+	var x = S.loadUint(S.sizeSizeT)
+	S.IF(x > math.MaxInt32, "bad integer")
+	return int(x)
 }
 
 // LoadNumber
 // 对应C函数：`static lua_Number LoadNumber(LoadState* S)'
 func (S *loadState) LoadNumber() LuaNumber {
-	var x LuaNumber
-	S.LoadVar(&x)
-	return x
+	if S.integral {
+		return LuaNumber(S.loadSigned(S.sizeNumber))
+	}
+	var x = S.loadUint(S.sizeNumber)
+	if S.sizeNumber == 4 {
+		return LuaNumber(math.Float32frombits(uint32(x)))
+	}
+	return math.Float64frombits(x)
 }
 
 // LoadString
 // 对应C函数：`static TString* LoadString(LoadState* S)'
 func (S *loadState) LoadString() *TString {
-	var size int
-	S.LoadVar(&size)
+	var size = S.LoadSize()
 	if size == 0 {
 		return nil
 	} else {
-		s := S.b.OpenSpace(size)
+		s := S.b.OpenSpace(S.L, size)
 		S.LoadBlock(s[:size])
 		// todo: 是否需要移除末尾的'\0'??
 		return S.L.sNewStr(s[:size-1]) /* remove trailing '\0' */
@@ -121,8 +152,14 @@ func (S *loadState) LoadString() *TString {
 // 对应C函数：`static void LoadCode(LoadState* S, Proto* f)'
 func (S *loadState) LoadCode(f *Proto) {
 	var n = S.LoadInt()
-	f.code.Init(n, S.L)
-	S.LoadVector(f.code, n, int(unsafe.Sizeof(Instruction(0))))
+	f.code.Init(n+1, S.L) /* 多留一个元素，见closeFunc */
+	f.code = f.code[:n]
+	var size = int(unsafe.Sizeof(Instruction(0)))
+	var b = S.b.OpenSpace(S.L, n*size)
+	S.LoadBlock(b[:n*size])
+	for i := range f.code {
+		f.code[i] = Instruction(S.decodeUint(b[i*size : (i+1)*size]))
+	}
 }
 
 // LoadConstants
@@ -161,7 +198,9 @@ func (S *loadState) LoadConstants(f *Proto) {
 func (S *loadState) LoadDebug(f *Proto) {
 	n := S.LoadInt()
 	f.lineInfo.Init(n, S.L)
-	S.LoadVector(f.lineInfo, n, int(unsafe.Sizeof(int(0))))
+	for i := 0; i < n; i++ {
+		f.lineInfo[i] = S.LoadInt()
+	}
 	n = S.LoadInt()
 	f.locVars.Init(n, S.L)
 	for i := 0; i < n; i++ {
@@ -199,19 +238,34 @@ func (S *loadState) LoadFunction(p *TString) *Proto {
 	S.LoadCode(f)
 	S.LoadConstants(f)
 	S.LoadDebug(f)
-	S.IF(gCheckCode(f) != 0, "bad code")
-	S.L.top++
+	S.IF(!f.gCheckCode(), "bad code")
+	S.L.top--
 	S.L.nCCalls--
 	return f
 }
 
 // LoadHeader
+// 与C不同，不要求头部与本机的完全相同：任何Lua 5.1格式的块都可以读入，
+// int、size_t和lua_Number的大小以及字节序记在S中，读入时再转换
 // 对应C函数：`static void LoadHeader(LoadState* S)'
 func (S *loadState) LoadHeader() {
 	var h, s [LUAC_HEADERSIZE]byte
 	uHeader(h[:])
 	S.LoadBlock(s[:])
-	S.IF(bytes.Compare(h[:], s[:]) != 0, "bad header")
+	/* signature, version and format must match; the rest describes the layout */
+	S.IF(!bytes.Equal(h[:6], s[:6]), "bad header")
+	S.bigEndian = s[6] == 0
+	S.sizeInt = int(s[7])
+	S.sizeSizeT = int(s[8])
+	S.sizeNumber = int(s[10])
+	S.integral = s[11] != 0
+	S.IF(s[6] > 1 ||
+		S.sizeInt < 2 || S.sizeInt > 8 ||
+		S.sizeSizeT < 2 || S.sizeSizeT > 8 ||
+		int(s[9]) != int(unsafe.Sizeof(Instruction(0))) ||
+		!S.integral && S.sizeNumber != 4 && S.sizeNumber != 8 ||
+		S.integral && (S.sizeNumber < 1 || S.sizeNumber > 8) ||
+		s[11] > 1, "bad header")
 }
 
 // uUndump load precompiled chuck
@@ -2,6 +2,8 @@ package golua
 
 import (
 	"bytes"
+	"encoding/binary"
+	"math"
 	"testing"
 )
 
@@ -14,16 +16,256 @@ func Test_uHeader(t *testing.T) {
 	}
 }
 
+func newTestLoadState(data []byte) *loadState {
+	var z ZIO
+	z.Init(nil, getS, &loadS{s: data, size: len(data)})
+	return &loadState{Z: &z}
+}
+
 func Test_loadState_LoadVar(t *testing.T) {
-	s := &loadState{}
-	var x float64 = 1.1
+	var data = make([]byte, 8)
+	binary.LittleEndian.PutUint64(data, math.Float64bits(1.1))
+	s := newTestLoadState(data)
+	var x float64
 	s.LoadVar(&x)
-	t.Log(x)
+	if x != 1.1 {
+		t.Errorf("want 1.1 got %v", x)
+	}
+}
+
+func Test_loadState_LoadCode(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		var data = make([]byte, 44)
+		order.PutUint32(data, 10) /* 4-byte int, as written by a 32-bit luac */
+		for i := 0; i < 10; i++ {
+			order.PutUint32(data[4+i*4:], uint32(i)<<8)
+		}
+		s := newTestLoadState(data)
+		s.L = LuaOpen()
+		s.b = new(MBuffer)
+		s.bigEndian = order == binary.BigEndian
+		s.sizeInt = 4
+		var f Proto
+		s.LoadCode(&f)
+		for i, v := range f.code {
+			if v != Instruction(i<<8) {
+				t.Errorf("%v: want %v got %v", order, i<<8, v)
+			}
+		}
+		s.L.Close()
+	}
+}
+
+func Test_loadState_LoadNumber(t *testing.T) {
+	var cases = []struct {
+		data       []byte
+		bigEndian  bool
+		sizeNumber int
+		integral   bool
+		want       LuaNumber
+	}{
+		{binary.BigEndian.AppendUint64(nil, math.Float64bits(-1.25)), true, 8, false, -1.25},
+		{binary.LittleEndian.AppendUint32(nil, math.Float32bits(0.5)), false, 4, false, 0.5},
+		{binary.LittleEndian.AppendUint32(nil, uint32(0xfffffffd)), false, 4, true, -3},
+		{binary.BigEndian.AppendUint64(nil, 1<<40), true, 8, true, 1 << 40},
+	}
+	for _, c := range cases {
+		s := newTestLoadState(c.data)
+		s.bigEndian, s.sizeNumber, s.integral = c.bigEndian, c.sizeNumber, c.integral
+		if x := s.LoadNumber(); x != c.want {
+			t.Errorf("% x: want %v got %v", c.data, c.want, x)
+		}
+	}
+}
+
+func TestUndumpBadHeader(t *testing.T) {
+	L := LuaOpen()
+	if L.LLoadString("return 1") != 0 {
+		t.Fatal(L.ToString(-1))
+	}
+	var data []byte
+	L.Dump(func(L *LuaState, p []byte, sz int, ud interface{}) int {
+		data = append(data, p[:sz]...)
+		return 0
+	}, nil, false)
+	L.Close()
+	for _, c := range []struct{ pos, b int }{
+		{4, 0x50}, /* version */
+		{5, 1},    /* format */
+		{6, 2},    /* endianness */
+		{9, 8},    /* sizeof(Instruction) */
+		{10, 16},  /* sizeof(lua_Number) */
+	} {
+		var bad = bytes.Clone(data)
+		bad[c.pos] = byte(c.b)
+		L := LuaOpen()
+		if L.LLoadBuffer(bad, "=bad") != LUA_ERRSYNTAX || L.ToString(-1) != "bad: bad header in precompiled chunk" {
+			t.Errorf("byte %d = %d: %s", c.pos, c.b, L.ToString(-1))
+		}
+		L.Close()
+	}
+}
+
+// chunkLayout 描述预编译块头部声明的格式
+type chunkLayout struct {
+	order      binary.ByteOrder
+	sizeInt    int
+	sizeSizeT  int
+	sizeNumber int
+	integral   bool
+}
+
+// chunkRecoder 把本机格式（见Test_uHeader）的预编译块改写成另一种布局。
+// 得到的块不是luac 5.1.4的输出，它们只让LoadHeader、LoadSize和LoadString
+// 在没有参考编译器的情况下也能被端到端地执行到
+type chunkRecoder struct {
+	in  []byte
+	out []byte
+	to  chunkLayout
+}
+
+func (r *chunkRecoder) take(n int) []byte {
+	var b = r.in[:n]
+	r.in = r.in[n:]
+	return b
+}
+
+func (r *chunkRecoder) put(x uint64, size int) {
+	var b [8]byte
+	if r.to.order == binary.BigEndian {
+		binary.BigEndian.PutUint64(b[:], x)
+		r.out = append(r.out, b[8-size:]...)
+	} else {
+		binary.LittleEndian.PutUint64(b[:], x)
+		r.out = append(r.out, b[:size]...)
+	}
 }
 
-func Test_loadState_LoadVector(t *testing.T) {
-	s := &loadState{}
-	var x = make([]Instruction, 10)
-	s.LoadVector(x, 10, 4)
-	t.Log(x)
+func (r *chunkRecoder) copyBytes(n int) {
+	r.out = append(r.out, r.take(n)...)
+}
+
+func (r *chunkRecoder) int() int {
+	var x = int(binary.LittleEndian.Uint64(r.take(8)))
+	r.put(uint64(x), r.to.sizeInt)
+	return x
+}
+
+func (r *chunkRecoder) string() {
+	var n = binary.LittleEndian.Uint64(r.take(8))
+	r.put(n, r.to.sizeSizeT)
+	r.copyBytes(int(n))
+}
+
+func (r *chunkRecoder) number() {
+	var x = math.Float64frombits(binary.LittleEndian.Uint64(r.take(8)))
+	switch {
+	case r.to.integral:
+		r.put(uint64(int64(x)), r.to.sizeNumber)
+	case r.to.sizeNumber == 4:
+		r.put(uint64(math.Float32bits(float32(x))), 4)
+	default:
+		r.put(math.Float64bits(x), 8)
+	}
+}
+
+func (r *chunkRecoder) function() {
+	r.string()     /* source */
+	r.int()        /* lineDefined */
+	r.int()        /* lastLineDefined */
+	r.copyBytes(4) /* nups, numparams, is_vararg, maxstacksize */
+	for n := r.int(); n > 0; n-- {
+		r.put(uint64(binary.LittleEndian.Uint32(r.take(4))), 4)
+	}
+	for n := r.int(); n > 0; n-- {
+		var t = r.take(1)[0]
+		r.out = append(r.out, t)
+		switch ttype(t) {
+		case LUA_TBOOLEAN:
+			r.copyBytes(1)
+		case LUA_TNUMBER:
+			r.number()
+		case LUA_TSTRING:
+			r.string()
+		}
+	}
+	for n := r.int(); n > 0; n-- {
+		r.function()
+	}
+	for n := r.int(); n > 0; n-- { /* lineinfo */
+		r.int()
+	}
+	for n := r.int(); n > 0; n-- { /* locvars */
+		r.string()
+		r.int()
+		r.int()
+	}
+	for n := r.int(); n > 0; n-- { /* upvalues */
+		r.string()
+	}
+}
+
+func recodeChunk(chunk []byte, to chunkLayout) []byte {
+	var r = chunkRecoder{in: chunk, to: to}
+	var h = r.take(LUAC_HEADERSIZE)
+	r.out = append(r.out, h[:6]...)
+	var endian, integral byte = 1, 0
+	if to.order == binary.BigEndian {
+		endian = 0
+	}
+	if to.integral {
+		integral = 1
+	}
+	r.out = append(r.out, endian, byte(to.sizeInt), byte(to.sizeSizeT), 4, byte(to.sizeNumber), integral)
+	r.function()
+	return r.out
+}
+
+func TestUndumpLayouts(t *testing.T) {
+	const fib = `
+		local function fib(n)
+			if n < 2 then return n end
+			return fib(n - 1) + fib(n - 2)
+		end
+		local s = "layout" .. "-ok"
+		return fib(10), s, #s, `
+	var cases = []struct {
+		name   string
+		layout chunkLayout
+		last   string
+		want   LuaNumber
+	}{
+		{"32-bit little endian", chunkLayout{binary.LittleEndian, 4, 4, 8, false}, "1.25", 1.25},
+		{"32-bit big endian", chunkLayout{binary.BigEndian, 4, 4, 8, false}, "1.25", 1.25},
+		{"64-bit big endian", chunkLayout{binary.BigEndian, 8, 8, 8, false}, "1.25", 1.25},
+		{"float numbers", chunkLayout{binary.LittleEndian, 4, 4, 4, false}, "1.25", 1.25},
+		{"integral numbers", chunkLayout{binary.BigEndian, 4, 4, 4, true}, "-7", -7},
+		{"64-bit integral numbers", chunkLayout{binary.LittleEndian, 4, 8, 8, true}, "1e12", 1e12},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			L := LuaOpen()
+			defer L.Close()
+			if L.LLoadString(fib+c.last) != 0 {
+				t.Fatal(L.ToString(-1))
+			}
+			var native []byte
+			L.Dump(func(L *LuaState, p []byte, sz int, ud interface{}) int {
+				native = append(native, p[:sz]...)
+				return 0
+			}, nil, false)
+			L.Pop(1)
+			var chunk = recodeChunk(native, c.layout)
+			if L.LLoadBuffer(chunk, "=chunk") != 0 {
+				t.Fatal(L.ToString(-1))
+			}
+			if L.PCall(0, LUA_MULTRET, 0) != 0 {
+				t.Fatal(L.ToString(-1))
+			}
+			if L.GetTop() != 4 || L.ToNumber(1) != 55 || L.ToString(2) != "layout-ok" ||
+				L.ToNumber(3) != 9 || L.ToNumber(4) != c.want {
+				t.Errorf("results: %v %q %v %v", L.ToNumber(1), L.ToString(2), L.ToNumber(3), L.ToNumber(4))
+			}
+		})
+	}
 }
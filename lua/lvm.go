@@ -57,7 +57,7 @@ func (L *LuaState) vConcat(total int, last int) {
 				}
 				tl += l
 			}
-			buffer := L.G().buff.OpenSpace(tl)
+			buffer := L.G().buff.OpenSpace(L, tl)
 			tl = 0
 			for i := n; i > 0; i-- { /* concat all strings */
 				s := top.Ptr(-i).StringValue()
@@ -76,7 +76,7 @@ func (L *LuaState) vConcat(total int, last int) {
 //	const TValue *rc, TMS op)'
 func arith(L *LuaState, ra StkId, rb, rc *TValue, op TMS) {
 	b := vToNumber(rb, &TValue{})
-	c := vToNumber(rb, &TValue{})
+	c := vToNumber(rc, &TValue{})
 	if b != nil && c != nil {
 		nb, nc := b.NumberValue(), c.NumberValue()
 		switch op {
@@ -124,7 +124,7 @@ func (L *LuaState) vSetTable(t *TValue, key *TValue, val StkId) {
 				return
 			}
 			/* else will try the tag method */
-		} else if tm = L.tGetTMByObj(t, TM_NEWINDEX); tm == nil {
+		} else if tm = L.tGetTMByObj(t, TM_NEWINDEX); tm.IsNil() {
 			L.gTypeError(t, "index")
 		}
 
@@ -139,8 +139,15 @@ func (L *LuaState) vSetTable(t *TValue, key *TValue, val StkId) {
 
 // 对应C函数：`static int call_binTM (lua_State *L, const TValue *p1, const TValue *p2, StkId res, TMS event)'
 func callBinTM(L *LuaState, p1 *TValue, p2 *TValue, res StkId, event TMS) bool {
-	// todo: callBinTM
-	return false
+	var tm = L.tGetTMByObj(p1, event) /* try first operand */
+	if tm.IsNil() {
+		tm = L.tGetTMByObj(p2, event) /* try second operand */
+	}
+	if tm.IsNil() {
+		return false
+	}
+	callTMRes(L, res, tm, p1, p2)
+	return true
 }
 
 // 对应C函数：`static void callTMres (lua_State *L, StkId res, const TValue *f,
@@ -208,7 +215,7 @@ func (L *LuaState) vExecute(nExecCalls int) {
 		ColorSlave   = "\u001B[35m"
 		ColorIgnored = "\u001B[36m"
 	)
-	if DEBUG {
+	if TRACE_VM {
 		var k = L.CI().Func().L().p.k
 		fmt.Println("\u001B[34mCONSTANTS======================={\u001B[0m")
 		for i, value := range k {
@@ -276,7 +283,7 @@ reentry: /* entry point */
 			return &k[i.GetArgBx()]
 		}
 
-		getKst = func(i int) string { // DEBUG 使用的辅助函数
+		getKst = func(i int) string { // TRACE_VM 使用的辅助函数
 			var v = k[i]
 			var s string
 			if v.IsNumber() {
@@ -292,7 +299,7 @@ reentry: /* entry point */
 			pc = pc.Ptr(1)
 		}
 		dumpCode = func(instruction *Instruction, color string) {
-			if DEBUG {
+			if TRACE_VM {
 				fmt.Printf("%s%s%s\n",
 					color, instruction.DumpCode(getKst, L.top-L.base), ColorReset)
 			}
@@ -302,7 +309,7 @@ reentry: /* entry point */
 			L.iThreadYield()
 		}
 		dumpJumped = func(p *Instruction, n int) {
-			if DEBUG {
+			if TRACE_VM {
 				for j := 0; j < n; j++ {
 					dumpCode(p.Ptr(j), ColorIgnored)
 				}
@@ -315,7 +322,7 @@ reentry: /* entry point */
 		var i = *pc
 		pc = pc.Ptr(1) // pc++
 
-		if DEBUG {
+		if TRACE_VM {
 			fmt.Printf("\u001B[34m%s\u001B[0m\n", i.DumpCode(getKst, L.top-L.base))
 		}
 
@@ -465,6 +472,18 @@ reentry: /* entry point */
 				base = L.base
 			}
 			continue
+		case OP_POW:
+			var rb = RKB(i)
+			var rc = RKC(i)
+			if rb.IsNumber() && rc.IsNumber() {
+				var nb, nc = rb.NumberValue(), rc.NumberValue()
+				ra.SetNumber(luai_numpow(nb, nc))
+			} else {
+				L.savedPc = pc // Protect
+				arith(L, ra, rb, rc, TM_POW)
+				base = L.base
+			}
+			continue
 		case OP_UNM:
 			var rb = RB(i)
 			if rb.IsNumber() {
@@ -505,7 +524,7 @@ reentry: /* entry point */
 			RA(i).SetObj(L, &L.stack[base+b])
 			continue
 		case OP_JMP:
-			if DEBUG {
+			if TRACE_VM {
 				for j := 0; j < i.GetArgSBx(); j++ {
 					dumpCode(pc.Ptr(j), ColorIgnored)
 				}
@@ -587,7 +606,7 @@ reentry: /* entry point */
 			switch L.dPrecall(ra, LUA_MULTRET) {
 			case PCRLUA:
 				/* tail call: put new frame in place of previous one */
-				var ci = L.baseCi[L.ci-1] /* previous frame */
+				var ci = &L.baseCi[L.ci-1] /* previous frame */
 				var fn = ci.fn
 				var pfn = L.CI().fn /* previous function index */
 				var pfnIdx = adr2idx(L, L.CI().fn)
@@ -596,6 +615,8 @@ reentry: /* entry point */
 				if L.openUpval != nil {
 					L.fClose(&L.stack[ci.base])
 				}
+				ci.base = adr2idx(L, fn) + (L.CI().base - pfnIdx)
+				L.base = ci.base
 				for aux = 0; pfnIdx+aux < L.top; aux++ {
 					fn.Ptr(aux).SetObj(L, pfn.Ptr(aux))
 				}
@@ -652,7 +673,7 @@ reentry: /* entry point */
 			if !tonumber(init, RA(i)) {
 				L.DbgRunError("'for' initial value must be a number")
 			} else if !tonumber(pLimit, RA(i).Ptr(1)) {
-				L.DbgRunError("'for' limit must be number")
+				L.DbgRunError("'for' limit must be a number")
 			} else if !tonumber(pStep, RA(i).Ptr(2)) {
 				L.DbgRunError("'for' step must be a number")
 			}
@@ -720,7 +741,7 @@ reentry: /* entry point */
 					LuaAssert(pc.GetOpCode() == OP_MOVE)
 					ncl.upVals[j] = L.fFindUpVal(&L.stack[base+pc.GetArgB()])
 				}
-				if DEBUG {
+				if TRACE_VM {
 					fmt.Printf("\u001B[34m%s \u001B[35m", pc.DumpCode(getKst, L.top-L.base)[:33])
 					if pc.GetOpCode() == OP_GETUPVAL {
 						fmt.Printf("r%d.upvals[%d] := cl.upvals[%d]", i.GetArgA(), j, pc.GetArgB())
@@ -804,7 +825,7 @@ func vEqualVal(L *LuaState, t1, t2 *TValue) bool {
 		if t1.UdataValue() == t2.UdataValue() {
 			return true
 		}
-		tm = get_compTM(L, t1.UdataValue().metatable, t1.UdataValue().metatable, TM_EQ)
+		tm = get_compTM(L, t1.UdataValue().metatable, t2.UdataValue().metatable, TM_EQ)
 		break /* will try TM */
 	case LUA_TTABLE:
 		if t1.TableValue() == t2.TableValue() {
@@ -828,7 +849,7 @@ func vEqualVal(L *LuaState, t1, t2 *TValue) bool {
 //	TMS event)
 func get_compTM(L *LuaState, mt1, mt2 *Table, event TMS) *TValue {
 	var tm1 = FastTM(L, mt1, event)
-	if mt1 == nil { /* no metamethod */
+	if tm1 == nil { /* no metamethod */
 		return nil
 	}
 	if mt1 == mt2 { /* same metatables => same metamethods */
@@ -1,6 +1,7 @@
 package golua
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -42,3 +43,133 @@ func TestBreakSwitch(t *testing.T) {
 		}
 	}
 }
+
+func TestVarArg(t *testing.T) {
+	L := LuaOpen()
+	defer L.Close()
+	/* the implicit `arg' parameter must not shift the varargs */
+	if L.LDoString(`
+		local function f(...) return ... end
+		local function g(x, ...) return x, ... end
+		local function h(...) return arg.n, arg[2] end
+		local a, b, c, d, e = f("a", "b")
+		local n, v = h(1, 2)
+		return a, b, c, n, v, g(1, 2, 3)`) != 0 {
+		t.Fatal(L.ToString(-1))
+	}
+	want := []string{"a", "b", "nil", "2", "2", "1", "2", "3"}
+	if L.GetTop() != len(want) {
+		t.Fatalf("want %d results got %d", len(want), L.GetTop())
+	}
+	for i, w := range want {
+		var got = "nil"
+		if !L.IsNil(i + 1) {
+			got = L.ToString(i + 1)
+		}
+		if got != w {
+			t.Errorf("result %d: want %s got %s", i+1, w, got)
+		}
+	}
+}
+
+// openMeta 返回注册了setmetatable的状态机，供不依赖标准库的元方法测试使用
+func openMeta() *LuaState {
+	L := LuaOpen()
+	L.Register("setmetatable", func(L *LuaState) int {
+		L.SetTop(2)
+		L.SetMetaTable(1)
+		return 1
+	})
+	return L
+}
+
+func TestMetamethods(t *testing.T) {
+	L := openMeta()
+	defer L.Close()
+	if L.LDoString(`
+		local V = {}
+		local function vec(x, y) return setmetatable({x = x, y = y}, V) end
+		V.__add = function(a, b) return vec(a.x + b.x, a.y + b.y) end
+		V.__sub = function(a, b) return vec(a.x - b.x, a.y - b.y) end
+		V.__mul = function(a, b)
+			if b == 2 then return vec(a.x * 2, a.y * 2) end
+			return a.x * b.x + a.y * b.y
+		end
+		V.__div = function(a, b) return a.x / b end
+		V.__mod = function(a, b) return a.x % b end
+		V.__pow = function(a, b) return a.x ^ b end
+		V.__unm = function(a) return vec(-a.x, -a.y) end
+		V.__eq = function(a, b) return a.x == b.x and a.y == b.y end
+		V.__lt = function(a, b) return a.x < b.x end
+		V.__concat = function(a, b)
+			if a == V then return "V" .. b.x end
+			return a.x .. "|" .. b
+		end
+		V.__call = function(self, k) return self[k] end
+		V.__index = function(t, k) return k .. "?" end
+		local a, b = vec(3, 4), vec(1, 2)
+		local c = a + b
+		return c.x, (a - b).y, (a * 2).y, a * b, a / 2, a % 2, a ^ 2, (-a).x,
+			a == vec(3, 4), a ~= b, b < a, a <= b, a > b, b >= b,
+			a .. "s" .. 1, V .. a, a("y"), a.z, 2 ^ 10, -2 ^ 2`) != 0 {
+		t.Fatal(L.ToString(-1))
+	}
+	want := []string{"4", "2", "8", "11", "1.5", "1", "9", "-3",
+		"true", "true", "true", "false", "true", "true",
+		"3|s1", "V3", "4", "z?", "1024", "-4"}
+	if L.GetTop() != len(want) {
+		t.Fatalf("want %d results got %d", len(want), L.GetTop())
+	}
+	for i, w := range want {
+		var got = L.ToString(i + 1)
+		if L.IsBoolean(i + 1) {
+			got = "false"
+			if L.ToBoolean(i + 1) {
+				got = "true"
+			}
+		}
+		if got != w {
+			t.Errorf("result %d: want %s got %s", i+1, w, got)
+		}
+	}
+}
+
+func TestMetamethodChains(t *testing.T) {
+	L := openMeta()
+	defer L.Close()
+	if L.LDoString(`
+		local base = {hello = "base"}
+		local top = setmetatable({}, {__index = setmetatable({}, {__index = base})})
+		local store = {}
+		local proxy = setmetatable({}, {__newindex = setmetatable({}, {__newindex = store})})
+		proxy.k = 5
+		local W = {__lt = function(a, b) return a.v < b.v end}  -- no __le: uses not (b < a)
+		local w1, w2 = setmetatable({v = 1}, W), setmetatable({v = 2}, W)
+		local E = {__eq = function() return true end}
+		local F = {__eq = function() return true end}
+		return top.hello, proxy.k, store.k, w1 <= w2, w2 <= w1,
+			setmetatable({}, E) == setmetatable({}, E), setmetatable({}, E) == setmetatable({}, F)`) != 0 {
+		t.Fatal(L.ToString(-1))
+	}
+	if L.ToString(1) != "base" || !L.IsNil(2) || L.ToInteger(3) != 5 ||
+		!L.ToBoolean(4) || L.ToBoolean(5) || !L.ToBoolean(6) || L.ToBoolean(7) {
+		t.Errorf("unexpected results: %s %v %d %v %v %v %v", L.ToString(1), L.TypeName(L.Type(2)),
+			L.ToInteger(3), L.ToBoolean(4), L.ToBoolean(5), L.ToBoolean(6), L.ToBoolean(7))
+	}
+
+	for chunk, msg := range map[string]string{
+		`return {} + 1`:    "attempt to perform arithmetic on a table value",
+		`return {} < {}`:   "attempt to compare two table values",
+		`return {} .. "x"`: "attempt to concatenate a table value",
+		`return #nil`:      "attempt to get length of a nil value",
+		`return ({})()`:    "attempt to call a table value",
+		`local t = {}; setmetatable(t, {__index = t}); return t.x`: "loop in gettable",
+	} {
+		L.SetTop(0)
+		if L.LDoString(chunk) == 0 {
+			t.Errorf("%s: no error", chunk)
+		} else if got := L.ToString(-1); !strings.Contains(got, msg) {
+			t.Errorf("%s: want %q got %q", chunk, msg, got)
+		}
+	}
+}
@@ -102,19 +102,20 @@ func (m *MBuffer) Init() {
 
 // OpenSpace
 // 对应C函数：`char *luaZ_openspace (lua_State *L, Mbuffer *buff, size_t n)'
-func (m *MBuffer) OpenSpace(n int) []byte {
+func (m *MBuffer) OpenSpace(L *LuaState, n int) []byte {
 	if n > m.size {
 		if n < LUA_MINBUFFER {
 			n = LUA_MINBUFFER
 		}
-		m.Resize(n)
+		m.Resize(L, n)
 	}
 	return m.buffer
 }
 
 // Resize
 // 对应C函数：`luaZ_resizebuffer'
-func (m *MBuffer) Resize(n int) {
+func (m *MBuffer) Resize(L *LuaState, n int) {
+	L.mBufRealloc(m.size, n)
 	b := make([]byte, n)
 	copy(b, m.buffer)
 	m.buffer = b
@@ -123,8 +124,8 @@ func (m *MBuffer) Resize(n int) {
 
 // Free
 // 对应C函数：`luaZ_freebuffer(L, buff)'
-func (m *MBuffer) Free() {
-	m.Resize(0)
+func (m *MBuffer) Free(L *LuaState) {
+	m.Resize(L, 0)
 }
 
 // Reset
@@ -3,9 +3,11 @@ package golua
 import "testing"
 
 func TestMBuffer_Resize(t *testing.T) {
+	L := NewState(nil, nil)
+	defer L.Close()
 	buf := &MBuffer{}
-	buf.OpenSpace(100)
-	buf.Free()
+	buf.OpenSpace(L, 100)
+	buf.Free(L)
 	if buf.n != 0 || cap(buf.buffer) != 0 {
 		t.Error("free MBuffer error")
 	}
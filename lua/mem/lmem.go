@@ -16,6 +16,8 @@ type Vec[T any] []T
 
 type ErrorHandler interface {
 	DbgRunError(format string, args ...interface{})
+	// MRealloc 登记一块内存从osize字节变为nsize字节，内存不足时抛出错误而不返回
+	MRealloc(osize int, nsize int)
 }
 
 func (v *Vec[T]) Size() int {
@@ -31,7 +33,9 @@ func ErrTooBig(h ErrorHandler) {
 // ReAlloc
 // 对应C函数：`luaM_reallocvector(L, v,oldn,n,t)'
 func (v *Vec[T]) ReAlloc(n int, h ErrorHandler) {
-	if uintptr(n+1) <= uintptr(MAX_SIZET)/unsafe.Sizeof(*(*T)(nil)) { /* +1 to avoid warnings */
+	var sz = unsafe.Sizeof(*(*T)(nil))
+	if uintptr(n+1) <= uintptr(MAX_SIZET)/sz { /* +1 to avoid warnings */
+		h.MRealloc(len(*v)*int(sz), n*int(sz))
 		var v2 = make([]T, n)
 		copy(v2, *v)
 		*v = v2
@@ -50,7 +54,9 @@ func (v *Vec[T]) Free(h ErrorHandler) {
 // 对应C函数：`luaM_newvector(L,n,t)'
 func (v *Vec[T]) Init(size int, h ErrorHandler) {
 	/* 这里不使用ReAlloc(size, h)，因为ReAlloc中会进行copy，在这里不需要copy旧的值到*v中 */
-	if uintptr(size+1) <= uintptr(MAX_SIZET)/unsafe.Sizeof(*(*T)(nil)) { /* +1 to avoid warnings */
+	var sz = unsafe.Sizeof(*(*T)(nil))
+	if uintptr(size+1) <= uintptr(MAX_SIZET)/sz { /* +1 to avoid warnings */
+		h.MRealloc(0, size*int(sz))
 		*v = make([]T, size)
 	} else {
 		ErrTooBig(h)
@@ -83,6 +89,8 @@ func (v *Vec[T]) growAux_(h ErrorHandler, limit int, errMsg string) {
 			sz = MINSIZEARRAY /* minimum size */
 		}
 	}
+	var elemSize = int(unsafe.Sizeof(*(*T)(nil)))
+	h.MRealloc(size*elemSize, sz*elemSize)
 	var v2 = make([]T, sz)
 	copy(v2, *v)
 	*v = v2
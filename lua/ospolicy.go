@@ -0,0 +1,98 @@
+package golua
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// OSPolicy
+// 宿主对os库所能使用的系统能力的控制。为nil的字段使用真实的系统实现，
+// 因此测试中可以只冻结时钟，生产环境中可以只禁止执行外部命令。
+// 拒绝某个操作时返回错误即可（例如os.ErrPermission），脚本会像系统调用失败一样得到它。
+// 禁止执行外部命令时要同时拒绝Execute和Popen。
+// io.open和io.tmpfile不受OSPolicy控制，需要限制文件访问时不要打开io库。
+type OSPolicy struct {
+	Now      func() time.Time                          /* current time for os.time and os.date */
+	Location *time.Location                            /* zone for local dates; nil means time.Local */
+	Clock    func() float64                            /* seconds of processor time for os.clock */
+	Getenv   func(name string) (string, bool)          /* os.getenv */
+	Remove   func(name string) error                   /* os.remove */
+	Rename   func(from, to string) error               /* os.rename */
+	TmpName  func() (string, error)                    /* os.tmpname */
+	Execute  func(command string) (int, error)         /* os.execute; "" asks whether a shell is available */
+	Popen    func(command, mode string) (*FILE, error) /* io.popen */
+	Exit     func(code int)                            /* os.exit */
+}
+
+// 对应C宏：`lua_tmpnam(b,e)'，与使用mkstemp时一样会创建这个文件
+func tmpName() (string, error) {
+	f, err := os.CreateTemp("", "lua_")
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	return f.Name(), nil
+}
+
+// 对应C函数：`system(command)'，返回命令的退出码，无法执行时返回错误
+func system(command string) (int, error) {
+	var shell, flag = "/bin/sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/c"
+	}
+	if command == "" { /* is a shell available? */
+		if _, err := exec.LookPath(shell); err != nil {
+			return 0, nil
+		}
+		return 1, nil
+	}
+	var cmd = exec.Command(shell, flag, command)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	var err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
+
+// 用系统实现补全p中为nil的字段
+func (p OSPolicy) withDefaults() OSPolicy {
+	if p.Now == nil {
+		p.Now = time.Now
+	}
+	if p.Location == nil {
+		p.Location = time.Local
+	}
+	if p.Clock == nil {
+		p.Clock = clock
+	}
+	if p.Getenv == nil {
+		p.Getenv = os.LookupEnv
+	}
+	if p.Remove == nil {
+		p.Remove = os.Remove
+	}
+	if p.Rename == nil {
+		p.Rename = os.Rename
+	}
+	if p.TmpName == nil {
+		p.TmpName = tmpName
+	}
+	if p.Execute == nil {
+		p.Execute = system
+	}
+	if p.Popen == nil {
+		p.Popen = Popen
+	}
+	if p.Exit == nil {
+		p.Exit = os.Exit
+	}
+	return p
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package golua
+
+import "time"
+
+/* 这些平台上读不到处理器时间，默认时钟从启动开始计时 */
+var startTime = time.Now()
+
+// 对应C函数：`clock()'
+func clock() float64 {
+	return time.Since(startTime).Seconds()
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package golua
+
+import "syscall"
+
+// 对应C函数：`clock()'，进程已使用的处理器时间（用户态加内核态）
+func clock() float64 {
+	var ru syscall.Rusage
+	if syscall.Getrusage(syscall.RUSAGE_SELF, &ru) != nil {
+		return 0
+	}
+	var t = ru.Utime.Nano() + ru.Stime.Nano()
+	return float64(t) / 1e9
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package golua
+
+import (
+	"testing"
+	"time"
+)
+
+/* the default clock measures processor time, so sleeping must not advance it */
+func Test_clock(t *testing.T) {
+	var c0 = clock()
+	time.Sleep(200 * time.Millisecond)
+	var c1 = clock()
+	for i := 0; clock()-c1 < 0.01 && i < 1e9; i++ {
+	}
+	var c2 = clock()
+	if c1-c0 > 0.1 || c2 <= c1 {
+		t.Errorf("clock: %v, %v, %v", c0, c1, c2)
+	}
+}
@@ -0,0 +1,419 @@
+// Package pattern 是从lstrlib.c中分离出来的Lua 5.1模式匹配引擎，
+// 可以脱离LuaState直接在[]byte上使用，语义与string.find/match/gmatch/gsub完全一致
+package pattern
+
+import (
+	"bytes"
+	"errors"
+)
+
+// MaxCaptures 单个模式中最多的捕获数
+// 对应C宏：`LUA_MAXCAPTURES'
+const MaxCaptures = 32
+
+const (
+	capUnfinished = -1
+	capPosition   = -2
+)
+
+const lEsc = '%'
+
+// Specials 模式中具有特殊含义的字符，不含这些字符的模式等同于普通查找
+const Specials = "^$*+?.([%-"
+
+// 模式匹配过程中可能产生的错误，消息与C实现一致
+var (
+	ErrEndsWithEscape    = errors.New("malformed pattern (ends with '%')")
+	ErrMissingBracket    = errors.New("malformed pattern (missing ']')")
+	ErrUnbalanced        = errors.New("unbalanced pattern")
+	ErrFrontierBracket   = errors.New("missing '[' after '%f' in pattern")
+	ErrTooManyCaptures   = errors.New("too many captures")
+	ErrInvalidCapture    = errors.New("invalid capture index")
+	ErrInvalidPattern    = errors.New("invalid pattern capture")
+	ErrUnfinishedCapture = errors.New("unfinished capture")
+)
+
+// matchError 用于在递归匹配中跳出，相当于C中的luaL_error
+type matchError struct {
+	err error
+}
+
+// catch 在API边界把matchError转换为返回的error，其它panic原样抛出
+func catch(err *error) {
+	if r := recover(); r != nil {
+		if e, ok := r.(matchError); ok {
+			*err = e.err
+			return
+		}
+		panic(r)
+	}
+}
+
+// matchState
+// 对应C结构体：`struct MatchState'
+// C中的指针在这里都用下标表示，-1相当于NULL
+type matchState struct {
+	src     []byte /* subject */
+	p       []byte /* pattern */
+	level   int    /* total number of captures (finished or unfinished) */
+	capture [MaxCaptures]struct {
+		init int
+		len  int
+	}
+}
+
+func (ms *matchState) error(err error) {
+	panic(matchError{err})
+}
+
+// pat 返回模式串中i处的字符，越界时返回0，模拟C字符串结尾的'\0'
+func (ms *matchState) pat(i int) byte {
+	if i < len(ms.p) {
+		return ms.p[i]
+	}
+	return 0
+}
+
+// 对应C函数：`static int check_capture (MatchState *ms, int l)'
+func (ms *matchState) checkCapture(l byte) int {
+	var i = int(l) - '1'
+	if i < 0 || i >= ms.level || ms.capture[i].len == capUnfinished {
+		ms.error(ErrInvalidCapture)
+	}
+	return i
+}
+
+// 对应C函数：`static int capture_to_close (MatchState *ms)'
+func (ms *matchState) captureToClose() int {
+	var level = ms.level
+	for level--; level >= 0; level-- {
+		if ms.capture[level].len == capUnfinished {
+			return level
+		}
+	}
+	ms.error(ErrInvalidPattern)
+	return 0
+}
+
+// 对应C函数：`static const char *classEnd (MatchState *ms, const char *p)'
+func (ms *matchState) classEnd(p int) int {
+	var c = ms.pat(p)
+	p++
+	switch c {
+	case lEsc:
+		if ms.pat(p) == 0 {
+			ms.error(ErrEndsWithEscape)
+		}
+		return p + 1
+	case '[':
+		if ms.pat(p) == '^' {
+			p++
+		}
+		for { /* look for a `]' */
+			if ms.pat(p) == 0 {
+				ms.error(ErrMissingBracket)
+			}
+			c = ms.pat(p)
+			p++
+			if c == lEsc && ms.pat(p) != 0 {
+				p++ /* skip escapes (e.g. `%]') */
+			}
+			if ms.pat(p) == ']' {
+				break
+			}
+		}
+		return p + 1
+	default:
+		return p
+	}
+}
+
+// 对应C函数：`static int match_class (int c, int cl)'
+// 字符分类与C语言"C" locale下的ctype一致
+func matchClass(c byte, cl byte) bool {
+	var res bool
+	switch toLower(cl) {
+	case 'a':
+		res = isAlpha(c)
+	case 'c':
+		res = c < 32 || c == 127
+	case 'd':
+		res = isDigit(c)
+	case 'l':
+		res = 'a' <= c && c <= 'z'
+	case 's':
+		res = c == ' ' || ('\t' <= c && c <= '\r')
+	case 'u':
+		res = 'A' <= c && c <= 'Z'
+	case 'w':
+		res = isAlpha(c) || isDigit(c)
+	case 'x':
+		res = isDigit(c) || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+	case 'p':
+		res = c > 32 && c < 127 && !isAlpha(c) && !isDigit(c)
+	case 'z':
+		res = c == 0
+	default:
+		return cl == c
+	}
+	if 'A' <= cl && cl <= 'Z' {
+		return !res
+	}
+	return res
+}
+
+// 对应C函数：`static int matchbracketclass (int c, const char *p, const char *ec)'
+func (ms *matchState) matchBracketClass(c byte, p int, ec int) bool {
+	var sig = true
+	if ms.pat(p+1) == '^' {
+		sig = false
+		p++ /* skip the `^' */
+	}
+	for p++; p < ec; p++ {
+		if ms.pat(p) == lEsc {
+			p++
+			if matchClass(c, ms.pat(p)) {
+				return sig
+			}
+		} else if ms.pat(p+1) == '-' && p+2 < ec {
+			p += 2
+			if ms.pat(p-2) <= c && c <= ms.pat(p) {
+				return sig
+			}
+		} else if ms.pat(p) == c {
+			return sig
+		}
+	}
+	return !sig
+}
+
+// 对应C函数：`static int singlematch (int c, const char *p, const char *ep)'
+func (ms *matchState) singleMatch(c byte, p int, ep int) bool {
+	switch ms.pat(p) {
+	case '.': /* matches any char */
+		return true
+	case lEsc:
+		return matchClass(c, ms.pat(p+1))
+	case '[':
+		return ms.matchBracketClass(c, p, ep-1)
+	default:
+		return ms.pat(p) == c
+	}
+}
+
+// 对应C函数：`static const char *matchbalance (MatchState *ms, const char *s, const char *p)'
+func (ms *matchState) matchBalance(s int, p int) int {
+	if ms.pat(p) == 0 || ms.pat(p+1) == 0 {
+		ms.error(ErrUnbalanced)
+	}
+	if s >= len(ms.src) || ms.src[s] != ms.pat(p) {
+		return -1
+	}
+	var b = ms.pat(p)
+	var e = ms.pat(p + 1)
+	var cont = 1
+	for s++; s < len(ms.src); s++ {
+		if ms.src[s] == e {
+			cont--
+			if cont == 0 {
+				return s + 1
+			}
+		} else if ms.src[s] == b {
+			cont++
+		}
+	}
+	return -1 /* string ends out of balance */
+}
+
+// 对应C函数：`static const char *max_expand (MatchState *ms, const char *s, const char *p, const char *ep)'
+func (ms *matchState) maxExpand(s int, p int, ep int) int {
+	var i = 0 /* counts maximum expand for item */
+	for s+i < len(ms.src) && ms.singleMatch(ms.src[s+i], p, ep) {
+		i++
+	}
+	/* keeps trying to match with the maximum repetitions */
+	for i >= 0 {
+		var res = ms.match(s+i, ep+1)
+		if res != -1 {
+			return res
+		}
+		i-- /* else didn't match; reduce 1 repetition to try again */
+	}
+	return -1
+}
+
+// 对应C函数：`static const char *min_expand (MatchState *ms, const char *s, const char *p, const char *ep)'
+func (ms *matchState) minExpand(s int, p int, ep int) int {
+	for {
+		var res = ms.match(s, ep+1)
+		if res != -1 {
+			return res
+		} else if s < len(ms.src) && ms.singleMatch(ms.src[s], p, ep) {
+			s++ /* try with one more repetition */
+		} else {
+			return -1
+		}
+	}
+}
+
+// 对应C函数：`static const char *start_capture (MatchState *ms, const char *s, const char *p, int what)'
+func (ms *matchState) startCapture(s int, p int, what int) int {
+	var level = ms.level
+	if level >= MaxCaptures {
+		ms.error(ErrTooManyCaptures)
+	}
+	ms.capture[level].init = s
+	ms.capture[level].len = what
+	ms.level = level + 1
+	var res = ms.match(s, p)
+	if res == -1 { /* match failed? */
+		ms.level-- /* undo capture */
+	}
+	return res
+}
+
+// 对应C函数：`static const char *end_capture (MatchState *ms, const char *s, const char *p)'
+func (ms *matchState) endCapture(s int, p int) int {
+	var l = ms.captureToClose()
+	ms.capture[l].len = s - ms.capture[l].init /* close capture */
+	var res = ms.match(s, p)
+	if res == -1 { /* match failed? */
+		ms.capture[l].len = capUnfinished /* undo capture */
+	}
+	return res
+}
+
+// 对应C函数：`static const char *match_capture (MatchState *ms, const char *s, int l)'
+func (ms *matchState) matchCapture(s int, l byte) int {
+	var i = ms.checkCapture(l)
+	var init, n = ms.capture[i].init, ms.capture[i].len
+	if len(ms.src)-s >= n && bytes.Equal(ms.src[init:init+n], ms.src[s:s+n]) {
+		return s + n
+	}
+	return -1
+}
+
+// 对应C函数：`static const char *match (MatchState *ms, const char *s, const char *p)'
+// 返回匹配结束的位置，-1表示匹配失败
+func (ms *matchState) match(s int, p int) int {
+	for { /* init: using goto's to optimize tail recursion */
+		switch ms.pat(p) {
+		case '(': /* start capture */
+			if ms.pat(p+1) == ')' { /* position capture? */
+				return ms.startCapture(s, p+2, capPosition)
+			}
+			return ms.startCapture(s, p+1, capUnfinished)
+		case ')': /* end capture */
+			return ms.endCapture(s, p+1)
+		case lEsc:
+			switch ms.pat(p + 1) {
+			case 'b': /* balanced string? */
+				s = ms.matchBalance(s, p+2)
+				if s == -1 {
+					return -1
+				}
+				p += 4
+				continue /* else return match(ms, s, p+4); */
+			case 'f': /* frontier? */
+				p += 2
+				if ms.pat(p) != '[' {
+					ms.error(ErrFrontierBracket)
+				}
+				var ep = ms.classEnd(p) /* points to what is next */
+				var prev, cur byte
+				if s > 0 {
+					prev = ms.src[s-1]
+				}
+				if s < len(ms.src) {
+					cur = ms.src[s]
+				}
+				if ms.matchBracketClass(prev, p, ep-1) || !ms.matchBracketClass(cur, p, ep-1) {
+					return -1
+				}
+				p = ep
+				continue /* else return match(ms, s, ep); */
+			default:
+				if isDigit(ms.pat(p + 1)) { /* capture results (%0-%9)? */
+					s = ms.matchCapture(s, ms.pat(p+1))
+					if s == -1 {
+						return -1
+					}
+					p += 2
+					continue /* else return match(ms, s, p+2) */
+				}
+				/* else goto dflt */
+			}
+		case 0: /* end of pattern */
+			return s /* match succeeded */
+		case '$':
+			if ms.pat(p+1) == 0 { /* is the `$' the last char in pattern? */
+				if s == len(ms.src) { /* check end of string */
+					return s
+				}
+				return -1
+			}
+			/* else goto dflt */
+		}
+		/* dflt: pattern class plus optional suffix */
+		var ep = ms.classEnd(p) /* points to what is next */
+		var m = s < len(ms.src) && ms.singleMatch(ms.src[s], p, ep)
+		switch ms.pat(ep) {
+		case '?': /* optional */
+			if m {
+				if res := ms.match(s+1, ep+1); res != -1 {
+					return res
+				}
+			}
+			p = ep + 1
+			continue /* else return match(ms, s, ep+1); */
+		case '*': /* 0 or more repetitions */
+			return ms.maxExpand(s, p, ep)
+		case '+': /* 1 or more repetitions */
+			if m {
+				return ms.maxExpand(s+1, p, ep)
+			}
+			return -1
+		case '-': /* 0 or more repetitions (minimum) */
+			return ms.minExpand(s, p, ep)
+		default:
+			if !m {
+				return -1
+			}
+			s++
+			p = ep
+			continue /* else return match(ms, s+1, ep); */
+		}
+	}
+}
+
+// result 把一次成功匹配的状态复制为Result，未闭合的捕获留到访问时才报错
+func (ms *matchState) result(s int, e int) *Result {
+	var r = &Result{Src: ms.src, Start: s, End: e}
+	for i := 0; i < ms.level; i++ {
+		var init, l = ms.capture[i].init, ms.capture[i].len
+		switch l {
+		case capUnfinished:
+			r.caps = append(r.caps, Capture{Start: init, unfinished: true})
+		case capPosition:
+			r.caps = append(r.caps, Capture{Start: init, Position: true})
+		default:
+			r.caps = append(r.caps, Capture{Start: init, Value: ms.src[init : init+l]})
+		}
+	}
+	return r
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isAlpha(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func toLower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + ('a' - 'A')
+	}
+	return c
+}
@@ -0,0 +1,213 @@
+package pattern
+
+import "strconv"
+
+// Capture 一个捕获的结果
+type Capture struct {
+	Start      int    /* 捕获在源串中的起始位置（从0开始） */
+	Value      []byte /* 捕获到的内容，位置捕获时为nil */
+	Position   bool   /* 是否为位置捕获"()"，Lua中得到的值为Start+1 */
+	unfinished bool
+}
+
+// Result 一次成功匹配的结果，整个匹配为Src[Start:End]
+type Result struct {
+	Src        []byte
+	Start, End int
+	caps       []Capture
+}
+
+// NumCaptures 返回匹配中显式捕获的个数
+func (r *Result) NumCaptures() int {
+	return len(r.caps)
+}
+
+// Capture 返回第i个捕获（从0开始），模式中没有捕获时第0个捕获为整个匹配
+// 对应C函数：`static void push_onecapture (MatchState *ms, int i, const char *s, const char *e)'
+func (r *Result) Capture(i int) (Capture, error) {
+	if i >= len(r.caps) {
+		if i == 0 { /* ms->level == 0, too */
+			return Capture{Start: r.Start, Value: r.Src[r.Start:r.End]}, nil /* add whole match */
+		}
+		return Capture{}, ErrInvalidCapture
+	}
+	if r.caps[i].unfinished {
+		return Capture{}, ErrUnfinishedCapture
+	}
+	return r.caps[i], nil
+}
+
+// Captures 返回所有捕获，模式中没有捕获时返回整个匹配
+// 对应C函数：`static int push_captures (MatchState *ms, const char *s, const char *e)'
+func (r *Result) Captures() ([]Capture, error) {
+	var n = len(r.caps)
+	if n == 0 {
+		n = 1
+	}
+	var caps = make([]Capture, n)
+	for i := range caps {
+		var err error
+		if caps[i], err = r.Capture(i); err != nil {
+			return nil, err
+		}
+	}
+	return caps, nil
+}
+
+// Expand 按string.gsub替换串的规则展开template并追加到dst：
+// %0为整个匹配，%1-%9为对应的捕获，%后跟其它字符时表示该字符本身
+// 对应C函数：`static void add_s (MatchState *ms, luaL_Buffer *b, const char *s, const char *e)'
+func (r *Result) Expand(dst []byte, template []byte) ([]byte, error) {
+	var l = len(template)
+	for i := 0; i < l; i++ {
+		if template[i] != lEsc {
+			dst = append(dst, template[i])
+			continue
+		}
+		i++ /* skip ESC */
+		var c byte
+		if i < l {
+			c = template[i]
+		}
+		if !isDigit(c) {
+			dst = append(dst, c)
+		} else if c == '0' {
+			dst = append(dst, r.Src[r.Start:r.End]...)
+		} else {
+			var cap, err = r.Capture(int(c - '1'))
+			if err != nil {
+				return nil, err
+			}
+			if cap.Position {
+				dst = strconv.AppendInt(dst, int64(cap.Start+1), 10)
+			} else {
+				dst = append(dst, cap.Value...)
+			}
+		}
+	}
+	return dst, nil
+}
+
+// Find 从init（从0开始的字节位置）起查找src中pat的第一个匹配，没有匹配时返回nil；
+// 以'^'开头的模式只在init处尝试匹配
+// 对应C函数：`static int str_find_aux (lua_State *L, int find)'中模式匹配的部分
+func Find(src []byte, pat []byte, init int) (r *Result, err error) {
+	defer catch(&err)
+	if init < 0 {
+		init = 0
+	} else if init > len(src) {
+		init = len(src)
+	}
+	var ms = matchState{src: src, p: pat}
+	var anchor = ms.pat(0) == '^'
+	var pi = 0
+	if anchor {
+		pi = 1
+	}
+	for s1 := init; ; s1++ {
+		ms.level = 0
+		if e := ms.match(s1, pi); e != -1 {
+			return ms.result(s1, e), nil
+		}
+		if s1 >= len(src) || anchor {
+			return nil, nil /* not found */
+		}
+	}
+}
+
+// Match 与string.match相同，返回第一个匹配的所有捕获，模式中没有捕获时返回整个匹配；
+// 没有匹配时返回nil
+func Match(src []byte, pat []byte, init int) ([]Capture, error) {
+	var r, err = Find(src, pat, init)
+	if r == nil {
+		return nil, err
+	}
+	return r.Captures()
+}
+
+// Iterator 由GMatch返回，依次给出各个匹配
+type Iterator struct {
+	ms  matchState
+	pos int
+}
+
+// GMatch 与string.gmatch相同，从init开始迭代src中pat的所有匹配；
+// 与Lua 5.1一致，'^'在这里不表示锚定
+func GMatch(src []byte, pat []byte, init int) *Iterator {
+	return &Iterator{ms: matchState{src: src, p: pat}, pos: init}
+}
+
+// Next 返回下一个匹配，没有更多匹配时返回nil
+// 对应C函数：`static int gmatch_aux (lua_State *L)'
+func (it *Iterator) Next() (r *Result, err error) {
+	defer catch(&err)
+	for src := it.pos; src <= len(it.ms.src); src++ {
+		it.ms.level = 0
+		if e := it.ms.match(src, 0); e != -1 {
+			it.pos = e
+			if e == src {
+				it.pos++ /* empty match? go at least one position */
+			}
+			return it.ms.result(src, e), nil
+		}
+	}
+	return nil, nil /* not found */
+}
+
+// Pos 返回下一次搜索开始的位置
+func (it *Iterator) Pos() int {
+	return it.pos
+}
+
+// Replacer 计算一个匹配的替换内容，ok为false时保留原文
+type Replacer func(r *Result) (repl []byte, ok bool, err error)
+
+// Template 返回按Expand规则展开template的Replacer，相当于string.gsub的字符串替换
+func Template(template []byte) Replacer {
+	return func(r *Result) ([]byte, bool, error) {
+		var repl, err = r.Expand(nil, template)
+		return repl, true, err
+	}
+}
+
+// GSub 与string.gsub相同，把src中pat的前n个匹配（n<0时为全部）替换为repl的结果，
+// 返回替换后的内容和匹配的次数
+// 对应C函数：`static int str_gsub (lua_State *L)'
+func GSub(src []byte, pat []byte, n int, repl Replacer) (out []byte, count int, err error) {
+	defer catch(&err)
+	var ms = matchState{src: src, p: pat}
+	var anchor = ms.pat(0) == '^'
+	var pi = 0
+	if anchor {
+		pi = 1
+	}
+	var s = 0
+	for n < 0 || count < n {
+		ms.level = 0
+		var e = ms.match(s, pi)
+		if e != -1 {
+			count++
+			var v, ok, err = repl(ms.result(s, e))
+			if err != nil {
+				return nil, 0, err
+			}
+			if !ok {
+				v = src[s:e] /* keep original text */
+			}
+			out = append(out, v...)
+		}
+		if e != -1 && e > s { /* non empty match? */
+			s = e /* skip it */
+		} else if s < len(src) {
+			out = append(out, src[s])
+			s++
+		} else {
+			break
+		}
+		if anchor {
+			break
+		}
+	}
+	out = append(out, src[s:]...)
+	return out, count, nil
+}
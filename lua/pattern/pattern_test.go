@@ -0,0 +1,188 @@
+package pattern
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// values 把捕获转换为与Lua中相同的字符串形式，位置捕获为从1开始的位置
+func values(caps []Capture) []string {
+	var ret []string
+	for _, c := range caps {
+		if c.Position {
+			ret = append(ret, strconv.Itoa(c.Start+1))
+		} else {
+			ret = append(ret, string(c.Value))
+		}
+	}
+	return ret
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		src, pat   string
+		init       int
+		start, end int
+	}{
+		{"hello world", "o w", 0, 4, 7},
+		{"hello world", "o", 5, 7, 8},
+		{"hello", "^h", 0, 0, 1},
+		{"hello", "^e", 0, -1, -1},
+		{"hello", "^e", 1, 1, 2},
+		{"hello", "l+", 0, 2, 4},
+		{"hello", "o$", 0, 4, 5},
+		{"a$b", "$b", 0, 1, 3},
+		{"", "", 0, 0, 0},
+		{"abc", "", 10, 3, 3},
+		{"f(a(b)c)d", "%b()", 0, 1, 8},
+		{"THE (quick) fox", "%f[%a]%a+", 3, 5, 10},
+		{"[x]", "[%]]", 0, 2, 3},
+		{"x-y", "[a-]", 0, 1, 2},
+		{"a\x00b", "%z", 0, 1, 2},
+		{"aaab", "a-b", 0, 0, 4},
+		{"ab", "a?b", 0, 0, 2},
+		{"b", "a?b", 0, 0, 1},
+	}
+	for _, tt := range tests {
+		r, err := Find([]byte(tt.src), []byte(tt.pat), tt.init)
+		if err != nil {
+			t.Errorf("Find(%q, %q) error = %v", tt.src, tt.pat, err)
+			continue
+		}
+		start, end := -1, -1
+		if r != nil {
+			start, end = r.Start, r.End
+		}
+		if start != tt.start || end != tt.end {
+			t.Errorf("Find(%q, %q, %d) = %d, %d, want %d, %d", tt.src, tt.pat, tt.init, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		src, pat string
+		want     []string
+	}{
+		{"key = value", "(%w+)%s*=%s*(%w+)", []string{"key", "value"}},
+		{"hello", "()ll()", []string{"3", "5"}},
+		{"  trim  ", "^%s*(.-)%s*$", []string{"trim"}},
+		{"hello", "l+", []string{"ll"}},
+		{"aaa", "(a)%1", []string{"a"}},
+		{"x = 'a' .. \"b\"", "([\"'])(.-)%1", []string{"'", "a"}},
+		{"hello", "xyz", nil},
+	}
+	for _, tt := range tests {
+		caps, err := Match([]byte(tt.src), []byte(tt.pat), 0)
+		if err != nil {
+			t.Errorf("Match(%q, %q) error = %v", tt.src, tt.pat, err)
+			continue
+		}
+		if got := values(caps); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Match(%q, %q) = %q, want %q", tt.src, tt.pat, got, tt.want)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		src, pat string
+		want     error
+	}{
+		{"abc", "[a", ErrMissingBracket},
+		{"abc", "%", ErrEndsWithEscape},
+		{"abc", "%b", ErrUnbalanced},
+		{"abc", "%fa", ErrFrontierBracket},
+		{"abc", "a)", ErrInvalidPattern},
+		{"abc", "%1", ErrInvalidCapture},
+		{"abc", "(a", ErrUnfinishedCapture},
+		{"abc", strings.Repeat("()", MaxCaptures+1), ErrTooManyCaptures},
+	}
+	for _, tt := range tests {
+		_, err := Match([]byte(tt.src), []byte(tt.pat), 0)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("Match(%q, %q) error = %v, want %v", tt.src, tt.pat, err, tt.want)
+		}
+	}
+	// 与Lua一致，未闭合的捕获只在被访问时才报错
+	r, err := Find([]byte("abc"), []byte("(a"), 0)
+	if err != nil || r == nil || r.Start != 0 || r.End != 1 {
+		t.Errorf("Find with unfinished capture = %v, %v", r, err)
+	}
+}
+
+func TestGMatch(t *testing.T) {
+	tests := []struct {
+		src, pat string
+		want     []string
+	}{
+		{"one two", "%a+", []string{"one", "two"}},
+		{"one two", "%a*", []string{"one", "", "two", ""}},
+		{"a=1, b=2", "(%w+)=(%w+)", []string{"a", "1", "b", "2"}},
+		{"^a^a", "^a", []string{"^a", "^a"}}, /* '^' is not an anchor in gmatch */
+	}
+	for _, tt := range tests {
+		var got []string
+		it := GMatch([]byte(tt.src), []byte(tt.pat), 0)
+		for {
+			r, err := it.Next()
+			if err != nil {
+				t.Fatalf("GMatch(%q, %q) error = %v", tt.src, tt.pat, err)
+			}
+			if r == nil {
+				break
+			}
+			caps, _ := r.Captures()
+			got = append(got, values(caps)...)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GMatch(%q, %q) = %q, want %q", tt.src, tt.pat, got, tt.want)
+		}
+	}
+}
+
+func TestGSub(t *testing.T) {
+	tests := []struct {
+		src, pat, repl string
+		n              int
+		want           string
+		count          int
+	}{
+		{"hello world", "o", "0", -1, "hell0 w0rld", 2},
+		{"hello world", "(%w+)", "<%1>", -1, "<hello> <world>", 2},
+		{"hello world", "%w+", "%0 %0", 1, "hello hello world", 1},
+		{"abc", "", "-", -1, "-a-b-c-", 4},
+		{"abc", "^", "-", -1, "-abc", 1},
+		{"abc", "b", "x", 0, "abc", 0},
+		{"abc", "()b", "%1", -1, "a2c", 1},
+		{"100%", "%%", "%% %q", -1, "100% q", 1},
+		{"x", "(x", "%0", -1, "x", 1},
+	}
+	for _, tt := range tests {
+		out, count, err := GSub([]byte(tt.src), []byte(tt.pat), tt.n, Template([]byte(tt.repl)))
+		if err != nil {
+			t.Errorf("GSub(%q, %q, %q) error = %v", tt.src, tt.pat, tt.repl, err)
+			continue
+		}
+		if string(out) != tt.want || count != tt.count {
+			t.Errorf("GSub(%q, %q, %q) = %q, %d, want %q, %d", tt.src, tt.pat, tt.repl, out, count, tt.want, tt.count)
+		}
+	}
+
+	out, count, err := GSub([]byte("hello world"), []byte("%w+"), -1, func(r *Result) ([]byte, bool, error) {
+		if string(r.Src[r.Start:r.End]) == "world" {
+			return nil, false, nil
+		}
+		return []byte(strings.ToUpper(string(r.Src[r.Start:r.End]))), true, nil
+	})
+	if err != nil || string(out) != "HELLO world" || count != 2 {
+		t.Errorf("GSub with function = %q, %d, %v", out, count, err)
+	}
+
+	if _, _, err := GSub([]byte("x"), []byte("x"), -1, Template([]byte("%2"))); !errors.Is(err, ErrInvalidCapture) {
+		t.Errorf("GSub with bad capture error = %v, want %v", err, ErrInvalidCapture)
+	}
+}
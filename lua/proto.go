@@ -33,7 +33,7 @@ func (p *Proto) pcRel(pc *Instruction) int {
 
 // 对应C函数：`getline(f,pc)'
 func (p *Proto) getLine(pc int) int {
-	if p.lineInfo != nil {
+	if len(p.lineInfo) != 0 && pc >= 0 {
 		return p.lineInfo[pc]
 	}
 	return 0
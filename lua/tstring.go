@@ -44,8 +44,9 @@ type StringTable struct {
 // 对应C函数：`void luaS_resize (lua_State *L, int newsize)'
 func (L *LuaState) sResize(newSize uint64) {
 
-	// todo: if (G(L)->gcstate == GCSsweepstring)
-	// todo: return;  /* cannot resize during GC traverse */
+	if L.G().gcState == GCSSweepString {
+		return /* cannot resize during GC traverse */
+	}
 
 	// newhash = luaM_newvector(L, newsize, GCObject *);
 	newHash := make([]GCObject, newSize)
@@ -82,6 +83,7 @@ func (L *LuaState) newStr(str []byte, h uint64) *TString {
 		// L.MemTooBig()
 		mem.ErrTooBig(L)
 	}
+	L.MRealloc(0, int(unsafe.Sizeof(TString{}))+l)
 	ts := &TString{
 		CommonHeader: CommonHeader{
 			next:   nil,
@@ -121,7 +123,10 @@ func (L *LuaState) sNewStr(str []byte) *TString {
 	for ; o != nil; o = o.GetNext() {
 		ts := o.ToTString()
 		if ts.Len == l && bytes.Compare(str, ts.GetStr()) == 0 {
-			// todo: if (isdead(G(L), o)) changewhite(o);
+			/* string may be dead */
+			if isdead(L.G(), o) {
+				ts.ChangeWhite()
+			}
 			return ts
 		}
 	}
@@ -6,4 +6,16 @@ type Udata struct {
 	env       *Table
 	len       int
 	data      []byte
+	value     interface{} /* Go value held by the userdata */
+}
+
+// Value 返回userdata中保存的Go值。
+// C中的userdata可以在内存块里保存指针，而Go的[]byte不能保存Go指针，因此这里单独留一个位置。
+func (u *Udata) Value() interface{} {
+	return u.value
+}
+
+// SetValue 设置userdata中保存的Go值
+func (u *Udata) SetValue(v interface{}) {
+	u.value = v
 }
@@ -2,6 +2,7 @@ package golua
 
 import (
 	"fmt"
+	"math"
 	"unsafe"
 )
 
@@ -185,7 +186,16 @@ func (v *TValue) SetObj(L *LuaState, obj *TValue) {
 
 // NumberToStr
 // 对应C函数：`lua_number2str(s,n)'
+// 无穷大和NaN按照C中printf的格式输出
 func NumberToStr(n LuaNumber) string {
+	switch {
+	case math.IsInf(n, 1):
+		return "inf"
+	case math.IsInf(n, -1):
+		return "-inf"
+	case math.IsNaN(n):
+		return "nan"
+	}
 	return fmt.Sprintf(LUA_NUMBER_FMT, n)
 }
 